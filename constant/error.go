@@ -1,17 +1,92 @@
 package constant
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
+
+// Error defines a domain error with a stable machine readable code, clients branch on the code and not on the message
+type Error struct {
+	Code    string
+	Message string
+}
+
+// Error returns the message of the error
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Error codes
+const (
+	CodeDBErr                 = "database_error"
+	CodeDBUnavailable         = "database_unavailable"
+	CodeBookNotFound          = "book_not_found"
+	CodeInvalidRequest        = "invalid_request"
+	CodeRetrievingBookDetails = "provider_error"
+	CodeRequestCanceled       = "request_canceled"
+	CodeTimeout               = "timeout"
+	// CodeInternal is the code of errors that are not domain errors
+	CodeInternal = "internal_error"
+)
 
 var (
 	// ErrDBErr ...
-	ErrDBErr = errors.New("database returns error")
+	ErrDBErr = &Error{Code: CodeDBErr, Message: "database returns error"}
+
+	// ErrDBUnavailable is returned when the database cannot be reached
+	ErrDBUnavailable = &Error{Code: CodeDBUnavailable, Message: "database unavailable"}
 
 	// ErrBookNotFound ...
-	ErrBookNotFound = errors.New("book not found")
+	ErrBookNotFound = &Error{Code: CodeBookNotFound, Message: "book not found"}
 
 	// ErrInvalidRequest ...
-	ErrInvalidRequest = errors.New("invalid request parameter")
+	ErrInvalidRequest = &Error{Code: CodeInvalidRequest, Message: "invalid request parameter"}
+
+	// ErrRetrievingBookDetails is returned when a book provider fails
+	ErrRetrievingBookDetails = &Error{Code: CodeRetrievingBookDetails, Message: "error retrieving book details"}
 
-	// ErrRetrievingBookDetails ...
-	ErrRetrievingBookDetails = errors.New("error retrieving book details")
+	// ErrRequestCanceled ...
+	ErrRequestCanceled = &Error{Code: CodeRequestCanceled, Message: "request canceled"}
+
+	// ErrTimeout ...
+	ErrTimeout = &Error{Code: CodeTimeout, Message: "request timed out"}
 )
+
+// Code returns the code of the domain error wrapped by err, CodeInternal when err is not a domain error
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// FieldError describes why a field of a request is invalid
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationError is an invalid request error listing the invalid fields of the request
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// InvalidField returns a ValidationError for a single invalid field
+func InvalidField(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// Error returns the message of ErrInvalidRequest followed by the invalid fields
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return ErrInvalidRequest.Error() + ": " + strings.Join(msgs, ", ")
+}
+
+// Unwrap returns ErrInvalidRequest, a ValidationError has its code
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidRequest
+}
@@ -1,21 +1,112 @@
 package entities
 
+import (
+	"strings"
+	"time"
+)
+
 // Book represents a Book object
 type Book struct {
-	BookID          int64  `db:"id"`
-	ISBN            string `db:"isbn"`
-	Title           string `db:"title"`
-	Authors         string `db:"authors"`
-	ImageURL        string `db:"imageUrl"`
-	SmallImageURL   string `db:"smallImageUrl"`
-	PublicationYear int64  `db:"publicationYear"`
-	Publisher       string `db:"publisher"`
-	UserID          string `db:"userId"`
-	Status          int64  `db:"status"`
-
-	Description string `db:"description"`
-	PageCount   int64  `db:"pageCount"`
-	Categories  string `db:"categories"`
-	Language    string `db:"language"`
-	Source      string `db:"source"`
+	BookID          int64    `db:"id"`
+	ISBN            string   `db:"isbn"`
+	Title           string   `db:"title"`
+	Authors         []string `db:"-"`
+	ImageURL        string   `db:"imageUrl"`
+	SmallImageURL   string   `db:"smallImageUrl"`
+	PublicationYear int64    `db:"publicationYear"`
+	Publisher       string   `db:"publisher"`
+	UserID          string   `db:"userId"`
+	Status          Status   `db:"status"`
+
+	Description string   `db:"description"`
+	PageCount   int64    `db:"pageCount"`
+	Categories  []string `db:"-"`
+	Language    string   `db:"language"`
+	Source      string   `db:"source"`
+	// Binding is the binding of the edition, ie: Paperback, it is looked up from providers but not stored
+	Binding string `db:"-"`
+	// Provenance is the source of every field of a book merged from providers by field name, nil for stored books
+	Provenance map[string]string `db:"-"`
+
+	// StartedAt and FinishedAt are set when the status changes, see Transition
+	StartedAt  *time.Time `db:"startedAt"`
+	FinishedAt *time.Time `db:"finishedAt"`
+
+	// Review is stored in the reviews table, nil when the book is neither rated nor reviewed
+	Review *Review `db:"-"`
+
+	// CurrentPage is the page reached in the last reading session, see Session
+	CurrentPage int64 `db:"currentPage"`
+	// Progress is computed from CurrentPage and the reading pace of the user, nil when it is not computed
+	Progress *Progress `db:"-"`
+
+	DeletedAt *time.Time `db:"deletedAt"`
+}
+
+// Maximum lengths in characters of the Book fields, matching the column widths of the books table
+const (
+	MaxISBNLength        = 20
+	MaxUserIDLength      = 45
+	MaxTitleLength       = 255
+	MaxImageURLLength    = 2048
+	MaxPublisherLength   = 255
+	MaxDescriptionLength = 65535
+	MaxLanguageLength    = 32
+	MaxSourceLength      = 255
+	MaxReviewLength      = 65535
+	// MaxNameLength is the maximum length of an author or category name
+	MaxNameLength = 255
+	// MaxNames is the maximum number of authors or categories of a book
+	MaxNames = 50
+)
+
+// MinPublicationYear is the lowest publication year of a book, 0 is an unknown year
+const MinPublicationYear = 1
+
+// BookPatch represents a partial update of a Book, nil fields are left unchanged
+type BookPatch struct {
+	Title           *string
+	Authors         *[]string
+	ImageURL        *string
+	SmallImageURL   *string
+	PublicationYear *int64
+	Publisher       *string
+	Status          *Status
+
+	Description *string
+	PageCount   *int64
+	Categories  *[]string
+	Language    *string
+	Source      *string
+
+	// StartedAt and FinishedAt are written with Status, nil clears them
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+
+	// Rating and Review update the review of the book, a review without rating and text is removed
+	Rating *Rating
+	Review *string
+
+	// CurrentPage is written by reading sessions only
+	CurrentPage *int64
+}
+
+// NormalizeNames trims author or category names and drops empty and repeated names, the order is kept
+func NormalizeNames(names []string) []string {
+	var res []string
+	seen := map[string]bool{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		res = append(res, n)
+	}
+	return res
+}
+
+// SplitNames splits comma joined author or category names, as stored before names were normalized
+func SplitNames(s string) []string {
+	return NormalizeNames(strings.Split(s, ","))
 }
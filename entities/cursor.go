@@ -0,0 +1,77 @@
+package entities
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Cursor is the position of a book in a list, the next page starts after it in the same sort order
+type Cursor struct {
+	// Values holds the values of the sort fields of the book, in the order of the sort fields
+	Values []interface{}
+	ID     int64
+}
+
+// cursorData is the encoded form of a Cursor, the sort order is kept to reject cursors of another order
+type cursorData struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	ID     int64         `json:"id"`
+}
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or was created for another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// NewCursor returns the cursor after b in a list ordered by sort
+func NewCursor(b *Book, sort []SortField) *Cursor {
+	c := &Cursor{ID: b.BookID, Values: make([]interface{}, len(sort))}
+	for i, s := range sort {
+		c.Values[i] = SortValue(b, s.Field)
+	}
+	return c
+}
+
+// EncodeCursor encodes c into an opaque string for a list ordered by sort
+func EncodeCursor(c *Cursor, sort []SortField) string {
+	b, _ := json.Marshal(cursorData{Sort: FormatSort(sort), Values: c.Values, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor decodes a cursor encoded by EncodeCursor, the cursor has to be created for a list ordered by sort
+func DecodeCursor(s string, sort []SortField) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	data := cursorData{}
+	if err = d.Decode(&data); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if data.Sort != FormatSort(sort) || len(data.Values) != len(sort) {
+		return nil, fmt.Errorf("%w: the cursor was created for another sort order", ErrInvalidCursor)
+	}
+	c := &Cursor{ID: data.ID, Values: make([]interface{}, len(sort))}
+	for i, s := range sort {
+		switch v := data.Values[i].(type) {
+		case string:
+			if _, ok := SortValue(&Book{}, s.Field).(string); !ok {
+				return nil, ErrInvalidCursor
+			}
+			c.Values[i] = v
+		case json.Number:
+			n, err := v.Int64()
+			if _, ok := SortValue(&Book{}, s.Field).(int64); !ok || err != nil {
+				return nil, ErrInvalidCursor
+			}
+			c.Values[i] = n
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
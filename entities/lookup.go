@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+// Lookup is the cached result of looking up the details of a book from providers by its ISBN-13
+type Lookup struct {
+	ISBN string
+	// Book is nil when no provider found the book
+	Book      *Book
+	ExpiresAt time.Time
+}
+
+// Expired reports whether the lookup is expired at now
+func (l *Lookup) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"math"
+	"time"
+)
+
+// PaceWindow is the period of recent reading sessions the reading pace of a user is computed from
+const PaceWindow = 14 * 24 * time.Hour
+
+// Session is a reading session of a book, from the page the user was on when it started to the page reached
+type Session struct {
+	BookID    int64     `db:"bookId"`
+	StartedAt time.Time `db:"startedAt"`
+	EndedAt   time.Time `db:"endedAt"`
+	StartPage int64     `db:"startPage"`
+	EndPage   int64     `db:"endPage"`
+}
+
+// PagesRead returns the number of pages read in the session, going back to an earlier page reads none
+func (s *Session) PagesRead() int64 {
+	if s.EndPage > s.StartPage {
+		return s.EndPage - s.StartPage
+	}
+	return 0
+}
+
+// ProgressUpdate is the progress reported by a user, either the current page or the percentage of the book read
+type ProgressUpdate struct {
+	Page    *int64
+	Percent *float64
+
+	// StartedAt and EndedAt bound the reading session, EndedAt defaults to the time of the update and StartedAt to EndedAt
+	StartedAt *time.Time
+	EndedAt   *time.Time
+}
+
+// Progress is the progress of a user through a book
+type Progress struct {
+	Percent float64
+	// EstimatedFinish is nil unless the book is being read at a known pace
+	EstimatedFinish *time.Time
+}
+
+// Pace returns the pages read per day in sessions, over the days from the start of the earliest session to now and at least one day
+func Pace(sessions []*Session, now time.Time) float64 {
+	var pages int64
+	first := now
+	for _, s := range sessions {
+		pages += s.PagesRead()
+		if s.StartedAt.Before(first) {
+			first = s.StartedAt
+		}
+	}
+	days := math.Max(now.Sub(first).Hours()/24, 1)
+	return float64(pages) / days
+}
+
+// ProgressOf returns the progress of the user through b reading pace pages per day, nil when the page count of b is unknown
+func ProgressOf(b *Book, pace float64, now time.Time) *Progress {
+	if b.PageCount <= 0 {
+		return nil
+	}
+	p := &Progress{Percent: math.Min(math.Round(float64(b.CurrentPage)*1000/float64(b.PageCount))/10, 100)}
+	if left := b.PageCount - b.CurrentPage; b.Status == StatusReading && left > 0 && pace > 0 {
+		finish := now.Add(time.Duration(float64(left) / pace * float64(24*time.Hour))).Truncate(time.Second)
+		p.EstimatedFinish = &finish
+	}
+	return p
+}
+
+// Estimating reports whether the progress of b depends on the reading pace of its user
+func Estimating(b *Book) bool {
+	return b.Status == StatusReading && b.PageCount > b.CurrentPage
+}
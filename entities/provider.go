@@ -0,0 +1,30 @@
+package entities
+
+import "time"
+
+// CircuitState is the state of the circuit breaker of a metadata provider
+type CircuitState string
+
+// Circuit states
+const (
+	// CircuitClosed lets lookups through
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen skips the provider until the cooldown ends
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single trial lookup through, which closes the circuit when it succeeds
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// ProviderStatus is the configuration and health of a metadata provider of the lookup chain
+type ProviderStatus struct {
+	Name    string
+	Timeout time.Duration
+	Policy  string
+	Retries int
+
+	State CircuitState
+	// Failures is the number of consecutive failed lookups
+	Failures int
+	// OpenUntil is the end of the cooldown of an open circuit, nil unless the circuit is open
+	OpenUntil *time.Time
+}
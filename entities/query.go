@@ -0,0 +1,100 @@
+package entities
+
+import "strings"
+
+// ListQuery defines the filters, order and page of a list of books, zero value filters match every book
+type ListQuery struct {
+	UserID    string
+	Status    *Status
+	Author    string
+	Category  string
+	Language  string
+	Publisher string
+	// YearFrom and YearTo bound the publication year, both inclusive
+	YearFrom *int64
+	YearTo   *int64
+	// RatingFrom and RatingTo bound the rating, both inclusive, books that are not rated have rating 0
+	RatingFrom *Rating
+	RatingTo   *Rating
+	// Q matches books whose title or description contains every word of Q, ignoring case
+	Q    string
+	Sort []SortField
+
+	// After starts the page after the book of the cursor, it is used instead of Offset
+	After  *Cursor
+	Limit  int64
+	Offset int64
+}
+
+// SortField defines a field books are ordered by
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Fields books can be ordered by, books are ordered by id after the requested fields
+const (
+	SortTitle           = "title"
+	SortPublicationYear = "publicationYear"
+	SortPublisher       = "publisher"
+	SortPageCount       = "pageCount"
+	SortStatus          = "status"
+	SortLanguage        = "language"
+	SortRating          = "rating"
+)
+
+// SortFields is the set of fields books can be ordered by
+var SortFields = map[string]bool{
+	SortTitle:           true,
+	SortPublicationYear: true,
+	SortPublisher:       true,
+	SortPageCount:       true,
+	SortStatus:          true,
+	SortLanguage:        true,
+	SortRating:          true,
+}
+
+// BookPage defines a page of books
+type BookPage struct {
+	Items []*Book
+	// NextCursor continues the list after the last item, empty on the last page
+	NextCursor string
+	// TotalCount is the number of books matching the filters on every page
+	TotalCount int64
+}
+
+// SortValue returns the value of the sort field of b
+func SortValue(b *Book, field string) interface{} {
+	switch field {
+	case SortTitle:
+		return b.Title
+	case SortPublicationYear:
+		return b.PublicationYear
+	case SortPublisher:
+		return b.Publisher
+	case SortPageCount:
+		return b.PageCount
+	case SortStatus:
+		return int64(b.Status)
+	case SortLanguage:
+		return b.Language
+	case SortRating:
+		if b.Review == nil {
+			return int64(0)
+		}
+		return int64(b.Review.Rating)
+	}
+	return nil
+}
+
+// FormatSort formats sort fields as a comma separated list, descending fields are prefixed with -
+func FormatSort(sort []SortField) string {
+	fields := make([]string, len(sort))
+	for i, s := range sort {
+		fields[i] = s.Field
+		if s.Desc {
+			fields[i] = "-" + s.Field
+		}
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,91 @@
+package entities
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Rating is the rating of a book in half stars, from 1 for half a star to MaxRating for five stars, 0 is no rating
+type Rating int64
+
+// MaxRating is the rating of five stars
+const MaxRating Rating = 10
+
+// ErrInvalidRating is returned for a number of stars that is not a multiple of half a star
+var ErrInvalidRating = errors.New("invalid rating")
+
+// RoundRating returns the rating nearest to stars, an average rating is rounded to half stars
+func RoundRating(stars float64) Rating {
+	return Rating(math.Round(stars * 2))
+}
+
+// Stars returns the number of stars of the rating
+func (r Rating) Stars() float64 {
+	return float64(r) / 2
+}
+
+// Valid reports whether r is no rating or between half a star and five stars
+func (r Rating) Valid() bool {
+	return r >= 0 && r <= MaxRating
+}
+
+// MarshalText returns the number of stars of the rating
+func (r Rating) MarshalText() ([]byte, error) {
+	return []byte(strconv.FormatFloat(r.Stars(), 'f', -1, 64)), nil
+}
+
+// UnmarshalText parses a number of stars, as sent in forms, query parameters and JSON
+func (r *Rating) UnmarshalText(b []byte) error {
+	stars, err := strconv.ParseFloat(string(b), 64)
+	if err != nil || stars*2 != math.Trunc(stars*2) {
+		return ErrInvalidRating
+	}
+	*r = RoundRating(stars)
+	return nil
+}
+
+// UnmarshalJSON parses a number of stars
+func (r *Rating) UnmarshalJSON(b []byte) error {
+	return r.UnmarshalText(b)
+}
+
+// Review is the rating and review of a book by the user of its library
+type Review struct {
+	Rating    Rating    `db:"rating"`
+	Text      string    `db:"review"`
+	CreatedAt time.Time `db:"createdAt"`
+	UpdatedAt time.Time `db:"updatedAt"`
+}
+
+// Update returns the review with the rating and text of next written at now, nil when next has neither.
+// r is returned when neither changes, r is nil for a book that was not reviewed and its creation time is kept otherwise
+func (r *Review) Update(next *Review, now time.Time) *Review {
+	if next == nil || (next.Rating == 0 && next.Text == "") {
+		return nil
+	}
+	if r != nil && r.Rating == next.Rating && r.Text == next.Text {
+		return r
+	}
+	u := &Review{Rating: next.Rating, Text: next.Text, CreatedAt: now, UpdatedAt: now}
+	if r != nil {
+		u.CreatedAt = r.CreatedAt
+	}
+	return u
+}
+
+// PatchedReview returns the rating and text of the review r with those set in patch
+func PatchedReview(r *Review, patch *BookPatch) *Review {
+	next := &Review{}
+	if r != nil {
+		next.Rating, next.Text = r.Rating, r.Text
+	}
+	if patch.Rating != nil {
+		next.Rating = *patch.Rating
+	}
+	if patch.Review != nil {
+		next.Text = *patch.Review
+	}
+	return next
+}
@@ -0,0 +1,44 @@
+package entities
+
+// TopStats is the number of authors, categories and publishers listed in the statistics of a library
+const TopStats = 10
+
+// StatusCount is the number of books with a reading status
+type StatusCount struct {
+	Status Status `db:"status"`
+	Count  int64  `db:"n"`
+}
+
+// PeriodCount is a number of pages or books in a month, formatted as 2006-01, or in a year
+type PeriodCount struct {
+	Period string `db:"period"`
+	Count  int64  `db:"n"`
+}
+
+// NameCount is the number of books of an author, category or publisher
+type NameCount struct {
+	Name  string `db:"name"`
+	Count int64  `db:"n"`
+}
+
+// YearCount is the number of books published in a year
+type YearCount struct {
+	Year  int64 `db:"year"`
+	Count int64 `db:"n"`
+}
+
+// Stats aggregates the books in the library of a user, deleted books are excluded.
+// Periods and years are in ascending order, names are ordered by count and then by name
+type Stats struct {
+	Statuses         []StatusCount
+	PagesPerMonth    []PeriodCount
+	FinishedPerYear  []PeriodCount
+	TopAuthors       []NameCount
+	TopCategories    []NameCount
+	TopPublishers    []NameCount
+	PublicationYears []YearCount
+
+	// AverageRating is in half stars over the Rated books, 0 when no book is rated
+	AverageRating float64
+	Rated         int64
+}
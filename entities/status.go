@@ -0,0 +1,110 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Status is the reading status of a book in a library, it is stored as a number and named in the API
+type Status int64
+
+// Reading statuses, the numbers 0 to 2 sent by earlier versions stay valid
+const (
+	StatusWishlist Status = iota
+	StatusOwned
+	StatusToRead
+	StatusReading
+	StatusRead
+	StatusAbandoned
+)
+
+// ErrInvalidStatus is returned for a status name or number that is not defined
+var ErrInvalidStatus = errors.New("invalid status")
+
+// Statuses lists every status in lifecycle order
+var Statuses = []Status{StatusWishlist, StatusOwned, StatusToRead, StatusReading, StatusRead, StatusAbandoned}
+
+var statusNames = map[Status]string{
+	StatusWishlist:  "wishlist",
+	StatusOwned:     "owned",
+	StatusToRead:    "to-read",
+	StatusReading:   "reading",
+	StatusRead:      "read",
+	StatusAbandoned: "abandoned",
+}
+
+// String returns the name of the status
+func (s Status) String() string {
+	if n, ok := statusNames[s]; ok {
+		return n
+	}
+	return strconv.FormatInt(int64(s), 10)
+}
+
+// Valid reports whether s is a defined status
+func (s Status) Valid() bool {
+	_, ok := statusNames[s]
+	return ok
+}
+
+// ParseStatus returns the status named name, the numbers of earlier versions are accepted as well
+func ParseStatus(name string) (Status, error) {
+	for s, n := range statusNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	i, err := strconv.ParseInt(name, 10, 64)
+	if err != nil || !Status(i).Valid() {
+		return 0, ErrInvalidStatus
+	}
+	return Status(i), nil
+}
+
+// MarshalText returns the name of the status
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a status name or number, as sent in forms and query parameters
+func (s *Status) UnmarshalText(b []byte) error {
+	v, err := ParseStatus(string(b))
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// UnmarshalJSON accepts a status name or the number of earlier versions
+func (s *Status) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		return s.UnmarshalText([]byte(name))
+	}
+	var i int64
+	if err := json.Unmarshal(b, &i); err != nil || !Status(i).Valid() {
+		return ErrInvalidStatus
+	}
+	*s = Status(i)
+	return nil
+}
+
+// Transition sets the startedAt and finishedAt of b changing to its status at now, prev is the stored book and nil for a new book.
+// Starting to read sets startedAt and clears finishedAt, reading or abandoning a book sets finishedAt, other statuses keep both times
+func Transition(b *Book, prev *Book, now time.Time) {
+	if prev != nil {
+		b.StartedAt, b.FinishedAt = prev.StartedAt, prev.FinishedAt
+		if prev.Status == b.Status {
+			return
+		}
+	}
+	switch b.Status {
+	case StatusReading:
+		b.StartedAt, b.FinishedAt = &now, nil
+	case StatusRead, StatusAbandoned:
+		b.FinishedAt = &now
+	}
+}
@@ -1,34 +1,78 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"go.uber.org/zap"
 
 	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
 	"github.com/abx123/library/handler/presenter"
 	"github.com/abx123/library/services"
 )
 
+const (
+	// statusClientClosedRequest is the nginx convention for requests canceled by the client
+	statusClientClosedRequest = 499
+	// mimeProblem is the media type of RFC 7807 problem details, errors are plain error objects unless it is accepted
+	mimeProblem = "application/problem+json"
+)
+
 type postUpsertBookRequest struct {
-	BookID          int64   `json:"id" form:"id"`
-	ISBN            string  `json:"isbn" form:"isbn"`
-	Title           string  `json:"title" form:"title"`
-	Author          string  `json:"author" form:"author"`
-	ImageURL        string  `json:"imageUrl" form:"imageUrl"`
-	SmallImageURL   string  `json:"smallImageUrl" form:"smallImageUrl"`
-	PublicationYear int64   `json:"publicationYear" form:"publicationYear"`
-	AverageRating   float64 `json:"averageRating" form:"averageRating"`
-	Status          int64   `json:"status" form:"status"`
-	Publisher       string  `json:"publisher" form:"publisher"`
-	Description     string  `json:"description" form:"description"`
-	Categories      string  `json:"categories" form:"categories"`
-	Language        string  `json:"language" form:"language"`
-	Source          string  `json:"source" form:"source"`
-	PageCount       int64   `json:"pageCount" form:"pageCount"`
+	BookID          int64           `json:"id" form:"id"`
+	ISBN            string          `json:"isbn" form:"isbn"`
+	Title           string          `json:"title" form:"title"`
+	Authors         names           `json:"authors" form:"authors"`
+	Author          string          `json:"author" form:"author"`
+	ImageURL        string          `json:"imageUrl" form:"imageUrl"`
+	SmallImageURL   string          `json:"smallImageUrl" form:"smallImageUrl"`
+	PublicationYear int64           `json:"publicationYear" form:"publicationYear"`
+	Status          entities.Status `json:"status" form:"status"`
+	Rating          entities.Rating `json:"rating" form:"rating"`
+	Review          string          `json:"review" form:"review"`
+	// AverageRating is the rating sent by earlier versions, it is used when rating is not set
+	AverageRating float64 `json:"averageRating" form:"averageRating"`
+	Publisher     string  `json:"publisher" form:"publisher"`
+	Description   string  `json:"description" form:"description"`
+	Categories    names   `json:"categories" form:"categories"`
+	Language      string  `json:"language" form:"language"`
+	Source        string  `json:"source" form:"source"`
+	PageCount     int64   `json:"pageCount" form:"pageCount"`
+}
+
+// progressRequest is the reading progress reported for a book, either page or percent is set
+type progressRequest struct {
+	Page      *int64     `json:"page" form:"page"`
+	Percent   *float64   `json:"percent" form:"percent"`
+	StartedAt *time.Time `json:"startedAt" form:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt" form:"endedAt"`
+}
+
+// authors returns the authors of the request, the author field of earlier versions is always a comma joined string
+func (r *postUpsertBookRequest) authors(comma bool) []string {
+	return entities.NormalizeNames(append(splitNames(r.Authors, comma), entities.SplitNames(r.Author)...))
+}
+
+// review returns the rating and review of the request, nil when the book is neither rated nor reviewed.
+// The averageRating of earlier versions is rounded to half stars
+func (r *postUpsertBookRequest) review() *entities.Review {
+	rating := r.Rating
+	if rating == 0 {
+		rating = entities.RoundRating(r.AverageRating)
+	}
+	if rating == 0 && r.Review == "" {
+		return nil
+	}
+	return &entities.Review{Rating: rating, Text: r.Review}
 }
 
 // Handler defines a handler struct
@@ -47,95 +91,72 @@ func NewHandler(dbSvc services.IdbService, bSvc services.Ibooks) *Handler {
 
 // GetBook resolves GET /{userID}/book/{isbn}, retreives details of a book from database
 func (h *Handler) GetBook(c echo.Context) (err error) {
-	reqID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
 	isbn := c.Param("isbn")
 	userId := c.Param("userId")
 
 	data, err := h.dbSvc.Get(c.Request().Context(), isbn, userId)
 	if err != nil {
 		zap.L().Error(err.Error(), zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, presenter.ErrResp(reqID, err))
+		return errResponse(c, err)
 	}
 
 	// Return ok
-	return c.JSON(http.StatusOK, &presenter.Book{
-		ISBN:        isbn,
-		Title:       data.Title,
-		Author:      data.Authors,
-		ImageURL:    data.ImageURL,
-		UserID:      data.UserID,
-		Status:      data.Status,
-		Source:      data.Source,
-		Language:    data.Language,
-		PageCount:   data.PageCount,
-		Description: data.Description,
-		Publisher:   data.Publisher,
-	})
+	return c.JSON(http.StatusOK, present(c, data))
 }
 
 // GetNewBook resolves GET /book/:isbn, retreives details of a book from providers.
 func (h *Handler) GetNewBook(c echo.Context) (err error) {
-	reqID := c.Response().Header().Get(echo.HeaderXRequestID)
-	isbn := c.Param("isbn")
-	if _, err := strconv.ParseInt(isbn, 10, 64); err != nil {
-		// Invalid request parameter
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
 		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
-		return c.JSON(http.StatusBadRequest, presenter.ErrResp(reqID, err))
+		return errResponse(c, err)
 	}
+	isbn := c.Param("isbn")
 
 	data, err := h.bookSvc.Get(c.Request().Context(), isbn)
 	if err != nil {
 		zap.L().Error(err.Error(), zap.Error(err))
-		return c.JSON(http.StatusInternalServerError, presenter.ErrResp(reqID, err))
+		return errResponse(c, err)
 	}
 
 	// Return ok
-	return c.JSON(http.StatusOK, &presenter.Book{
-		ISBN:        data.ISBN,
-		Title:       data.Title,
-		Author:      data.Authors,
-		ImageURL:    data.ImageURL,
-		Status:      data.Status,
-		Source:      data.Source,
-		Language:    data.Language,
-		PageCount:   data.PageCount,
-		Description: data.Description,
-		Publisher:   data.Publisher,
-	})
-}
-
-// ListBook resolves GET /{userID}/books, retreives the list of books related to the userID
+	return c.JSON(http.StatusOK, present(c, data))
+}
+
+// ListBook resolves GET /{userID}/books, retreives the list of books related to the userID filtered and sorted by the query parameters
 func (h *Handler) ListBook(c echo.Context) (err error) {
-	reqID := c.Response().Header().Get(echo.HeaderXRequestID)
-	limit, offset, err := getLimitAndOffest(c)
-	userId := c.Param("userId")
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	q, err := listQuery(c)
 	if err != nil {
 		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
-		return c.JSON(http.StatusBadRequest, presenter.ErrResp(reqID, err))
+		return errResponse(c, err)
 	}
 
-	data, err := h.dbSvc.List(c.Request().Context(), limit, offset, userId)
+	data, err := h.dbSvc.List(c.Request().Context(), q)
 	if err != nil {
 		// Error while querying database
-		return c.JSON(http.StatusInternalServerError, presenter.ErrResp(reqID, err))
+		return errResponse(c, err)
 	}
-	books := []*presenter.Book{}
-	for _, d := range data {
-		books = append(books, &presenter.Book{
-			ISBN:            d.ISBN,
-			Title:           d.Title,
-			Author:          d.Authors,
-			ImageURL:        d.ImageURL,
-			SmallImageURL:   d.SmallImageURL,
-			Publisher:       d.Publisher,
-			Description:     d.Description,
-			PageCount:       d.PageCount,
-			Categories:      d.Categories,
-			Language:        d.Language,
-			PublicationYear: d.PublicationYear,
-			UserID:          d.UserID,
-			Status:          d.Status,
-			Source:          d.Source,
+	books := []interface{}{}
+	for _, d := range data.Items {
+		books = append(books, present(c, d))
+	}
+	setPageHeaders(c, data)
+	if accepts(c, mimePage) {
+		c.Response().Header().Set(echo.HeaderContentType, mimePage)
+		return c.JSON(http.StatusOK, &presenter.BookPage{
+			Items:      books,
+			NextCursor: data.NextCursor,
+			TotalCount: data.TotalCount,
 		})
 	}
 
@@ -147,39 +168,197 @@ func (h *Handler) UpsertBook(c echo.Context) (err error) {
 	r := &postUpsertBookRequest{}
 	userId := c.Param("userId")
 	// isbn := c.Param("isbn")
-	reqID := c.Response().Header().Get(echo.HeaderXRequestID)
 	if err = c.Bind(r); err != nil {
 		// Invalid request parameter
 		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
-		return c.JSON(http.StatusBadRequest, presenter.ErrResp(reqID, constant.ErrInvalidRequest))
+		return errResponse(c, constant.ErrInvalidRequest)
+	}
+	comma := isCompatComma(c)
+	book := &entities.Book{
+		ISBN:            r.ISBN,
+		Title:           r.Title,
+		Authors:         r.authors(comma),
+		ImageURL:        r.ImageURL,
+		SmallImageURL:   r.SmallImageURL,
+		PublicationYear: r.PublicationYear,
+		Publisher:       r.Publisher,
+		UserID:          userId,
+		Status:          r.Status,
+		Description:     r.Description,
+		PageCount:       r.PageCount,
+		Categories:      splitNames(r.Categories, comma),
+		Language:        r.Language,
+		Source:          r.Source,
+		Review:          r.review(),
+	}
+	if err = validate(bookFields(book)...); err != nil {
+		// Invalid request parameter, every invalid field is reported
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	book, err = h.dbSvc.Upsert(c.Request().Context(), book)
+	if err != nil {
+		return errResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, present(c, book))
+}
+
+// PatchBook resolves PATCH /{userID}/book/{isbn}, applies a JSON merge patch (RFC 7396) to a book in the library of the userID,
+// only fields present in the request are updated and fields set to null are reset.
+func (h *Handler) PatchBook(c echo.Context) (err error) {
+	isbn := c.Param("isbn")
+	userId := c.Param("userId")
+
+	r := patchBookRequest{}
+	dec := json.NewDecoder(c.Request().Body)
+	if err = dec.Decode(&r); err == nil {
+		// The merge patch document is a single JSON object, nothing follows it
+		if err = dec.Decode(&json.RawMessage{}); err == io.EOF {
+			err = nil
+		} else if err == nil {
+			err = errors.New("trailing data after the merge patch document")
+		}
 	}
-	if _, err := strconv.ParseInt(r.ISBN, 10, 64); err != nil {
+	if err != nil {
+		// Invalid request parameter, merge patch document must be a JSON object
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, constant.ErrInvalidRequest)
+	}
+	patch, err := r.toPatch(isCompatComma(c))
+	if err == nil {
+		err = validate(append(paramFields(c), patchFields(patch)...)...)
+	}
+	if err != nil {
+		// Invalid request parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	book, err := h.dbSvc.Patch(c.Request().Context(), isbn, userId, patch)
+	if err != nil {
+		zap.L().Error(err.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, present(c, book))
+}
+
+// ProgressBook resolves POST /{userID}/book/{isbn}/progress, records a reading session of a book in the library of the userID
+// up to the current page or percentage read
+func (h *Handler) ProgressBook(c echo.Context) (err error) {
+	r := &progressRequest{}
+	if err = c.Bind(r); err != nil {
 		// Invalid request parameter
 		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
-		return c.JSON(http.StatusBadRequest, presenter.ErrResp(reqID, err))
+		return errResponse(c, constant.ErrInvalidRequest)
+	}
+	u := &entities.ProgressUpdate{Page: r.Page, Percent: r.Percent, StartedAt: r.StartedAt, EndedAt: r.EndedAt}
+	if err = validate(append(paramFields(c), progressFields(u)...)...); err != nil {
+		// Invalid request parameter, every invalid field is reported
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	isbn := c.Param("isbn")
+	userId := c.Param("userId")
+
+	book, err := h.dbSvc.Progress(c.Request().Context(), isbn, userId, u)
+	if err != nil {
+		zap.L().Error(err.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, present(c, book))
+}
+
+// DeleteBook resolves DELETE /{userID}/book/{isbn}, removes a book from the library of the userID
+func (h *Handler) DeleteBook(c echo.Context) (err error) {
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	isbn := c.Param("isbn")
+	userId := c.Param("userId")
+
+	err = h.dbSvc.Delete(c.Request().Context(), isbn, userId)
+	if err != nil {
+		zap.L().Error(err.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	// Return ok
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ListTrash resolves GET /{userID}/trash, retreives the list of deleted books related to the userID
+func (h *Handler) ListTrash(c echo.Context) (err error) {
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	limit, offset, err := getLimitAndOffest(c)
+	userId := c.Param("userId")
+	if err != nil {
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	data, err := h.dbSvc.Trash(c.Request().Context(), limit, offset, userId)
+	if err != nil {
+		// Error while querying database
+		return errResponse(c, err)
+	}
+	books := []interface{}{}
+	for _, d := range data {
+		books = append(books, present(c, d))
+	}
+
+	return c.JSON(http.StatusOK, books)
+}
+
+// RestoreBook resolves POST /{userID}/book/{isbn}/restore, restores a deleted book into the library of the userID
+func (h *Handler) RestoreBook(c echo.Context) (err error) {
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+	isbn := c.Param("isbn")
+	userId := c.Param("userId")
+
+	book, err := h.dbSvc.Restore(c.Request().Context(), isbn, userId)
+	if err != nil {
+		zap.L().Error(err.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	// Return ok
+	return c.JSON(http.StatusOK, present(c, book))
+}
+
+// GetStats resolves GET /{userID}/stats, aggregates the books in the library of the userID
+func (h *Handler) GetStats(c echo.Context) (err error) {
+	if err = validate(paramFields(c)...); err != nil {
+		// Invalid path parameter
+		zap.L().Error(constant.ErrInvalidRequest.Error(), zap.Error(err))
+		return errResponse(c, err)
 	}
-	book, err := h.dbSvc.Upsert(c.Request().Context(), r.ISBN, r.Title, r.Author, r.ImageURL, r.SmallImageURL, r.Publisher, userId, r.Description, r.Categories, r.Language, r.Source, r.PublicationYear, r.Status, r.PageCount)
+	userId := c.Param("userId")
 
+	stats, err := h.dbSvc.Stats(c.Request().Context(), userId)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, presenter.ErrResp(reqID, err))
-	}
-
-	return c.JSON(http.StatusOK, &presenter.Book{
-		ISBN:            book.ISBN,
-		Title:           book.Title,
-		Author:          book.Authors,
-		ImageURL:        book.ImageURL,
-		SmallImageURL:   book.SmallImageURL,
-		Publisher:       book.Publisher,
-		Description:     book.Description,
-		PageCount:       book.PageCount,
-		Categories:      book.Categories,
-		Language:        book.Language,
-		PublicationYear: book.PublicationYear,
-		UserID:          book.UserID,
-		Status:          book.Status,
-		Source:          book.Source,
-	})
+		zap.L().Error(err.Error(), zap.Error(err))
+		return errResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, mapStatsToPresenter(stats))
+}
+
+// GetProviders resolves GET /diagnostics/providers, returns the metadata providers and the state of their circuit breakers
+func (h *Handler) GetProviders(c echo.Context) (err error) {
+	return c.JSON(http.StatusOK, mapProvidersToPresenter(h.bookSvc.Providers()))
 }
 
 // Ping resolves GET /ping, returns "Pong", used for healthcheck.
@@ -188,6 +367,46 @@ func (h *Handler) Ping(c echo.Context) (err error) {
 	return c.String(http.StatusOK, "Pong")
 }
 
+// statusCodes maps the codes of domain errors to http status codes, other errors are internal server errors
+var statusCodes = map[string]int{
+	constant.CodeBookNotFound:          http.StatusNotFound,
+	constant.CodeInvalidRequest:        http.StatusBadRequest,
+	constant.CodeRetrievingBookDetails: http.StatusBadGateway,
+	constant.CodeDBUnavailable:         http.StatusServiceUnavailable,
+	constant.CodeTimeout:               http.StatusGatewayTimeout,
+	constant.CodeRequestCanceled:       statusClientClosedRequest,
+}
+
+// statusCode returns the http status code of the domain error wrapped by err
+func statusCode(err error) int {
+	if code, ok := statusCodes[constant.Code(err)]; ok {
+		return code
+	}
+	return http.StatusInternalServerError
+}
+
+// errResponse responds with the status code and error object of the domain error wrapped by err,
+// the error is an RFC 7807 problem details object when the client accepts it
+func errResponse(c echo.Context, err error) error {
+	reqID := c.Response().Header().Get(echo.HeaderXRequestID)
+	status := statusCode(err)
+	if accepts(c, mimeProblem) {
+		c.Response().Header().Set(echo.HeaderContentType, mimeProblem)
+		return c.JSON(status, presenter.ProblemResp(reqID, c.Request().URL.RequestURI(), status, err))
+	}
+	return c.JSON(status, presenter.ErrResp(reqID, err))
+}
+
+// accepts reports whether the Accept header of the request lists the media type mime
+func accepts(c echo.Context, mime string) bool {
+	for _, a := range strings.Split(c.Request().Header.Get(echo.HeaderAccept), ",") {
+		if strings.TrimSpace(strings.SplitN(a, ";", 2)[0]) == mime {
+			return true
+		}
+	}
+	return false
+}
+
 func getLimitAndOffest(c echo.Context) (int64, int64, error) {
 	strlimit := c.QueryParam("limit")
 	stroffset := c.QueryParam("offset")
@@ -197,14 +416,139 @@ func getLimitAndOffest(c echo.Context) (int64, int64, error) {
 	if strlimit != "" {
 		limit, err = strconv.ParseInt(strlimit, 10, 64)
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, constant.InvalidField("limit", "must be an integer")
+		}
+		if limit < 0 {
+			return 0, 0, constant.InvalidField("limit", "must not be negative")
 		}
 	}
 	if stroffset != "" {
 		offset, err = strconv.ParseInt(stroffset, 10, 64)
 		if err != nil {
-			return 0, 0, err
+			return 0, 0, constant.InvalidField("offset", "must be an integer")
+		}
+		if offset < 0 {
+			return 0, 0, constant.InvalidField("offset", "must not be negative")
 		}
 	}
 	return limit, offset, nil
 }
+
+// present returns the response body of a book, in the shape of earlier versions in compat mode
+func present(c echo.Context, b *entities.Book) interface{} {
+	if isCompatComma(c) {
+		return mapBookToLegacyPresenter(b)
+	}
+	return mapBookToPresenter(b)
+}
+
+func mapBookToPresenter(b *entities.Book) *presenter.Book {
+	p := &presenter.Book{
+		ISBN:            b.ISBN,
+		Title:           b.Title,
+		Authors:         b.Authors,
+		ImageURL:        b.ImageURL,
+		SmallImageURL:   b.SmallImageURL,
+		Publisher:       b.Publisher,
+		Description:     b.Description,
+		PageCount:       b.PageCount,
+		Categories:      b.Categories,
+		Language:        b.Language,
+		PublicationYear: b.PublicationYear,
+		UserID:          b.UserID,
+		Status:          b.Status.String(),
+		Source:          b.Source,
+		Binding:         b.Binding,
+		Provenance:      b.Provenance,
+		StartedAt:       b.StartedAt,
+		FinishedAt:      b.FinishedAt,
+		CurrentPage:     b.CurrentPage,
+		DeletedAt:       b.DeletedAt,
+	}
+	if r := b.Review; r != nil {
+		p.Rating, p.Review = r.Rating.Stars(), r.Text
+		p.ReviewCreatedAt, p.ReviewUpdatedAt = &r.CreatedAt, &r.UpdatedAt
+	}
+	if pr := b.Progress; pr != nil {
+		p.PercentComplete, p.EstimatedFinish = &pr.Percent, pr.EstimatedFinish
+	}
+	return p
+}
+
+func mapStatsToPresenter(s *entities.Stats) *presenter.Stats {
+	p := &presenter.Stats{
+		Statuses:         map[string]int64{},
+		PagesPerMonth:    []presenter.MonthPages{},
+		FinishedPerYear:  []presenter.YearBooks{},
+		PublicationYears: []presenter.YearBooks{},
+		TopAuthors:       mapNameCounts(s.TopAuthors),
+		TopCategories:    mapNameCounts(s.TopCategories),
+		TopPublishers:    mapNameCounts(s.TopPublishers),
+		RatedBooks:       s.Rated,
+	}
+	// The average of half stars is given in stars to two decimals
+	p.AverageRating = math.Round(s.AverageRating/2*100) / 100
+	for _, st := range entities.Statuses {
+		p.Statuses[st.String()] = 0
+	}
+	for _, c := range s.Statuses {
+		p.Statuses[c.Status.String()] = c.Count
+	}
+	for _, c := range s.PagesPerMonth {
+		p.PagesPerMonth = append(p.PagesPerMonth, presenter.MonthPages{Month: c.Period, Pages: c.Count})
+	}
+	for _, c := range s.FinishedPerYear {
+		// Years are formatted by the database
+		year, _ := strconv.ParseInt(c.Period, 10, 64)
+		p.FinishedPerYear = append(p.FinishedPerYear, presenter.YearBooks{Year: year, Books: c.Count})
+	}
+	for _, c := range s.PublicationYears {
+		p.PublicationYears = append(p.PublicationYears, presenter.YearBooks{Year: c.Year, Books: c.Count})
+	}
+	return p
+}
+
+func mapNameCounts(counts []entities.NameCount) []presenter.NameBooks {
+	res := []presenter.NameBooks{}
+	for _, c := range counts {
+		res = append(res, presenter.NameBooks{Name: c.Name, Books: c.Count})
+	}
+	return res
+}
+
+func mapProvidersToPresenter(statuses []*entities.ProviderStatus) *presenter.Providers {
+	p := &presenter.Providers{Providers: []presenter.ProviderStatus{}}
+	for _, s := range statuses {
+		ps := presenter.ProviderStatus{Name: s.Name, Policy: s.Policy, Retries: s.Retries, State: string(s.State), Failures: s.Failures, OpenUntil: s.OpenUntil}
+		if s.Timeout > 0 {
+			ps.Timeout = s.Timeout.String()
+		}
+		p.Providers = append(p.Providers, ps)
+	}
+	return p
+}
+
+func mapBookToLegacyPresenter(b *entities.Book) *presenter.LegacyBook {
+	p := &presenter.LegacyBook{
+		ISBN:            b.ISBN,
+		Title:           b.Title,
+		Author:          strings.Join(b.Authors, ", "),
+		ImageURL:        b.ImageURL,
+		SmallImageURL:   b.SmallImageURL,
+		Publisher:       b.Publisher,
+		Description:     b.Description,
+		PageCount:       b.PageCount,
+		Categories:      strings.Join(b.Categories, ", "),
+		Language:        b.Language,
+		PublicationYear: b.PublicationYear,
+		UserID:          b.UserID,
+		Status:          int64(b.Status),
+		Source:          b.Source,
+		DeletedAt:       b.DeletedAt,
+	}
+	if b.Review != nil {
+		// Earlier versions sent the rating as averageRating
+		p.AverageRating = b.Review.Rating.Stars()
+	}
+	return p
+}
@@ -2,17 +2,21 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/abx123/library/constant"
 	"github.com/abx123/library/entities"
+	"github.com/abx123/library/handler/presenter"
 	"github.com/abx123/library/services/mocks"
 )
 
@@ -24,6 +28,7 @@ func TestGetBook(t *testing.T) {
 		err      error
 		expRes   *entities.Book
 		httpCode int
+		expCode  string
 	}
 	testCases := []testCase{
 		{
@@ -42,6 +47,46 @@ func TestGetBook(t *testing.T) {
 			err:      fmt.Errorf("mock error"),
 			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774",
 			httpCode: http.StatusInternalServerError,
+			expCode:  constant.CodeInternal,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "invalid isbn",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562775",
+			httpCode: http.StatusBadRequest,
+			expCode:  constant.CodeInvalidRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found",
+			err:      constant.ErrBookNotFound,
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774",
+			httpCode: http.StatusNotFound,
+			expCode:  constant.CodeBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "database unavailable",
+			err:      constant.ErrDBUnavailable,
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774",
+			httpCode: http.StatusServiceUnavailable,
+			expCode:  constant.CodeDBUnavailable,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc timed out",
+			err:      constant.ErrTimeout,
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774",
+			httpCode: http.StatusGatewayTimeout,
+			expCode:  constant.CodeTimeout,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "request canceled",
+			err:      constant.ErrRequestCanceled,
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774",
+			httpCode: statusClientClosedRequest,
+			expCode:  constant.CodeRequestCanceled,
 		},
 	}
 
@@ -56,7 +101,10 @@ func TestGetBook(t *testing.T) {
 		r := echo.New()
 		r.GET("/:userId/book/:isbn", h.GetBook)
 		r.ServeHTTP(w, req)
-		assert.Equal(t, v.httpCode, w.Code)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.expCode != "" {
+			assert.Equal(t, v.expCode, errorCode(t, w), v.desc)
+		}
 	}
 }
 
@@ -68,6 +116,7 @@ func TestGetNewBook(t *testing.T) {
 		err      error
 		expRes   *entities.Book
 		httpCode int
+		expCode  string
 	}
 	testCases := []testCase{
 		{
@@ -80,11 +129,25 @@ func TestGetNewBook(t *testing.T) {
 			},
 			httpCode: http.StatusOK,
 		},
+		{
+			name: "Happy Case",
+			desc: "provenance of the fields",
+			url:  "http://localhost:1323/book/9780751562774",
+			expRes: &entities.Book{
+				ISBN:        "9780751562774",
+				Title:       "Lethal White",
+				Description: "dummy description",
+				Source:      "google",
+				Provenance:  map[string]string{"isbn": "google", "title": "google", "description": "openlibrary"},
+			},
+			httpCode: http.StatusOK,
+		},
 		{
 			name:     "Sad Case",
 			desc:     "invalid request param",
 			url:      "http://localhost:1323/book/97807dsa51562774",
 			httpCode: http.StatusBadRequest,
+			expCode:  constant.CodeInvalidRequest,
 		},
 		{
 			name:     "Sad Case",
@@ -92,6 +155,23 @@ func TestGetNewBook(t *testing.T) {
 			url:      "http://localhost:1323/book/9780751562774",
 			err:      fmt.Errorf("mock error"),
 			httpCode: http.StatusInternalServerError,
+			expCode:  constant.CodeInternal,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "providers return error",
+			url:      "http://localhost:1323/book/9780751562774",
+			err:      constant.ErrRetrievingBookDetails,
+			httpCode: http.StatusBadGateway,
+			expCode:  constant.CodeRetrievingBookDetails,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc rejects isbn",
+			url:      "http://localhost:1323/book/9780751562774",
+			err:      fmt.Errorf("%w: isbn 9780751562774 is not valid", constant.ErrInvalidRequest),
+			httpCode: http.StatusBadRequest,
+			expCode:  constant.CodeInvalidRequest,
 		},
 	}
 	for _, v := range testCases {
@@ -104,7 +184,15 @@ func TestGetNewBook(t *testing.T) {
 		r := echo.New()
 		r.GET("/book/:isbn", h.GetNewBook)
 		r.ServeHTTP(w, req)
-		assert.Equal(t, v.httpCode, w.Code)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.expCode != "" {
+			assert.Equal(t, v.expCode, errorCode(t, w), v.desc)
+		}
+		if v.expRes != nil {
+			res := presenter.Book{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), v.desc)
+			assert.Equal(t, v.expRes.Provenance, res.Provenance, v.desc)
+		}
 	}
 }
 
@@ -114,24 +202,99 @@ func TestListBook(t *testing.T) {
 		desc     string
 		url      string
 		err      error
-		expRes   []*entities.Book
+		query    *entities.ListQuery
+		expRes   *entities.BookPage
 		httpCode int
 	}
+	status := entities.StatusToRead
+	yearFrom := int64(1990)
+	ratingFrom, ratingTo := entities.Rating(7), entities.MaxRating
 	testCases := []testCase{
 		{
 			name: "Happy Case",
 			desc: "all ok",
-			expRes: []*entities.Book{
-				{
-					ISBN: "9780751562774",
-				},
-				{
-					ISBN: "9780751562774",
+			expRes: &entities.BookPage{
+				Items: []*entities.Book{
+					{
+						ISBN: "9780751562774",
+					},
+					{
+						ISBN: "9780751562774",
+					},
 				},
 			},
 			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=10&offset=0",
 			httpCode: http.StatusOK,
 		},
+		{
+			name:   "Happy Case",
+			desc:   "filters and sort",
+			expRes: &entities.BookPage{},
+			url:    "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=2&author=Terry+Pratchett&category=Fantasy&language=en&publisher=Gollancz&yearFrom=1990&q=good+omens&sort=title,-publicationYear",
+			query: &entities.ListQuery{
+				UserID:    "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status:    &status,
+				Author:    "Terry Pratchett",
+				Category:  "Fantasy",
+				Language:  "en",
+				Publisher: "Gollancz",
+				YearFrom:  &yearFrom,
+				Q:         "good omens",
+				Sort:      []entities.SortField{{Field: "title"}, {Field: "publicationYear", Desc: true}},
+				Limit:     10,
+			},
+			httpCode: http.StatusOK,
+		},
+		{
+			name:   "Happy Case",
+			desc:   "status is named",
+			expRes: &entities.BookPage{},
+			url:    "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=to-read",
+			query: &entities.ListQuery{
+				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status: &status,
+				Limit:  10,
+			},
+			httpCode: http.StatusOK,
+		},
+		{
+			name:   "Happy Case",
+			desc:   "rating range in stars",
+			expRes: &entities.BookPage{},
+			url:    "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?ratingFrom=3.5&ratingTo=5&sort=-rating",
+			query: &entities.ListQuery{
+				UserID:     "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				RatingFrom: &ratingFrom,
+				RatingTo:   &ratingTo,
+				Sort:       []entities.SortField{{Field: "rating", Desc: true}},
+				Limit:      10,
+			},
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "rating is not in half stars",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?ratingFrom=3.2",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "unknown status",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=lent",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "unknown sort field",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?sort=isbn",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "invalid year",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?yearTo=last",
+			httpCode: http.StatusBadRequest,
+		},
 		{
 			name:     "Sad Case",
 			desc:     "invalid request param",
@@ -144,6 +307,18 @@ func TestListBook(t *testing.T) {
 			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=10&offset=a0",
 			httpCode: http.StatusBadRequest,
 		},
+		{
+			name:     "Sad Case",
+			desc:     "negative limit",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=-1&offset=0",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "negative offset",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=10&offset=-1",
+			httpCode: http.StatusBadRequest,
+		},
 		{
 			name:     "Sad Case",
 			desc:     "svc return err",
@@ -156,13 +331,165 @@ func TestListBook(t *testing.T) {
 		dbSvc := mocks.IdbService{}
 		bSvc := mocks.Ibooks{}
 		h := NewHandler(&dbSvc, &bSvc)
-		dbSvc.On("List", context.Background(), int64(10), int64(0), "8BeqLfieIiTOkruBBrQ6p8jOTsk2").Return(v.expRes, v.err)
+		if v.query == nil {
+			v.query = &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Limit: 10}
+		}
+		dbSvc.On("List", context.Background(), v.query).Return(v.expRes, v.err)
 		req := httptest.NewRequest(http.MethodGet, v.url, nil)
 		w := httptest.NewRecorder()
 		r := echo.New()
 		r.GET("/:userId/books", h.ListBook)
 		r.ServeHTTP(w, req)
-		assert.Equal(t, v.httpCode, w.Code)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+	}
+}
+
+func TestListBookPage(t *testing.T) {
+	sort := []entities.SortField{{Field: entities.SortTitle}}
+	cursor := entities.EncodeCursor(&entities.Cursor{Values: []interface{}{"Good Omens"}, ID: 1}, sort)
+	page := &entities.BookPage{
+		Items:      []*entities.Book{{BookID: 2, ISBN: "9780552124751", Title: "The Colour of Magic"}},
+		NextCursor: "next",
+		TotalCount: 3,
+	}
+	type testCase struct {
+		name     string
+		desc     string
+		url      string
+		accept   string
+		query    *entities.ListQuery
+		httpCode int
+		expType  string
+		expBody  string
+		expLink  string
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "envelope is negotiated",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?sort=title&limit=1&offset=1",
+			accept:   "application/vnd.library.page+json, application/json;q=0.5",
+			query:    &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Sort: sort, Limit: 1, Offset: 1},
+			httpCode: http.StatusOK,
+			expType:  "application/vnd.library.page+json",
+			expBody:  `{"items":[{"isbn":"9780552124751","title":"The Colour of Magic","status":"wishlist"}],"nextCursor":"next","totalCount":3}`,
+			expLink:  `</8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=1&sort=title>; rel="first", </8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?cursor=next&limit=1&sort=title>; rel="next"`,
+		},
+		{
+			name:     "Happy Case",
+			desc:     "array for earlier clients, continued from cursor",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?sort=title&limit=1&cursor=" + cursor,
+			query:    &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Sort: sort, Limit: 1, After: &entities.Cursor{Values: []interface{}{"Good Omens"}, ID: 1}},
+			httpCode: http.StatusOK,
+			expType:  echo.MIMEApplicationJSONCharsetUTF8,
+			expBody:  `[{"isbn":"9780552124751","title":"The Colour of Magic","status":"wishlist"}]`,
+			expLink:  `</8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=1&sort=title>; rel="first", </8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?cursor=next&limit=1&sort=title>; rel="next"`,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "cursor of another sort order",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?sort=-title&cursor=" + cursor,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "invalid cursor",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?cursor=abc",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "cursor and offset",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?sort=title&offset=1&cursor=" + cursor,
+			httpCode: http.StatusBadRequest,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("List", context.Background(), v.query).Return(page, nil)
+		req := httptest.NewRequest(http.MethodGet, v.url, nil)
+		req.Header.Set(echo.HeaderAccept, v.accept)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.GET("/:userId/books", h.ListBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.httpCode == http.StatusOK {
+			assert.Equal(t, v.expType, w.Header().Get(echo.HeaderContentType), v.desc)
+			assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+			assert.Equal(t, v.expLink, w.Header().Get("Link"), v.desc)
+			assert.Equal(t, "3", w.Header().Get("X-Total-Count"), v.desc)
+		}
+	}
+}
+
+func TestProblem(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		url      string
+		accept   string
+		err      error
+		httpCode int
+		expType  string
+		expBody  string
+	}
+	testCases := []testCase{
+		{
+			name:     "Sad Case",
+			desc:     "problem details are negotiated",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=1",
+			accept:   "application/problem+json, application/json",
+			err:      constant.ErrBookNotFound,
+			httpCode: http.StatusNotFound,
+			expType:  "application/problem+json",
+			expBody:  `{"type":"urn:library:problem:book_not_found","title":"book not found","status":404,"detail":"book not found","instance":"/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=1","requestID":"","code":"book_not_found"}`,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "invalid fields are listed",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=a",
+			accept:   "application/problem+json",
+			httpCode: http.StatusBadRequest,
+			expType:  "application/problem+json",
+			expBody:  `{"type":"urn:library:problem:invalid_request","title":"invalid request parameter","status":400,"detail":"invalid request parameter: limit must be an integer","instance":"/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=a","requestID":"","code":"invalid_request","errors":[{"field":"limit","message":"must be an integer"}]}`,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "negative limit",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=-5",
+			accept:   "application/problem+json",
+			httpCode: http.StatusBadRequest,
+			expType:  "application/problem+json",
+			expBody:  `{"type":"urn:library:problem:invalid_request","title":"invalid request parameter","status":400,"detail":"invalid request parameter: limit must not be negative","instance":"/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?limit=-5","requestID":"","code":"invalid_request","errors":[{"field":"limit","message":"must not be negative"}]}`,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "error object for earlier clients",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books?status=1",
+			err:      fmt.Errorf("mock error"),
+			httpCode: http.StatusInternalServerError,
+			expType:  echo.MIMEApplicationJSONCharsetUTF8,
+			expBody:  `{"requestID":"","code":"internal_error","message":"mock error"}`,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		status := entities.StatusOwned
+		dbSvc.On("List", context.Background(), &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Status: &status, Limit: 10}).Return(nil, v.err)
+		req := httptest.NewRequest(http.MethodGet, v.url, nil)
+		req.Header.Set(echo.HeaderAccept, v.accept)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.GET("/:userId/books", h.ListBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		assert.Equal(t, v.expType, w.Header().Get(echo.HeaderContentType), v.desc)
+		assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
 	}
 }
 
@@ -224,6 +551,30 @@ func TestUpsert(t *testing.T) {
 				ISBN: "isbn",
 			},
 		},
+		{
+			name: "Sad Case",
+			desc: "isbn with bad check digit",
+			form: map[string][]string{
+				"isbn":   {"9780751562775"},
+				"title":  {"The Secrets She Keeps"},
+				"author": {"Michael Robotham"},
+				"status": {"1"},
+				"source": {"goodreads"},
+			},
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name: "Sad Case",
+			desc: "title too long",
+			form: map[string][]string{
+				"isbn":   {"9780751562774"},
+				"title":  {strings.Repeat("秘", 256)},
+				"author": {"Michael Robotham"},
+				"status": {"1"},
+				"source": {"goodreads"},
+			},
+			httpCode: http.StatusBadRequest,
+		},
 		{
 			name: "Sad Case",
 			desc: "svc return error",
@@ -243,7 +594,7 @@ func TestUpsert(t *testing.T) {
 		dbSvc := mocks.IdbService{}
 		bSvc := mocks.Ibooks{}
 		h := NewHandler(&dbSvc, &bSvc)
-		dbSvc.On("Upsert", context.Background(), "9780751562774", "The Secrets She Keeps", "Michael Robotham", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", "", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", "", "", "", "goodreads", int64(0), int64(1), int64(0)).Return(v.expRes, v.err)
+		dbSvc.On("Upsert", context.Background(), &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps", Authors: []string{"Michael Robotham"}, ImageURL: "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Status: 1, Source: "goodreads"}).Return(v.expRes, v.err)
 		req := httptest.NewRequest(http.MethodPost, "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book", strings.NewReader(v.form.Encode()))
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		w := httptest.NewRecorder()
@@ -254,6 +605,580 @@ func TestUpsert(t *testing.T) {
 	}
 }
 
+func TestUpsertNames(t *testing.T) {
+	type testCase struct {
+		name        string
+		desc        string
+		url         string
+		contentType string
+		body        string
+		expAuthors  []string
+		expCategory []string
+		expBody     string
+	}
+	testCases := []testCase{
+		{
+			name:        "Happy Case",
+			desc:        "json arrays",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"isbn": "9780751562774", "authors": ["Pratchett, Terry", "Neil Gaiman"], "categories": ["Fantasy"]}`,
+			expAuthors:  []string{"Pratchett, Terry", "Neil Gaiman"},
+			expCategory: []string{"Fantasy"},
+			expBody:     `{"isbn":"9780751562774","authors":["Pratchett, Terry","Neil Gaiman"],"categories":["Fantasy"],"status":"wishlist"}`,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "repeated form values",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationForm,
+			body:        url.Values{"isbn": {"9780751562774"}, "authors": {"Pratchett, Terry", "Neil Gaiman"}, "categories": {"Fantasy"}}.Encode(),
+			expAuthors:  []string{"Pratchett, Terry", "Neil Gaiman"},
+			expCategory: []string{"Fantasy"},
+			expBody:     `{"isbn":"9780751562774","authors":["Pratchett, Terry","Neil Gaiman"],"categories":["Fantasy"],"status":"wishlist"}`,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "author of earlier versions is split on commas",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"isbn": "9780751562774", "author": "Terry Pratchett, Neil Gaiman", "categories": "Fantasy, Comedy"}`,
+			expAuthors:  []string{"Terry Pratchett", "Neil Gaiman"},
+			expCategory: []string{"Fantasy, Comedy"},
+			expBody:     `{"isbn":"9780751562774","authors":["Terry Pratchett","Neil Gaiman"],"categories":["Fantasy, Comedy"],"status":"wishlist"}`,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "compat mode splits and joins comma strings",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book?compat=comma",
+			contentType: echo.MIMEApplicationForm,
+			body:        url.Values{"isbn": {"9780751562774"}, "author": {"Terry Pratchett, Neil Gaiman"}, "categories": {"Fantasy, Comedy"}}.Encode(),
+			expAuthors:  []string{"Terry Pratchett", "Neil Gaiman"},
+			expCategory: []string{"Fantasy", "Comedy"},
+			expBody:     `{"isbn":"9780751562774","author":"Terry Pratchett, Neil Gaiman","categories":"Fantasy, Comedy"}`,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		b := &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Authors: v.expAuthors, Categories: v.expCategory}
+		dbSvc.On("Upsert", context.Background(), b).Return(&entities.Book{ISBN: "9780751562774", Authors: v.expAuthors, Categories: v.expCategory}, nil)
+		req := httptest.NewRequest(http.MethodPost, v.url, strings.NewReader(v.body))
+		req.Header.Set("Content-Type", v.contentType)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.POST("/:userId/book", h.UpsertBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, v.desc)
+		assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+	}
+}
+
+func TestUpsertReview(t *testing.T) {
+	reviewedAt := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name        string
+		desc        string
+		url         string
+		contentType string
+		body        string
+		expReview   *entities.Review
+		expBody     string
+		httpCode    int
+	}
+	testCases := []testCase{
+		{
+			name:        "Happy Case",
+			desc:        "rating in stars and review",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"isbn": "9780751562774", "rating": 4.5, "review": "Gripping"}`,
+			expReview:   &entities.Review{Rating: 9, Text: "Gripping"},
+			expBody:     `{"isbn":"9780751562774","status":"wishlist","rating":4.5,"review":"Gripping","reviewCreatedAt":"2021-06-01T00:00:00Z","reviewUpdatedAt":"2021-06-01T00:00:00Z"}`,
+			httpCode:    http.StatusOK,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "averageRating of earlier versions is rounded to half stars",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book?compat=comma",
+			contentType: echo.MIMEApplicationForm,
+			body:        url.Values{"isbn": {"9780751562774"}, "averageRating": {"3.87"}}.Encode(),
+			expReview:   &entities.Review{Rating: 8},
+			expBody:     `{"isbn":"9780751562774","averageRating":4}`,
+			httpCode:    http.StatusOK,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "no rating",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationForm,
+			body:        url.Values{"isbn": {"9780751562774"}, "averageRating": {"0"}}.Encode(),
+			expBody:     `{"isbn":"9780751562774","status":"wishlist"}`,
+			httpCode:    http.StatusOK,
+		},
+		{
+			name:        "Sad Case",
+			desc:        "rating is not in half stars",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationJSON,
+			body:        `{"isbn": "9780751562774", "rating": 4.3}`,
+			httpCode:    http.StatusBadRequest,
+		},
+		{
+			name:        "Sad Case",
+			desc:        "rating above five stars",
+			url:         "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book",
+			contentType: echo.MIMEApplicationForm,
+			body:        url.Values{"isbn": {"9780751562774"}, "rating": {"5.5"}}.Encode(),
+			httpCode:    http.StatusBadRequest,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		b := &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Review: v.expReview}
+		res := &entities.Book{ISBN: "9780751562774"}
+		if v.expReview != nil {
+			res.Review = &entities.Review{Rating: v.expReview.Rating, Text: v.expReview.Text, CreatedAt: reviewedAt, UpdatedAt: reviewedAt}
+		}
+		dbSvc.On("Upsert", context.Background(), b).Return(res, nil)
+		req := httptest.NewRequest(http.MethodPost, v.url, strings.NewReader(v.body))
+		req.Header.Set("Content-Type", v.contentType)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.POST("/:userId/book", h.UpsertBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.expBody != "" {
+			assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+		}
+	}
+}
+
+func TestPatchBook(t *testing.T) {
+	title := "The Secrets She Keeps"
+	publisher := ""
+	type testCase struct {
+		name     string
+		desc     string
+		body     string
+		err      error
+		expRes   *entities.Book
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			body: `{"title": "The Secrets She Keeps", "publisher": null}`,
+			expRes: &entities.Book{
+				ISBN:   "9780751562774",
+				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Title:  "The Secrets She Keeps",
+			},
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "patch is not a json object",
+			body:     `["title"]`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "second json value after the patch",
+			body:     `{"title": "The Secrets She Keeps"}{"status": "x"}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "garbage after the patch",
+			body:     `{"title": "The Secrets She Keeps"} garbage`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "immutable field",
+			body:     `{"isbn": "9781407243207"}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "language too long",
+			body:     `{"language": "` + strings.Repeat("e", 33) + `"}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found",
+			body:     `{"title": "The Secrets She Keeps", "publisher": null}`,
+			err:      constant.ErrBookNotFound,
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return error",
+			body:     `{"title": "The Secrets She Keeps", "publisher": null}`,
+			err:      fmt.Errorf("mock error"),
+			httpCode: http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Patch", context.Background(), "9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", &entities.BookPatch{Title: &title, Publisher: &publisher}).Return(v.expRes, v.err)
+		req := httptest.NewRequest(http.MethodPatch, "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774", strings.NewReader(v.body))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.PATCH("/:userId/book/:isbn", h.PatchBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code)
+	}
+}
+
+func TestProgressBook(t *testing.T) {
+	page := int64(80)
+	finish := time.Date(2021, 6, 9, 12, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name     string
+		desc     string
+		body     string
+		err      error
+		expRes   *entities.Book
+		expBody  string
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			body: `{"page": 80}`,
+			expRes: &entities.Book{
+				ISBN:        "9780751562774",
+				UserID:      "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status:      entities.StatusReading,
+				PageCount:   300,
+				CurrentPage: 80,
+				Progress:    &entities.Progress{Percent: 26.7, EstimatedFinish: &finish},
+			},
+			expBody:  `{"isbn":"9780751562774","pageCount":300,"userId":"8BeqLfieIiTOkruBBrQ6p8jOTsk2","status":"reading","currentPage":80,"percentComplete":26.7,"estimatedFinish":"2021-06-09T12:00:00Z"}`,
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "neither page nor percent",
+			body:     `{}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "both page and percent",
+			body:     `{"page": 80, "percent": 25}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "percent above 100",
+			body:     `{"percent": 101}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "session starts after it ends",
+			body:     `{"page": 80, "startedAt": "2021-06-01T21:00:00Z", "endedAt": "2021-06-01T20:00:00Z"}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "page is not a number",
+			body:     `{"page": "eighty"}`,
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "page exceeds the page count",
+			body:     `{"page": 80}`,
+			err:      constant.InvalidField("page", "exceeds the page count of 60"),
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found",
+			body:     `{"page": 80}`,
+			err:      constant.ErrBookNotFound,
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return error",
+			body:     `{"page": 80}`,
+			err:      fmt.Errorf("mock error"),
+			httpCode: http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Progress", context.Background(), "9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", &entities.ProgressUpdate{Page: &page}).Return(v.expRes, v.err)
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774/progress", strings.NewReader(v.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.POST("/:userId/book/:isbn/progress", h.ProgressBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.expBody != "" {
+			assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+		}
+	}
+}
+
+func TestDeleteBook(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		err      error
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "all ok",
+			httpCode: http.StatusNoContent,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found",
+			err:      constant.ErrBookNotFound,
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return error",
+			err:      fmt.Errorf("mock error"),
+			httpCode: http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Delete", context.Background(), "9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").Return(v.err)
+		req := httptest.NewRequest(http.MethodDelete, "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774", nil)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.DELETE("/:userId/book/:isbn", h.DeleteBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code)
+	}
+}
+
+func TestListTrash(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		url      string
+		err      error
+		expRes   []*entities.Book
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			expRes: []*entities.Book{
+				{
+					ISBN: "9780751562774",
+				},
+			},
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/trash?limit=10&offset=0",
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "invalid request param",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/trash?limit=1d0&offset=0",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "negative offset",
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/trash?offset=-10",
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return err",
+			err:      fmt.Errorf("mock error"),
+			url:      "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/trash",
+			httpCode: http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Trash", context.Background(), int64(10), int64(0), "8BeqLfieIiTOkruBBrQ6p8jOTsk2").Return(v.expRes, v.err)
+		req := httptest.NewRequest(http.MethodGet, v.url, nil)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.GET("/:userId/trash", h.ListTrash)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code)
+	}
+}
+
+func TestRestoreBook(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		err      error
+		expRes   *entities.Book
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			expRes: &entities.Book{
+				ISBN:   "9780751562774",
+				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+			},
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found in trash",
+			err:      constant.ErrBookNotFound,
+			httpCode: http.StatusNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return error",
+			err:      fmt.Errorf("mock error"),
+			httpCode: http.StatusInternalServerError,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Restore", context.Background(), "9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").Return(v.expRes, v.err)
+		req := httptest.NewRequest(http.MethodPost, "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/9780751562774/restore", nil)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.POST("/:userId/book/:isbn/restore", h.RestoreBook)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code)
+	}
+}
+
+func TestGetStats(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		userId   string
+		err      error
+		expRes   *entities.Stats
+		expBody  string
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			userId: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+			expRes: &entities.Stats{
+				Statuses:         []entities.StatusCount{{Status: entities.StatusReading, Count: 1}, {Status: entities.StatusRead, Count: 2}},
+				PagesPerMonth:    []entities.PeriodCount{{Period: "2021-06", Count: 120}},
+				FinishedPerYear:  []entities.PeriodCount{{Period: "2021", Count: 2}},
+				TopAuthors:       []entities.NameCount{{Name: "Terry Pratchett", Count: 3}},
+				TopPublishers:    []entities.NameCount{{Name: "Corgi", Count: 3}},
+				PublicationYears: []entities.YearCount{{Year: 1983, Count: 3}},
+				AverageRating:    22.0 / 3,
+				Rated:            3,
+			},
+			expBody:  `{"statuses":{"wishlist":0,"owned":0,"to-read":0,"reading":1,"read":2,"abandoned":0},"pagesPerMonth":[{"month":"2021-06","pages":120}],"finishedPerYear":[{"year":2021,"books":2}],"topAuthors":[{"name":"Terry Pratchett","books":3}],"topCategories":[],"topPublishers":[{"name":"Corgi","books":3}],"publicationYears":[{"year":1983,"books":3}],"averageRating":3.67,"ratedBooks":3}`,
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "user id too long",
+			userId:   strings.Repeat("u", entities.MaxUserIDLength+1),
+			httpCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "svc return error",
+			userId:   "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+			err:      constant.ErrDBUnavailable,
+			httpCode: http.StatusServiceUnavailable,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		dbSvc.On("Stats", context.Background(), v.userId).Return(v.expRes, v.err)
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:1323/"+v.userId+"/stats", nil)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.GET("/:userId/stats", h.GetStats)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		if v.expBody != "" {
+			assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+		}
+	}
+}
+
+func TestGetProviders(t *testing.T) {
+	openUntil := time.Date(2021, 6, 1, 12, 0, 30, 0, time.UTC)
+	type testCase struct {
+		name     string
+		desc     string
+		expRes   []*entities.ProviderStatus
+		expBody  string
+		httpCode int
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			expRes: []*entities.ProviderStatus{
+				{Name: "google", Timeout: 2 * time.Second, Policy: "next", Retries: 2, State: entities.CircuitClosed},
+				{Name: "crawler", Policy: "stop", State: entities.CircuitOpen, Failures: 5, OpenUntil: &openUntil},
+			},
+			expBody:  `{"providers":[{"name":"google","timeout":"2s","policy":"next","retries":2,"state":"closed","failures":0},{"name":"crawler","policy":"stop","retries":0,"state":"open","failures":5,"openUntil":"2021-06-01T12:00:30Z"}]}`,
+			httpCode: http.StatusOK,
+		},
+		{
+			name:     "Happy Case",
+			desc:     "no provider",
+			expBody:  `{"providers":[]}`,
+			httpCode: http.StatusOK,
+		},
+	}
+	for _, v := range testCases {
+		dbSvc := mocks.IdbService{}
+		bSvc := mocks.Ibooks{}
+		h := NewHandler(&dbSvc, &bSvc)
+		bSvc.On("Providers").Return(v.expRes)
+		req := httptest.NewRequest(http.MethodGet, "http://localhost:1323/diagnostics/providers", nil)
+		w := httptest.NewRecorder()
+		r := echo.New()
+		r.GET("/diagnostics/providers", h.GetProviders)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, v.httpCode, w.Code, v.desc)
+		assert.JSONEq(t, v.expBody, w.Body.String(), v.desc)
+	}
+}
+
 func TestPing(t *testing.T) {
 	dbSvc := mocks.IdbService{}
 	bSvc := mocks.Ibooks{}
@@ -265,3 +1190,10 @@ func TestPing(t *testing.T) {
 	r.ServeHTTP(w, req)
 	assert.Equal(t, 200, w.Code)
 }
+
+// errorCode returns the code of the error object in the body of w
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	res := &presenter.Error{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), res))
+	return res.Code
+}
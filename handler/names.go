@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/abx123/library/entities"
+)
+
+// compatComma is the value of the compat query parameter selecting the comma joined author and category strings of earlier versions
+const compatComma = "comma"
+
+// names is a list of author or category names, a JSON string is accepted as a list of a single value
+type names []string
+
+// UnmarshalJSON accepts an array of strings or a string
+func (n *names) UnmarshalJSON(b []byte) error {
+	if strings.HasPrefix(strings.TrimSpace(string(b)), "[") {
+		return json.Unmarshal(b, (*[]string)(n))
+	}
+	var s *string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	*n = nil
+	if s != nil {
+		*n = names{*s}
+	}
+	return nil
+}
+
+// isCompatComma reports whether the request uses the comma joined author and category strings of earlier versions
+func isCompatComma(c echo.Context) bool {
+	return c.QueryParam("compat") == compatComma
+}
+
+// splitNames returns the names in values, values are split on commas when comma is set
+func splitNames(values []string, comma bool) []string {
+	if !comma {
+		return entities.NormalizeNames(values)
+	}
+	return entities.SplitNames(strings.Join(values, ","))
+}
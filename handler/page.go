@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/abx123/library/entities"
+)
+
+const (
+	// mimePage is the media type of the paged envelope of a list, lists are plain JSON arrays unless it is accepted
+	mimePage = "application/vnd.library.page+json"
+	// headerTotalCount carries the total number of books on every page
+	headerTotalCount = "X-Total-Count"
+)
+
+// setPageHeaders sets the total count and the RFC 5988 Link header of the first and next pages of a list
+func setPageHeaders(c echo.Context, page *entities.BookPage) {
+	h := c.Response().Header()
+	h.Set(headerTotalCount, strconv.FormatInt(page.TotalCount, 10))
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, pageURL(c, ""))}
+	if page.NextCursor != "" {
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pageURL(c, page.NextCursor)))
+	}
+	h.Set("Link", strings.Join(links, ", "))
+}
+
+// pageURL returns the URL of the request starting at cursor, the first page when cursor is empty
+func pageURL(c echo.Context, cursor string) string {
+	u := *c.Request().URL
+	q := u.Query()
+	q.Del("offset")
+	q.Del("cursor")
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// patchBookRequest is a JSON merge patch document, members are named after postUpsertBookRequest
+type patchBookRequest map[string]json.RawMessage
+
+// toPatch converts the merge patch document into a BookPatch, a null member resets the field to its zero value.
+// Author and category values are split on commas when comma is set, the author member of earlier versions is always split.
+func (r patchBookRequest) toPatch(comma bool) (*entities.BookPatch, error) {
+	if _, ok := r["author"]; ok {
+		if _, ok = r["authors"]; ok {
+			return nil, fmt.Errorf("%w: fields author and authors cannot both be patched", constant.ErrInvalidRequest)
+		}
+	}
+	p := &entities.BookPatch{}
+	for k, v := range r {
+		var err error
+		switch k {
+		case "title":
+			p.Title, err = patchString(v)
+		case "authors":
+			p.Authors, err = patchNames(v, comma)
+		case "author":
+			p.Authors, err = patchNames(v, true)
+		case "imageUrl":
+			p.ImageURL, err = patchString(v)
+		case "smallImageUrl":
+			p.SmallImageURL, err = patchString(v)
+		case "publicationYear":
+			p.PublicationYear, err = patchInt(v)
+		case "publisher":
+			p.Publisher, err = patchString(v)
+		case "status":
+			p.Status, err = patchStatus(v)
+		case "description":
+			p.Description, err = patchString(v)
+		case "pageCount":
+			p.PageCount, err = patchInt(v)
+		case "categories":
+			p.Categories, err = patchNames(v, comma)
+		case "language":
+			p.Language, err = patchString(v)
+		case "source":
+			p.Source, err = patchString(v)
+		case "rating":
+			p.Rating, err = patchRating(v)
+		case "review":
+			p.Review, err = patchString(v)
+		default:
+			// Unknown or immutable field
+			return nil, constant.InvalidField(k, "cannot be patched")
+		}
+		if err != nil {
+			return nil, constant.InvalidField(k, err.Error())
+		}
+	}
+	return p, nil
+}
+
+func patchString(raw json.RawMessage) (*string, error) {
+	s := new(string)
+	if string(raw) == "null" {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func patchInt(raw json.RawMessage) (*int64, error) {
+	i := new(int64)
+	if string(raw) == "null" {
+		return i, nil
+	}
+	if err := json.Unmarshal(raw, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func patchStatus(raw json.RawMessage) (*entities.Status, error) {
+	s := new(entities.Status)
+	if string(raw) == "null" {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func patchRating(raw json.RawMessage) (*entities.Rating, error) {
+	r := new(entities.Rating)
+	if string(raw) == "null" {
+		return r, nil
+	}
+	if err := json.Unmarshal(raw, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func patchNames(raw json.RawMessage, comma bool) (*[]string, error) {
+	n := names{}
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return nil, err
+	}
+	res := splitNames(n, comma)
+	return &res, nil
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+func TestToPatch(t *testing.T) {
+	empty := ""
+	zero := int64(0)
+	title := "The Secrets She Keeps"
+	status := entities.StatusToRead
+	rating := entities.Rating(9)
+	unrated := entities.Rating(0)
+	review := "Gripping"
+	authors := []string{"Terry Pratchett", "Neil Gaiman"}
+	categories := []string{"Fantasy, Comedy"}
+	split := []string{"Fantasy", "Comedy"}
+	var none []string
+	type testCase struct {
+		name   string
+		desc   string
+		body   string
+		comma  bool
+		expRes *entities.BookPatch
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "fields are set",
+			body:   `{"title": "The Secrets She Keeps", "status": 2}`,
+			expRes: &entities.BookPatch{Title: &title, Status: &status},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "status is named",
+			body:   `{"status": "to-read"}`,
+			expRes: &entities.BookPatch{Status: &status},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "rating in stars and review",
+			body:   `{"rating": 4.5, "review": "Gripping"}`,
+			expRes: &entities.BookPatch{Rating: &rating, Review: &review},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "null removes rating",
+			body:   `{"rating": null}`,
+			expRes: &entities.BookPatch{Rating: &unrated},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "null resets field",
+			body:   `{"description": null, "pageCount": null}`,
+			expRes: &entities.BookPatch{Description: &empty, PageCount: &zero},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "empty patch",
+			body:   `{}`,
+			expRes: &entities.BookPatch{},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "names are arrays, a string is a single name",
+			body:   `{"authors": ["Terry Pratchett", "Neil Gaiman"], "categories": "Fantasy, Comedy"}`,
+			expRes: &entities.BookPatch{Authors: &authors, Categories: &categories},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "names are split on commas in compat mode",
+			body:   `{"author": "Terry Pratchett, Neil Gaiman", "categories": "Fantasy, Comedy"}`,
+			comma:  true,
+			expRes: &entities.BookPatch{Authors: &authors, Categories: &split},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "null clears names",
+			body:   `{"categories": null}`,
+			expRes: &entities.BookPatch{Categories: &none},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "author and authors",
+			body:   `{"author": "Terry Pratchett", "authors": ["Neil Gaiman"]}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "rating is not in half stars",
+			body:   `{"rating": 4.2}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown status",
+			body:   `{"status": "lent"}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "wrong type",
+			body:   `{"pageCount": "many"}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown field",
+			body:   `{"averageRating": 5}`,
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		r := patchBookRequest{}
+		assert.NoError(t, json.Unmarshal([]byte(v.body), &r))
+		actRes, actErr := r.toPatch(v.comma)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, errors.Is(actErr, constant.ErrInvalidRequest))
+	}
+}
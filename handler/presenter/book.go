@@ -1,19 +1,69 @@
 package presenter
 
+import "time"
+
 // Book defines book object
 type Book struct {
-	ISBN            string `json:"isbn,omitempty"`
-	Title           string `json:"title,omitempty"`
-	Author          string `json:"author,omitempty"`
-	ImageURL        string `json:"imageURL,omitempty"`
-	SmallImageURL   string `json:"smallImageURL,omitempty"`
-	Publisher       string `json:"publisher,omitempty"`
-	Description     string `json:"description,omitempty"`
-	PageCount       int64  `json:"pageCount,omitempty"`
-	Categories      string `json:"categories,omitempty"`
-	Language        string `json:"language,omitempty"`
-	PublicationYear int64  `json:"publicationYear,omitempty"`
-	UserID          string `json:"userId,omitempty"`
-	Status          int64  `json:"status,omitempty"`
-	Source          string `json:"source,omitempty"`
+	ISBN            string   `json:"isbn,omitempty"`
+	Title           string   `json:"title,omitempty"`
+	Authors         []string `json:"authors,omitempty"`
+	ImageURL        string   `json:"imageURL,omitempty"`
+	SmallImageURL   string   `json:"smallImageURL,omitempty"`
+	Publisher       string   `json:"publisher,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	PageCount       int64    `json:"pageCount,omitempty"`
+	Categories      []string `json:"categories,omitempty"`
+	Language        string   `json:"language,omitempty"`
+	PublicationYear int64    `json:"publicationYear,omitempty"`
+	UserID          string   `json:"userId,omitempty"`
+	Status          string   `json:"status"`
+	Source          string   `json:"source,omitempty"`
+	// Binding is only known of books looked up from providers
+	Binding string `json:"binding,omitempty"`
+	// Provenance is the source of every field of a book looked up from providers, omitted for books of a library
+	Provenance map[string]string `json:"provenance,omitempty"`
+
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+
+	// Rating is in stars, the review fields are omitted when the book is neither rated nor reviewed
+	Rating          float64    `json:"rating,omitempty"`
+	Review          string     `json:"review,omitempty"`
+	ReviewCreatedAt *time.Time `json:"reviewCreatedAt,omitempty"`
+	ReviewUpdatedAt *time.Time `json:"reviewUpdatedAt,omitempty"`
+
+	// PercentComplete is omitted when the page count is unknown, EstimatedFinish unless the book is being read at a known pace
+	CurrentPage     int64      `json:"currentPage,omitempty"`
+	PercentComplete *float64   `json:"percentComplete,omitempty"`
+	EstimatedFinish *time.Time `json:"estimatedFinish,omitempty"`
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// LegacyBook defines book object of earlier versions, authors and categories are comma joined strings
+type LegacyBook struct {
+	ISBN            string  `json:"isbn,omitempty"`
+	Title           string  `json:"title,omitempty"`
+	Author          string  `json:"author,omitempty"`
+	ImageURL        string  `json:"imageURL,omitempty"`
+	SmallImageURL   string  `json:"smallImageURL,omitempty"`
+	Publisher       string  `json:"publisher,omitempty"`
+	Description     string  `json:"description,omitempty"`
+	PageCount       int64   `json:"pageCount,omitempty"`
+	Categories      string  `json:"categories,omitempty"`
+	Language        string  `json:"language,omitempty"`
+	PublicationYear int64   `json:"publicationYear,omitempty"`
+	UserID          string  `json:"userId,omitempty"`
+	Status          int64   `json:"status,omitempty"`
+	AverageRating   float64 `json:"averageRating,omitempty"`
+	Source          string  `json:"source,omitempty"`
+
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// BookPage defines a page of books, items are Book or LegacyBook objects
+type BookPage struct {
+	Items      []interface{} `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+	TotalCount int64         `json:"totalCount"`
 }
@@ -1,15 +1,19 @@
 package presenter
 
+import "github.com/abx123/library/constant"
+
 // Error defines an error object
 type Error struct {
 	ReqId  string `json:"requestID"`
+	Code   string `json:"code"`
 	ErrMsg string `json:"message"`
 }
 
-// ErrResp wraps the requestID and error into a single Error object
+// ErrResp wraps the requestID and error into a single Error object, the code is taken from the wrapped domain error
 func ErrResp(reqID string, err error) *Error {
 	return &Error{
 		ReqId:  reqID,
+		Code:   constant.Code(err),
 		ErrMsg: err.Error(),
 	}
 }
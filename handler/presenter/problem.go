@@ -0,0 +1,53 @@
+package presenter
+
+import (
+	"errors"
+
+	"github.com/abx123/library/constant"
+)
+
+// problemTypePrefix prefixes the error code to form the type URI of a problem
+const problemTypePrefix = "urn:library:problem:"
+
+// Problem defines an RFC 7807 problem details object, requestID and code are extension members
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail"`
+	Instance string       `json:"instance"`
+	ReqId    string       `json:"requestID"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError defines an invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ProblemResp wraps the requestID and error into a single Problem object, instance is the URI of the request.
+// The title is the message of the wrapped domain error and the invalid fields of a validation error are listed in errors
+func ProblemResp(reqID, instance string, status int, err error) *Problem {
+	p := &Problem{
+		Type:     problemTypePrefix + constant.Code(err),
+		Title:    "internal error",
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: instance,
+		ReqId:    reqID,
+		Code:     constant.Code(err),
+	}
+	var e *constant.Error
+	if errors.As(err, &e) {
+		p.Title = e.Message
+	}
+	var v *constant.ValidationError
+	if errors.As(err, &v) {
+		for _, f := range v.Fields {
+			p.Errors = append(p.Errors, FieldError{Field: f.Field, Message: f.Message})
+		}
+	}
+	return p
+}
@@ -0,0 +1,19 @@
+package presenter
+
+import "time"
+
+// Providers defines the metadata providers books are looked up with, in lookup order
+type Providers struct {
+	Providers []ProviderStatus `json:"providers"`
+}
+
+// ProviderStatus defines the configuration and circuit breaker of a metadata provider, openUntil is only set while the circuit is open
+type ProviderStatus struct {
+	Name      string     `json:"name"`
+	Timeout   string     `json:"timeout,omitempty"`
+	Policy    string     `json:"policy"`
+	Retries   int        `json:"retries"`
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenUntil *time.Time `json:"openUntil,omitempty"`
+}
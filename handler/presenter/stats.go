@@ -0,0 +1,32 @@
+package presenter
+
+// Stats defines the statistics of a library, every status is counted and ratings are in stars
+type Stats struct {
+	Statuses         map[string]int64 `json:"statuses"`
+	PagesPerMonth    []MonthPages     `json:"pagesPerMonth"`
+	FinishedPerYear  []YearBooks      `json:"finishedPerYear"`
+	TopAuthors       []NameBooks      `json:"topAuthors"`
+	TopCategories    []NameBooks      `json:"topCategories"`
+	TopPublishers    []NameBooks      `json:"topPublishers"`
+	PublicationYears []YearBooks      `json:"publicationYears"`
+	AverageRating    float64          `json:"averageRating"`
+	RatedBooks       int64            `json:"ratedBooks"`
+}
+
+// MonthPages defines the number of pages read in a month, formatted as 2006-01
+type MonthPages struct {
+	Month string `json:"month"`
+	Pages int64  `json:"pages"`
+}
+
+// YearBooks defines a number of books in a year
+type YearBooks struct {
+	Year  int64 `json:"year"`
+	Books int64 `json:"books"`
+}
+
+// NameBooks defines the number of books of an author, category or publisher
+type NameBooks struct {
+	Name  string `json:"name"`
+	Books int64  `json:"books"`
+}
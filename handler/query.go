@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// listQuery parses the filters, sort order and page of GET /{userID}/books
+func listQuery(c echo.Context) (*entities.ListQuery, error) {
+	limit, offset, err := getLimitAndOffest(c)
+	if err != nil {
+		return nil, err
+	}
+	q := &entities.ListQuery{
+		UserID:    c.Param("userId"),
+		Author:    strings.TrimSpace(c.QueryParam("author")),
+		Category:  strings.TrimSpace(c.QueryParam("category")),
+		Language:  c.QueryParam("language"),
+		Publisher: c.QueryParam("publisher"),
+		Q:         c.QueryParam("q"),
+		Limit:     limit,
+		Offset:    offset,
+	}
+	if s := c.QueryParam("status"); s != "" {
+		status, err := entities.ParseStatus(s)
+		if err != nil {
+			return nil, constant.InvalidField("status", statusMessage)
+		}
+		q.Status = &status
+	}
+	if q.YearFrom, err = queryInt(c, "yearFrom"); err != nil {
+		return nil, err
+	}
+	if q.YearTo, err = queryInt(c, "yearTo"); err != nil {
+		return nil, err
+	}
+	if q.RatingFrom, err = queryRating(c, "ratingFrom"); err != nil {
+		return nil, err
+	}
+	if q.RatingTo, err = queryRating(c, "ratingTo"); err != nil {
+		return nil, err
+	}
+	if q.Sort, err = parseSort(c.QueryParam("sort")); err != nil {
+		return nil, err
+	}
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		if c.QueryParam("offset") != "" {
+			return nil, fmt.Errorf("%w: cursor and offset cannot be combined", constant.ErrInvalidRequest)
+		}
+		if q.After, err = entities.DecodeCursor(cursor, q.Sort); err != nil {
+			return nil, fmt.Errorf("%w: %s", constant.ErrInvalidRequest, err)
+		}
+	}
+	return q, nil
+}
+
+// queryInt returns the integer query parameter name, nil when it is not set
+func queryInt(c echo.Context, name string) (*int64, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, constant.InvalidField(name, "must be an integer")
+	}
+	return &i, nil
+}
+
+// queryRating returns the rating query parameter name in stars, nil when it is not set
+func queryRating(c echo.Context, name string) (*entities.Rating, error) {
+	s := c.QueryParam(name)
+	if s == "" {
+		return nil, nil
+	}
+	r := new(entities.Rating)
+	if err := r.UnmarshalText([]byte(s)); err != nil || !r.Valid() {
+		return nil, constant.InvalidField(name, "must be from 0 to 5 stars in steps of 0.5")
+	}
+	return r, nil
+}
+
+// parseSort parses a comma separated list of fields, a field prefixed with - is sorted in descending order
+func parseSort(s string) ([]entities.SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fields := []entities.SortField{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		sf := entities.SortField{Field: strings.TrimPrefix(f, "-"), Desc: strings.HasPrefix(f, "-")}
+		if !entities.SortFields[sf.Field] {
+			return nil, constant.InvalidField("sort", fmt.Sprintf("cannot sort by %q", f))
+		}
+		fields = append(fields, sf)
+	}
+	return fields, nil
+}
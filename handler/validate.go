@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+	"github.com/abx123/library/isbn"
+)
+
+// statusMessage lists the names of the statuses
+var statusMessage = func() string {
+	names := make([]string, len(entities.Statuses))
+	for i, s := range entities.Statuses {
+		names[i] = s.String()
+	}
+	return "must be one of " + strings.Join(names, ", ")
+}()
+
+// rule checks a value of a field, it returns the message of the field error or "" when the value is valid
+type rule[T any] func(v T) string
+
+// fieldCheck checks a field of a request, it returns the message of the first failed rule
+type fieldCheck struct {
+	name  string
+	check func() string
+}
+
+// field declares the rules of the field name, the field is skipped when value is nil
+func field[T any](name string, value *T, rules ...rule[T]) fieldCheck {
+	return fieldCheck{name: name, check: func() string {
+		if value == nil {
+			return ""
+		}
+		for _, r := range rules {
+			if msg := r(*value); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	}}
+}
+
+// validate runs every field check and returns a ValidationError listing all invalid fields, nil when every field is valid
+func validate(fields ...fieldCheck) error {
+	var errs []constant.FieldError
+	for _, f := range fields {
+		if msg := f.check(); msg != "" {
+			errs = append(errs, constant.FieldError{Field: f.name, Message: msg})
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &constant.ValidationError{Fields: errs}
+}
+
+// required rejects an empty string
+func required(s string) string {
+	if s == "" {
+		return "is required"
+	}
+	return ""
+}
+
+// maxLength rejects a string longer than max characters
+func maxLength(max int) rule[string] {
+	return func(s string) string {
+		if utf8.RuneCountInString(s) > max {
+			return fmt.Sprintf("exceeds %d characters", max)
+		}
+		return ""
+	}
+}
+
+// validISBN rejects a string that is not an ISBN-10 or ISBN-13 with a valid check digit, hyphens and spaces are ignored
+func validISBN(s string) string {
+	if !isbn.Valid(isbn.Normalize(s)) {
+		return "is not a valid ISBN-10 or ISBN-13"
+	}
+	return ""
+}
+
+// httpURL rejects a string that is not empty or an absolute http or https URL
+func httpURL(s string) string {
+	if s == "" {
+		return ""
+	}
+	if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an http or https URL"
+	}
+	return ""
+}
+
+// publicationYear rejects a year that is not unknown or between MinPublicationYear and next year
+func publicationYear(y int64) string {
+	max := int64(time.Now().Year() + 1)
+	if y != 0 && (y < entities.MinPublicationYear || y > max) {
+		return fmt.Sprintf("must be between %d and %d", entities.MinPublicationYear, max)
+	}
+	return ""
+}
+
+// nonNegative rejects a negative number
+func nonNegative(n int64) string {
+	if n < 0 {
+		return "must not be negative"
+	}
+	return ""
+}
+
+// status rejects an undefined status
+func status(s entities.Status) string {
+	if !s.Valid() {
+		return statusMessage
+	}
+	return ""
+}
+
+// ratingMessage describes a valid rating
+const ratingMessage = "must be from 0.5 to 5 stars in steps of 0.5"
+
+// validRating rejects a rating above five stars
+func validRating(r entities.Rating) string {
+	if !r.Valid() {
+		return ratingMessage
+	}
+	return ""
+}
+
+// percentage rejects a percentage below 0 or above 100
+func percentage(p float64) string {
+	if p < 0 || p > 100 {
+		return "must be between 0 and 100"
+	}
+	return ""
+}
+
+// notAfter rejects a time after end, or after now when end is nil
+func notAfter(end *time.Time) rule[time.Time] {
+	return func(t time.Time) string {
+		if end == nil && t.After(time.Now()) {
+			return "must not be in the future"
+		}
+		if end != nil && t.After(*end) {
+			return "must not be after endedAt"
+		}
+		return ""
+	}
+}
+
+// validNames rejects more than MaxNames names or a name longer than MaxNameLength
+func validNames(names []string) string {
+	if len(names) > entities.MaxNames {
+		return fmt.Sprintf("exceeds %d names", entities.MaxNames)
+	}
+	for _, n := range names {
+		if utf8.RuneCountInString(n) > entities.MaxNameLength {
+			return fmt.Sprintf("exceeds %d characters", entities.MaxNameLength)
+		}
+	}
+	return ""
+}
+
+// param returns a pointer to the path parameter name, nil when the route has no such parameter
+func param(c echo.Context, name string) *string {
+	for _, n := range c.ParamNames() {
+		if n == name {
+			v := c.Param(name)
+			return &v
+		}
+	}
+	return nil
+}
+
+// paramFields declares the rules of the path parameters of a request
+func paramFields(c echo.Context) []fieldCheck {
+	return []fieldCheck{
+		field("userId", param(c, "userId"), required, maxLength(entities.MaxUserIDLength)),
+		field("isbn", param(c, "isbn"), validISBN),
+	}
+}
+
+// bookFields declares the rules of the fields of a book
+func bookFields(b *entities.Book) []fieldCheck {
+	var rating *entities.Rating
+	var review *string
+	if b.Review != nil {
+		rating, review = &b.Review.Rating, &b.Review.Text
+	}
+	return []fieldCheck{
+		field("isbn", &b.ISBN, validISBN),
+		field("userId", &b.UserID, required, maxLength(entities.MaxUserIDLength)),
+		field("title", &b.Title, maxLength(entities.MaxTitleLength)),
+		field("authors", &b.Authors, validNames),
+		field("imageUrl", &b.ImageURL, maxLength(entities.MaxImageURLLength), httpURL),
+		field("smallImageUrl", &b.SmallImageURL, maxLength(entities.MaxImageURLLength), httpURL),
+		field("publicationYear", &b.PublicationYear, publicationYear),
+		field("publisher", &b.Publisher, maxLength(entities.MaxPublisherLength)),
+		field("status", &b.Status, status),
+		field("description", &b.Description, maxLength(entities.MaxDescriptionLength)),
+		field("pageCount", &b.PageCount, nonNegative),
+		field("categories", &b.Categories, validNames),
+		field("language", &b.Language, maxLength(entities.MaxLanguageLength)),
+		field("source", &b.Source, maxLength(entities.MaxSourceLength)),
+		field("rating", rating, validRating),
+		field("review", review, maxLength(entities.MaxReviewLength)),
+	}
+}
+
+// patchFields declares the rules of the fields of a patch, fields that are not patched are skipped
+func patchFields(p *entities.BookPatch) []fieldCheck {
+	return []fieldCheck{
+		field("title", p.Title, maxLength(entities.MaxTitleLength)),
+		field("authors", p.Authors, validNames),
+		field("imageUrl", p.ImageURL, maxLength(entities.MaxImageURLLength), httpURL),
+		field("smallImageUrl", p.SmallImageURL, maxLength(entities.MaxImageURLLength), httpURL),
+		field("publicationYear", p.PublicationYear, publicationYear),
+		field("publisher", p.Publisher, maxLength(entities.MaxPublisherLength)),
+		field("status", p.Status, status),
+		field("description", p.Description, maxLength(entities.MaxDescriptionLength)),
+		field("pageCount", p.PageCount, nonNegative),
+		field("categories", p.Categories, validNames),
+		field("language", p.Language, maxLength(entities.MaxLanguageLength)),
+		field("source", p.Source, maxLength(entities.MaxSourceLength)),
+		field("rating", p.Rating, validRating),
+		field("review", p.Review, maxLength(entities.MaxReviewLength)),
+	}
+}
+
+// progressFields declares the rules of the fields of a progress update, exactly one of page and percent is set
+func progressFields(u *entities.ProgressUpdate) []fieldCheck {
+	return []fieldCheck{
+		{name: "page", check: func() string {
+			if (u.Page == nil) == (u.Percent == nil) {
+				return "either page or percent is required"
+			}
+			return ""
+		}},
+		field("page", u.Page, nonNegative),
+		field("percent", u.Percent, percentage),
+		field("startedAt", u.StartedAt, notAfter(u.EndedAt)),
+	}
+}
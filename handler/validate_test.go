@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+func TestValidateBook(t *testing.T) {
+	userID := "8BeqLfieIiTOkruBBrQ6p8jOTsk2"
+	type testCase struct {
+		name      string
+		desc      string
+		book      *entities.Book
+		expFields []string
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			book: &entities.Book{ISBN: "9780751562774", UserID: userID, Title: "The Secrets She Keeps", Authors: []string{"Michael Robotham"}, ImageURL: "https://images.isbndb.com/covers/27/74/9780751562774.jpg", PublicationYear: 2017, Status: 1, PageCount: 432},
+		},
+		{
+			name: "Happy Case",
+			desc: "isbn-10 with check digit X",
+			book: &entities.Book{ISBN: "080442957X", UserID: userID},
+		},
+		{
+			name: "Happy Case",
+			desc: "hyphenated isbn-13",
+			book: &entities.Book{ISBN: "978-0-7515-6277-4", UserID: userID},
+		},
+		{
+			name: "Happy Case",
+			desc: "limits are counted in characters, not bytes",
+			book: &entities.Book{ISBN: "9780751562774", UserID: userID, Title: strings.Repeat("秘", entities.MaxTitleLength)},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "title too long",
+			book:      &entities.Book{ISBN: "9780751562774", UserID: userID, Title: strings.Repeat("秘", entities.MaxTitleLength+1)},
+			expFields: []string{"title"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "userId too long",
+			book:      &entities.Book{ISBN: "9780751562774", UserID: strings.Repeat("u", entities.MaxUserIDLength+1)},
+			expFields: []string{"userId"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "author name too long",
+			book:      &entities.Book{ISBN: "9780751562774", UserID: userID, Authors: []string{"Michael Robotham", strings.Repeat("a", entities.MaxNameLength+1)}},
+			expFields: []string{"authors"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "too many categories",
+			book:      &entities.Book{ISBN: "9780751562774", UserID: userID, Categories: make([]string, entities.MaxNames+1)},
+			expFields: []string{"categories"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "isbn with bad check digit",
+			book:      &entities.Book{ISBN: "9780751562775", UserID: userID},
+			expFields: []string{"isbn"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "every invalid field is reported",
+			book:      &entities.Book{ISBN: "978ddsa0751562774", ImageURL: "images/9780751562774.jpg", SmallImageURL: "ftp://images.isbndb.com/9780751562774.jpg", PublicationYear: 99999, Status: entities.StatusAbandoned + 1, PageCount: -1},
+			expFields: []string{"isbn", "userId", "imageUrl", "smallImageUrl", "publicationYear", "status", "pageCount"},
+		},
+	}
+	for _, v := range testCases {
+		actErr := validate(bookFields(v.book)...)
+		assert.Equal(t, v.expFields, invalidFields(actErr), v.desc)
+		if v.expFields != nil {
+			assert.True(t, errors.Is(actErr, constant.ErrInvalidRequest), v.desc)
+		}
+	}
+}
+
+func TestValidatePatch(t *testing.T) {
+	ok := "The Secrets She Keeps"
+	long := strings.Repeat("d", entities.MaxDescriptionLength+1)
+	authors := []string{strings.Repeat("a", entities.MaxNameLength+1)}
+	url := "9780751562774.jpg"
+	negative := int64(-1)
+	tooHigh := entities.MaxRating + 1
+	tooLong := strings.Repeat("r", entities.MaxReviewLength+1)
+	type testCase struct {
+		name      string
+		desc      string
+		patch     *entities.BookPatch
+		expFields []string
+	}
+	testCases := []testCase{
+		{
+			name:  "Happy Case",
+			desc:  "unset fields are skipped",
+			patch: &entities.BookPatch{Title: &ok},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "description too long",
+			patch:     &entities.BookPatch{Title: &ok, Description: &long},
+			expFields: []string{"description"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "author name too long",
+			patch:     &entities.BookPatch{Authors: &authors},
+			expFields: []string{"authors"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "relative image url and negative page count",
+			patch:     &entities.BookPatch{ImageURL: &url, PageCount: &negative},
+			expFields: []string{"imageUrl", "pageCount"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "rating above five stars and review too long",
+			patch:     &entities.BookPatch{Rating: &tooHigh, Review: &tooLong},
+			expFields: []string{"rating", "review"},
+		},
+	}
+	for _, v := range testCases {
+		actErr := validate(patchFields(v.patch)...)
+		assert.Equal(t, v.expFields, invalidFields(actErr), v.desc)
+	}
+}
+
+func TestValidateProgress(t *testing.T) {
+	page, negative := int64(80), int64(-1)
+	half, over := 50.0, 100.5
+	future := time.Now().Add(time.Hour)
+	startedAt := time.Date(2021, 6, 1, 21, 0, 0, 0, time.UTC)
+	endedAt := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name      string
+		desc      string
+		update    *entities.ProgressUpdate
+		expFields []string
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "page",
+			update: &entities.ProgressUpdate{Page: &page},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "percent with session times",
+			update: &entities.ProgressUpdate{Percent: &half, StartedAt: &endedAt, EndedAt: &startedAt},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "neither page nor percent",
+			update:    &entities.ProgressUpdate{},
+			expFields: []string{"page"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "both page and percent",
+			update:    &entities.ProgressUpdate{Page: &page, Percent: &half},
+			expFields: []string{"page"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "negative page",
+			update:    &entities.ProgressUpdate{Page: &negative},
+			expFields: []string{"page"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "percent above 100",
+			update:    &entities.ProgressUpdate{Percent: &over},
+			expFields: []string{"percent"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "session starts after it ends",
+			update:    &entities.ProgressUpdate{Page: &page, StartedAt: &startedAt, EndedAt: &endedAt},
+			expFields: []string{"startedAt"},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "session starts in the future",
+			update:    &entities.ProgressUpdate{Page: &page, StartedAt: &future},
+			expFields: []string{"startedAt"},
+		},
+	}
+	for _, v := range testCases {
+		actErr := validate(progressFields(v.update)...)
+		assert.Equal(t, v.expFields, invalidFields(actErr), v.desc)
+	}
+}
+
+func TestParamFields(t *testing.T) {
+	type testCase struct {
+		name      string
+		desc      string
+		route     string
+		url       string
+		expFields []string
+	}
+	testCases := []testCase{
+		{
+			name:  "Happy Case",
+			desc:  "all ok",
+			route: "/:userId/book/:isbn",
+			url:   "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/book/0751562777",
+		},
+		{
+			name:  "Happy Case",
+			desc:  "route without isbn",
+			route: "/:userId/books",
+			url:   "http://localhost:1323/8BeqLfieIiTOkruBBrQ6p8jOTsk2/books",
+		},
+		{
+			name:      "Sad Case",
+			desc:      "invalid userId and isbn",
+			route:     "/:userId/book/:isbn",
+			url:       "http://localhost:1323/" + strings.Repeat("u", entities.MaxUserIDLength+1) + "/book/0751562778",
+			expFields: []string{"userId", "isbn"},
+		},
+	}
+	for _, v := range testCases {
+		var actErr error
+		r := echo.New()
+		r.GET(v.route, func(c echo.Context) error {
+			actErr = validate(paramFields(c)...)
+			return nil
+		})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, v.url, nil))
+		assert.Equal(t, v.expFields, invalidFields(actErr), v.desc)
+	}
+}
+
+// invalidFields returns the names of the invalid fields of a ValidationError, nil when err is nil
+func invalidFields(err error) []string {
+	var v *constant.ValidationError
+	if !errors.As(err, &v) {
+		return nil
+	}
+	fields := []string{}
+	for _, f := range v.Fields {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
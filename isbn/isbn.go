@@ -0,0 +1,114 @@
+// Package isbn verifies International Standard Book Numbers and converts between ISBN-10 and ISBN-13
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid is returned for a string that is not an ISBN-10 or ISBN-13 with a valid check digit
+var ErrInvalid = errors.New("invalid isbn")
+
+// prefix978 is the EAN prefix of the ISBN-13 of every ISBN-10
+const prefix978 = "978"
+
+// separators removes the hyphens and spaces grouping the parts of an ISBN
+var separators = strings.NewReplacer("-", "", " ", "")
+
+// Normalize strips the hyphens and spaces of s and upper cases the check digit X of an ISBN-10
+func Normalize(s string) string {
+	return strings.ToUpper(separators.Replace(strings.TrimSpace(s)))
+}
+
+// Valid reports whether s is an ISBN-10 or ISBN-13 with a valid check digit, s has to be compact, without hyphens or spaces
+func Valid(s string) bool {
+	switch len(s) {
+	case 10:
+		return valid10(s)
+	case 13:
+		return valid13(s)
+	}
+	return false
+}
+
+// Canonical returns the ISBN-13 of s, s is an ISBN-10 or ISBN-13 that may be hyphenated
+func Canonical(s string) (string, error) {
+	s = Normalize(s)
+	if !Valid(s) {
+		return "", ErrInvalid
+	}
+	if len(s) == 13 {
+		return s, nil
+	}
+	s = prefix978 + s[:9]
+	return s + checkDigit13(s), nil
+}
+
+// To10 returns the ISBN-10 of s, s is an ISBN-10 or ISBN-13 that may be hyphenated.
+// ISBN-13s that do not start with 978 have no ISBN-10 and return ErrInvalid
+func To10(s string) (string, error) {
+	s = Normalize(s)
+	if !Valid(s) {
+		return "", ErrInvalid
+	}
+	if len(s) == 10 {
+		return s, nil
+	}
+	if !strings.HasPrefix(s, prefix978) {
+		return "", ErrInvalid
+	}
+	s = s[3:12]
+	return s + checkDigit10(s), nil
+}
+
+// valid10 reports whether the weighted sum of the digits of an ISBN-10 is a multiple of 11, the check digit X stands for 10
+func valid10(s string) bool {
+	if !digits(s[:9]) {
+		return false
+	}
+	return checkDigit10(s[:9]) == strings.ToUpper(s[9:])
+}
+
+// valid13 reports whether the digits of an ISBN-13 weighted alternately by 1 and 3 sum to a multiple of 10
+func valid13(s string) bool {
+	if !digits(s) {
+		return false
+	}
+	return checkDigit13(s[:12]) == s[12:]
+}
+
+// checkDigit10 returns the check digit of the first 9 digits of an ISBN-10
+func checkDigit10(s string) string {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(s[i]-'0')
+	}
+	d := (11 - sum%11) % 11
+	if d == 10 {
+		return "X"
+	}
+	return string(rune('0' + d))
+}
+
+// checkDigit13 returns the check digit of the first 12 digits of an ISBN-13
+func checkDigit13(s string) string {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(s[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+// digits reports whether s only has decimal digits
+func digits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
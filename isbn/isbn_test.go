@@ -0,0 +1,155 @@
+package isbn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		isbn   string
+		expRes bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "isbn-13",
+			isbn:   "9780751562774",
+			expRes: true,
+		},
+		{
+			name:   "Happy Case",
+			desc:   "isbn-10",
+			isbn:   "0751562777",
+			expRes: true,
+		},
+		{
+			name:   "Happy Case",
+			desc:   "isbn-10 with check digit X",
+			isbn:   "080442957X",
+			expRes: true,
+		},
+		{
+			name: "Sad Case",
+			desc: "isbn-13 with bad check digit",
+			isbn: "9780751562775",
+		},
+		{
+			name: "Sad Case",
+			desc: "isbn-10 with bad check digit",
+			isbn: "0751562778",
+		},
+		{
+			name: "Sad Case",
+			desc: "X is only a check digit",
+			isbn: "07515627X7",
+		},
+		{
+			name: "Sad Case",
+			desc: "not a number",
+			isbn: "978ddsa0751562774",
+		},
+		{
+			name: "Sad Case",
+			desc: "hyphenated",
+			isbn: "978-0751562774",
+		},
+		{
+			name: "Sad Case",
+			desc: "empty",
+		},
+	}
+	for _, v := range testCases {
+		assert.Equal(t, v.expRes, Valid(v.isbn), v.desc)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "080442957X", Normalize(" 0-8044-2957-x "))
+	assert.Equal(t, "9780751562774", Normalize("978 0 7515 6277 4"))
+}
+
+func TestCanonical(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		isbn   string
+		expRes string
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "isbn-10",
+			isbn:   "0751562777",
+			expRes: "9780751562774",
+		},
+		{
+			name:   "Happy Case",
+			desc:   "hyphenated isbn-10 with check digit X",
+			isbn:   "0-8044-2957-X",
+			expRes: "9780804429573",
+		},
+		{
+			name:   "Happy Case",
+			desc:   "hyphenated isbn-13",
+			isbn:   "978-0-7515-6277-4",
+			expRes: "9780751562774",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "bad check digit",
+			isbn:   "0751562778",
+			expErr: ErrInvalid,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := Canonical(v.isbn)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+	}
+}
+
+func TestTo10(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		isbn   string
+		expRes string
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "isbn-13",
+			isbn:   "9780751562774",
+			expRes: "0751562777",
+		},
+		{
+			name:   "Happy Case",
+			desc:   "isbn-13 of an isbn-10 with check digit X",
+			isbn:   "978-0-8044-2957-3",
+			expRes: "080442957X",
+		},
+		{
+			name:   "Happy Case",
+			desc:   "isbn-10",
+			isbn:   "080442957x",
+			expRes: "080442957X",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "979 isbn-13 has no isbn-10",
+			isbn:   "9791032305690",
+			expErr: ErrInvalid,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := To10(v.isbn)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+	}
+}
@@ -0,0 +1,286 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+// files holds the migrations of every dialect, named <version>_<name>.up.sql and <version>_<name>.down.sql
+//
+//go:embed mysql/*.sql sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+// Dialects supported by the migrations
+const (
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+	Postgres = "postgres"
+)
+
+// lockName identifies the migration lock so concurrent instances migrate one at a time
+const (
+	lockName = "schema_migrations"
+	lockKey  = 7283451
+)
+
+const createVersionTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version bigint NOT NULL PRIMARY KEY, name varchar(255) NOT NULL, appliedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP)"
+
+// Migration defines a versioned schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrator applies the migrations of a dialect to a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// New creates a new instance of Migrator for the database of the given dialect
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	m, err := Load(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		dialect:    dialect,
+		migrations: m,
+	}, nil
+}
+
+// Load returns the migrations of dialect ordered by version
+func Load(dialect string) ([]Migration, error) {
+	return load(files, dialect)
+}
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("unknown migration dialect %q: %w", dir, err)
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		var up bool
+		switch {
+		case strings.HasSuffix(e.Name(), ".up.sql"):
+			up = true
+		case strings.HasSuffix(e.Name(), ".down.sql"):
+		default:
+			continue
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(e.Name(), ".up.sql"), ".down.sql")
+		parts := strings.SplitN(base, "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %q", e.Name())
+		}
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", e.Name())
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+		if m.Name != parts[1] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, parts[1])
+		}
+		if up {
+			m.Up = string(b)
+		} else {
+			m.Down = string(b)
+		}
+	}
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the version of the newest migration
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the last applied migration, 0 when none is applied
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, createVersionTable); err != nil {
+		return 0, err
+	}
+	return version(ctx, conn)
+}
+
+// Up applies every pending migration in order, returns the number of migrations applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(conn *sql.Conn, current int64) error {
+		for _, mig := range m.migrations {
+			if mig.Version <= current {
+				continue
+			}
+			zap.L().Info("applying migration", zap.Int64("version", mig.Version), zap.String("name", mig.Name))
+			if err := m.apply(ctx, conn, mig.Up, m.rebind("INSERT INTO schema_migrations (version, name) VALUES (?, ?)"), mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("applying migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down reverts the last steps applied migrations in reverse order, returns the number of migrations reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.locked(ctx, func(conn *sql.Conn, current int64) error {
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if mig.Version > current {
+				continue
+			}
+			zap.L().Info("reverting migration", zap.Int64("version", mig.Version), zap.String("name", mig.Name))
+			if err := m.apply(ctx, conn, mig.Down, m.rebind("DELETE FROM schema_migrations WHERE version = ?"), mig.Version); err != nil {
+				return fmt.Errorf("reverting migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// locked runs fn on a single connection holding the migration lock, with the current version of the schema
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, current int64) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	defer unlock()
+	if _, err = conn.ExecContext(ctx, createVersionTable); err != nil {
+		return err
+	}
+	current, err := version(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, current)
+}
+
+// lock takes a session lock on MySQL and Postgres, SQLite serializes writers with its database lock
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	switch m.dialect {
+	case MySQL:
+		var ok sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 60)", lockName).Scan(&ok); err != nil {
+			return nil, err
+		}
+		if ok.Int64 != 1 {
+			return nil, fmt.Errorf("timed out waiting for lock %q", lockName)
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+		}, nil
+	case Postgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+			return nil, err
+		}
+		return func() {
+			conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockKey)
+		}, nil
+	}
+	return func() {}, nil
+}
+
+// apply executes the statements of a migration and records it in a single transaction.
+// MySQL commits DDL statements implicitly, a failed MySQL migration may have to be cleaned up by hand.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range statements(script) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Migrator) rebind(query string) string {
+	if m.dialect == Postgres {
+		return sqlx.Rebind(sqlx.DOLLAR, query)
+	}
+	return query
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int64, error) {
+	var v sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&v); err != nil {
+		return 0, err
+	}
+	return v.Int64, nil
+}
+
+// statements splits a script into statements terminated by a semicolon at the end of a line, comment lines are dropped
+func statements(script string) []string {
+	stmts := []string{}
+	var b strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSpace(b.String()))
+			b.Reset()
+		}
+	}
+	if s := strings.TrimSpace(b.String()); s != "" {
+		stmts = append(stmts, s)
+	}
+	return stmts
+}
@@ -0,0 +1,419 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "library.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n))
+	return n == 1
+}
+
+func TestLoad(t *testing.T) {
+	versions := map[string][]int64{}
+	for _, d := range []string{MySQL, SQLite, Postgres} {
+		m, err := Load(d)
+		require.NoError(t, err, d)
+		assert.NotEmpty(t, m, d)
+		for i, v := range m {
+			assert.NotEmpty(t, v.Up, d)
+			assert.NotEmpty(t, v.Down, d)
+			if i > 0 {
+				assert.Less(t, m[i-1].Version, v.Version, d)
+			}
+			versions[d] = append(versions[d], v.Version)
+		}
+	}
+	// Every dialect has the same schema history
+	assert.Equal(t, versions[MySQL], versions[SQLite])
+	assert.Equal(t, versions[MySQL], versions[Postgres])
+
+	_, err := Load("oracle")
+	assert.Error(t, err)
+}
+
+func TestLoadFS(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		fs     fstest.MapFS
+		expRes []Migration
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "ordered by version, other files are ignored",
+			fs: fstest.MapFS{
+				"d/0010_b.up.sql":   {Data: []byte("up b")},
+				"d/0010_b.down.sql": {Data: []byte("down b")},
+				"d/0002_a.up.sql":   {Data: []byte("up a")},
+				"d/0002_a.down.sql": {Data: []byte("down a")},
+				"d/readme.md":       {Data: []byte("readme")},
+			},
+			expRes: []Migration{
+				{Version: 2, Name: "a", Up: "up a", Down: "down a"},
+				{Version: 10, Name: "b", Up: "up b", Down: "down b"},
+			},
+		},
+		{
+			name: "Sad Case",
+			desc: "missing down",
+			fs: fstest.MapFS{
+				"d/0001_a.up.sql": {Data: []byte("up a")},
+			},
+			expErr: true,
+		},
+		{
+			name: "Sad Case",
+			desc: "invalid version",
+			fs: fstest.MapFS{
+				"d/first_a.up.sql":   {Data: []byte("up a")},
+				"d/first_a.down.sql": {Data: []byte("down a")},
+			},
+			expErr: true,
+		},
+		{
+			name: "Sad Case",
+			desc: "missing name",
+			fs: fstest.MapFS{
+				"d/0001.up.sql":   {Data: []byte("up a")},
+				"d/0001.down.sql": {Data: []byte("down a")},
+			},
+			expErr: true,
+		},
+		{
+			name: "Sad Case",
+			desc: "conflicting names",
+			fs: fstest.MapFS{
+				"d/0001_a.up.sql":   {Data: []byte("up a")},
+				"d/0001_b.down.sql": {Data: []byte("down b")},
+			},
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := load(v.fs, "d")
+		assert.Equal(t, v.expErr, actErr != nil, v.desc)
+		if !v.expErr {
+			assert.Equal(t, v.expRes, actRes, v.desc)
+		}
+	}
+}
+
+func TestStatements(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		script string
+		expRes []string
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "single statement without semicolon",
+			script: "DROP TABLE books",
+			expRes: []string{"DROP TABLE books"},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "multi line statements and comments",
+			script: "-- books\nCREATE TABLE books (\n  id int\n);\n\nCREATE INDEX title ON books (title);\n",
+			expRes: []string{"CREATE TABLE books (\n  id int\n);", "CREATE INDEX title ON books (title);"},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "empty",
+			script: "\n-- nothing\n",
+			expRes: []string{},
+		},
+	}
+	for _, v := range testCases {
+		assert.Equal(t, v.expRes, statements(v.script), v.desc)
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	m, err := New(db, SQLite)
+	require.NoError(t, err)
+
+	v, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), v)
+
+	n, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, len(m.migrations), n)
+	assert.True(t, tableExists(t, db, "books"))
+	v, err = m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, m.Latest(), v)
+
+	// Applied migrations are skipped
+	n, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = m.Down(ctx, len(m.migrations)+1)
+	require.NoError(t, err)
+	assert.Equal(t, len(m.migrations), n)
+	assert.False(t, tableExists(t, db, "books"))
+	v, err = m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), v)
+}
+
+func TestUpFailure(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	m := &Migrator{
+		db:      db,
+		dialect: SQLite,
+		migrations: []Migration{
+			{Version: 1, Name: "a", Up: "CREATE TABLE a (id integer);", Down: "DROP TABLE a;"},
+			{Version: 2, Name: "b", Up: "CREATE TABLE b (id integer);\nCREATE TABLE a (id integer);", Down: "DROP TABLE b;"},
+			{Version: 3, Name: "c", Up: "CREATE TABLE c (id integer);", Down: "DROP TABLE c;"},
+		},
+	}
+	n, err := m.Up(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+	v, err := m.Version(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	// The failed migration is rolled back as a whole and later migrations are not applied
+	assert.True(t, tableExists(t, db, "a"))
+	assert.False(t, tableExists(t, db, "b"))
+	assert.False(t, tableExists(t, db, "c"))
+
+	n, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.False(t, tableExists(t, db, "a"))
+}
+
+func TestNormalizeNames(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	all, err := Load(SQLite)
+	require.NoError(t, err)
+	m := &Migrator{db: db, dialect: SQLite, migrations: all[:2]}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO books (isbn, title, authors, publicationYear, userId, status, categories, source) VALUES ('9780751562774', 'Good Omens', 'Terry Pratchett, Neil Gaiman,, Terry Pratchett', 1990, 'u', 1, 'Fantasy,Comedy', 'goodreads'), ('9781407243207', 'The Colour of Magic', 'Terry Pratchett', 1983, 'u', 1, NULL, 'goodreads')")
+	require.NoError(t, err)
+
+	// The comma joined names are split into the join tables in order, repeated names are dropped
+	m.migrations = all[:3]
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	names := func(query string) []string {
+		rows, err := db.Query(query)
+		require.NoError(t, err)
+		defer rows.Close()
+		res := []string{}
+		for rows.Next() {
+			var isbn, name string
+			require.NoError(t, rows.Scan(&isbn, &name))
+			res = append(res, isbn+":"+name)
+		}
+		return res
+	}
+	authors := "SELECT b.isbn, a.name FROM books b JOIN book_authors j ON j.bookId = b.id JOIN authors a ON a.id = j.authorId ORDER BY b.isbn, j.position"
+	categories := "SELECT b.isbn, c.name FROM books b JOIN book_categories j ON j.bookId = b.id JOIN categories c ON c.id = j.categoryId ORDER BY b.isbn, j.position"
+	assert.Equal(t, []string{"9780751562774:Terry Pratchett", "9780751562774:Neil Gaiman", "9781407243207:Terry Pratchett"}, names(authors))
+	assert.Equal(t, []string{"9780751562774:Fantasy", "9780751562774:Comedy"}, names(categories))
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM authors").Scan(&n))
+	assert.Equal(t, 2, n)
+
+	// Reverting joins the names again
+	_, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, tableExists(t, db, "authors"))
+	assert.Equal(t, []string{"9780751562774:Terry Pratchett, Neil Gaiman", "9781407243207:Terry Pratchett"}, names("SELECT isbn, authors FROM books ORDER BY isbn"))
+	assert.Equal(t, []string{"9780751562774:Fantasy, Comedy"}, names("SELECT isbn, categories FROM books WHERE categories IS NOT NULL ORDER BY isbn"))
+}
+
+func TestCanonicalISBN(t *testing.T) {
+	ctx := context.Background()
+	db := openSQLite(t)
+	all, err := Load(SQLite)
+	require.NoError(t, err)
+	m := &Migrator{db: db, dialect: SQLite, migrations: all[:3]}
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO books (isbn, title, publicationYear, userId, status, source) VALUES ('0751562777', 'The Secrets She Keeps', 2017, 'u', 1, 'goodreads'), ('0-8044-2957-x', 'Good Omens', 1990, 'u', 1, 'goodreads'), ('978-1-4072-4320-7', 'The Colour of Magic', 1983, 'u', 1, 'goodreads'), ('0552124753', 'The Colour', 1983, 'v', 1, 'goodreads'), ('9780552124751', 'The Colour of Magic', 1983, 'v', 1, 'goodreads'), ('isbn', 'Unknown', 0, 'u', 1, 'goodreads'), ('0-306-40615-2', 'Tables', 1980, 'u', 1, 'goodreads'), ('0306406152', 'Tables of Integrals', 1980, 'u', 1, 'goodreads'), ('9781407243207', 'The Colour', 1983, 'v', 1, 'goodreads'), ('140724320X', 'The Colour of Magic', 1983, 'v', 1, 'goodreads')")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO authors (id, name) VALUES (1, 'Terry Pratchett'), (2, 'Terry Pratchet')")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO book_authors (bookId, authorId, position) VALUES (4, 2, 0), (5, 1, 0), (9, 2, 0), (10, 1, 0)")
+	require.NoError(t, err)
+
+	// ISBN-10s are converted and separators stripped, invalid ISBNs are left as they are.
+	// Of a book saved under several spellings of its ISBN the last one saved is kept with its authors under the ISBN-13
+	m.migrations = all[:4]
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	books := func() []string {
+		rows, err := db.Query("SELECT userId, isbn, title, COALESCE((SELECT GROUP_CONCAT(a.name) FROM book_authors j JOIN authors a ON a.id = j.authorId WHERE j.bookId = books.id), '') FROM books ORDER BY id")
+		require.NoError(t, err)
+		defer rows.Close()
+		res := []string{}
+		for rows.Next() {
+			var userID, isbn, title, authors string
+			require.NoError(t, rows.Scan(&userID, &isbn, &title, &authors))
+			res = append(res, userID+":"+isbn+":"+title+":"+authors)
+		}
+		return res
+	}
+	exp := []string{
+		"u:9780751562774:The Secrets She Keeps:",
+		"u:9780804429573:Good Omens:",
+		"u:9781407243207:The Colour of Magic:",
+		"v:9780552124751:The Colour of Magic:Terry Pratchett",
+		"u:isbn:Unknown:",
+		"u:9780306406157:Tables of Integrals:",
+		"v:9781407243207:The Colour of Magic:Terry Pratchett",
+	}
+	assert.Equal(t, exp, books())
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM book_authors").Scan(&n))
+	assert.Equal(t, 2, n)
+	assert.False(t, tableExists(t, db, "migration_isbns"))
+	assert.False(t, tableExists(t, db, "migration_duplicates"))
+
+	// Reverting keeps the canonical ISBN-13s
+	_, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, exp, books())
+}
+
+// openMySQL opens the MySQL database of TEST_DSN without any table, the test is skipped when it is not set
+func openMySQL(t *testing.T) *sql.DB {
+	dsn := strings.TrimPrefix(os.Getenv("TEST_DSN"), "mysql://")
+	if dsn == "" {
+		t.Skip("TEST_DSN is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	// Tests of the repositories share the database, the migrated schema is left as it was found
+	resetMySQL(t, db)
+	t.Cleanup(func() {
+		resetMySQL(t, db)
+		db.Close()
+	})
+	return db
+}
+
+// resetMySQL drops every table of the database of db
+func resetMySQL(t *testing.T, db *sql.DB) {
+	ctx := context.Background()
+	// The foreign key checks are a setting of the session, they are disabled on the connection dropping the tables
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	rows, err := conn.QueryContext(ctx, "SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE()")
+	require.NoError(t, err)
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		tables = append(tables, "`"+name+"`")
+	}
+	require.NoError(t, rows.Close())
+	if len(tables) == 0 {
+		return
+	}
+	_, err = conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0")
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "DROP TABLE "+strings.Join(tables, ", "))
+	require.NoError(t, err)
+	_, err = conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 1")
+	require.NoError(t, err)
+}
+
+// TestUpgradeBaseline applies every migration to the books table created by the scripts of sql/ before migrations existed
+func TestUpgradeBaseline(t *testing.T) {
+	ctx := context.Background()
+	type testCase struct {
+		name    string
+		desc    string
+		scripts []string
+	}
+	testCases := []testCase{
+		{
+			name:    "Happy Case",
+			desc:    "books.sql only",
+			scripts: []string{"books.sql"},
+		},
+		{
+			name:    "Happy Case",
+			desc:    "books.sql with the deletedAt column",
+			scripts: []string{"books.sql", "books_deleted_at.sql"},
+		},
+		{
+			name:    "Happy Case",
+			desc:    "books.sql with the deletedAt column and the unique key",
+			scripts: []string{"books.sql", "books_deleted_at.sql", "books_unique_user_isbn.sql"},
+		},
+	}
+	for _, v := range testCases {
+		db := openMySQL(t)
+		for _, script := range v.scripts {
+			b, err := os.ReadFile(filepath.Join("testdata", "baseline", script))
+			require.NoError(t, err, v.desc)
+			for _, stmt := range statements(string(b)) {
+				_, err = db.Exec(stmt)
+				require.NoError(t, err, v.desc)
+			}
+		}
+		// Books saved twice by the former upsert when the table has no unique key
+		insert := "INSERT INTO books (isbn, title, authors, publicationYear, userId, status, source) VALUES ('9780751562774', ?, 'Michael Robotham', 2017, 'u', 1, 'goodreads')"
+		_, err := db.Exec(insert, "The Secrets")
+		require.NoError(t, err, v.desc)
+		if _, err = db.Exec(insert, "The Secrets She Keeps"); err != nil {
+			// The unique key was already added
+			require.Contains(t, v.scripts, "books_unique_user_isbn.sql", v.desc)
+			_, err = db.Exec("UPDATE books SET title = 'The Secrets She Keeps'")
+			require.NoError(t, err, v.desc)
+		}
+
+		m, err := New(db, MySQL)
+		require.NoError(t, err, v.desc)
+		n, err := m.Up(ctx)
+		require.NoError(t, err, v.desc)
+		assert.Equal(t, len(m.migrations), n, v.desc)
+
+		// A single book is left, the last one saved, and the upsert has a key to conflict on
+		var title string
+		require.NoError(t, db.QueryRow("SELECT title FROM books WHERE userId = 'u' AND deletedAt IS NULL").Scan(&title), v.desc)
+		assert.Equal(t, "The Secrets She Keeps", title, v.desc)
+		_, err = db.Exec("INSERT INTO books (isbn, title, publicationYear, userId, status, source) VALUES ('9780751562774', 'The Secrets She Keeps', 2017, 'u', 1, 'goodreads')")
+		assert.Error(t, err, v.desc)
+		var isbnType string
+		require.NoError(t, db.QueryRow("SELECT COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'books' AND COLUMN_NAME = 'isbn'").Scan(&isbnType), v.desc)
+		assert.Equal(t, "varchar(20)", isbnType, v.desc)
+	}
+}
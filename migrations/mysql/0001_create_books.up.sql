@@ -0,0 +1,36 @@
+CREATE TABLE IF NOT EXISTS `books` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `isbn` varchar(20) NOT NULL,
+  `title` varchar(45) NOT NULL,
+  `authors` varchar(45) NOT NULL,
+  `imageUrl` text,
+  `smallImageUrl` text,
+  `publicationYear` int(4) NOT NULL,
+  `publisher` text,
+  `userId` varchar(45) NOT NULL,
+  `status` int(11) NOT NULL,
+  `description` text,
+  `pageCount` int(11) DEFAULT NULL,
+  `categories` text,
+  `language` text,
+  `source` text NOT NULL,
+  `deletedAt` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `userId_isbn` (`userId`, `isbn`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+-- A table created from the former sql/books.sql script is upgraded to the same schema, each step is skipped when it is already applied.
+-- MySQL has no ADD COLUMN IF NOT EXISTS, the statements are built from information_schema.
+SET @stmt = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE `books` ADD COLUMN `deletedAt` datetime DEFAULT NULL', 'SELECT 1')
+  FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'books' AND COLUMN_NAME = 'deletedAt');
+PREPARE stmt FROM @stmt;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+-- Rows saved twice by the former non atomic upsert keep the last one saved
+DELETE b1 FROM `books` b1 JOIN `books` b2 ON b1.userId = b2.userId AND b1.isbn = b2.isbn AND b1.id < b2.id;
+ALTER TABLE `books` MODIFY `isbn` varchar(20) NOT NULL;
+SET @stmt = (SELECT IF(COUNT(*) = 0, 'ALTER TABLE `books` ADD UNIQUE KEY `userId_isbn` (`userId`, `isbn`)', 'SELECT 1')
+  FROM information_schema.STATISTICS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'books' AND INDEX_NAME = 'userId_isbn');
+PREPARE stmt FROM @stmt;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,12 @@
+ALTER TABLE `books` DROP INDEX `userId_title`;
+ALTER TABLE `books`
+  MODIFY `title` varchar(45) NOT NULL,
+  MODIFY `authors` varchar(45) NOT NULL,
+  MODIFY `imageUrl` text,
+  MODIFY `smallImageUrl` text,
+  MODIFY `publisher` text,
+  MODIFY `description` text,
+  MODIFY `categories` text,
+  MODIFY `language` text,
+  MODIFY `source` text NOT NULL;
+ALTER TABLE `books` CONVERT TO CHARACTER SET latin1;
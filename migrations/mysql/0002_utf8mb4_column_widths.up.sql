@@ -0,0 +1,12 @@
+ALTER TABLE `books` CONVERT TO CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+ALTER TABLE `books`
+  MODIFY `title` varchar(255) NOT NULL,
+  MODIFY `authors` varchar(1024) NOT NULL,
+  MODIFY `imageUrl` varchar(2048),
+  MODIFY `smallImageUrl` varchar(2048),
+  MODIFY `publisher` varchar(255),
+  MODIFY `description` mediumtext,
+  MODIFY `categories` varchar(1024),
+  MODIFY `language` varchar(32),
+  MODIFY `source` varchar(255) NOT NULL,
+  ADD INDEX `userId_title` (`userId`, `title`);
@@ -0,0 +1,9 @@
+ALTER TABLE `books` ADD COLUMN `authors` varchar(1024) NOT NULL DEFAULT '' AFTER `title`, ADD COLUMN `categories` varchar(1024) AFTER `pageCount`;
+SET SESSION group_concat_max_len = 65535;
+UPDATE `books` b SET
+  b.authors = COALESCE((SELECT GROUP_CONCAT(a.name ORDER BY ba.position SEPARATOR ', ') FROM `book_authors` ba JOIN `authors` a ON a.id = ba.authorId WHERE ba.bookId = b.id), ''),
+  b.categories = (SELECT GROUP_CONCAT(c.name ORDER BY bc.position SEPARATOR ', ') FROM `book_categories` bc JOIN `categories` c ON c.id = bc.categoryId WHERE bc.bookId = b.id);
+DROP TABLE IF EXISTS `book_categories`;
+DROP TABLE IF EXISTS `book_authors`;
+DROP TABLE IF EXISTS `categories`;
+DROP TABLE IF EXISTS `authors`;
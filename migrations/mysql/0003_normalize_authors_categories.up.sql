@@ -0,0 +1,62 @@
+CREATE TABLE IF NOT EXISTS `authors` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) COLLATE utf8mb4_bin NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE TABLE IF NOT EXISTS `categories` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) COLLATE utf8mb4_bin NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE TABLE IF NOT EXISTS `book_authors` (
+  `bookId` int(11) NOT NULL,
+  `authorId` int(11) NOT NULL,
+  `position` int(11) NOT NULL,
+  PRIMARY KEY (`bookId`, `position`),
+  UNIQUE KEY `bookId_authorId` (`bookId`, `authorId`),
+  KEY `authorId` (`authorId`),
+  CONSTRAINT `book_authors_bookId` FOREIGN KEY (`bookId`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `book_authors_authorId` FOREIGN KEY (`authorId`) REFERENCES `authors` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+CREATE TABLE IF NOT EXISTS `book_categories` (
+  `bookId` int(11) NOT NULL,
+  `categoryId` int(11) NOT NULL,
+  `position` int(11) NOT NULL,
+  PRIMARY KEY (`bookId`, `position`),
+  UNIQUE KEY `bookId_categoryId` (`bookId`, `categoryId`),
+  KEY `categoryId` (`categoryId`),
+  CONSTRAINT `book_categories_bookId` FOREIGN KEY (`bookId`) REFERENCES `books` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `book_categories_categoryId` FOREIGN KEY (`categoryId`) REFERENCES `categories` (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Split the comma joined names of existing books, a book has at most 100 names
+CREATE TABLE `migration_numbers` (`n` int(11) NOT NULL PRIMARY KEY);
+INSERT INTO `migration_numbers` (`n`)
+  SELECT t.d * 10 + u.d + 1 FROM
+  (SELECT 0 AS d UNION ALL SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5 UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9) t,
+  (SELECT 0 AS d UNION ALL SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3 UNION ALL SELECT 4 UNION ALL SELECT 5 UNION ALL SELECT 6 UNION ALL SELECT 7 UNION ALL SELECT 8 UNION ALL SELECT 9) u;
+CREATE TABLE `migration_names` (
+  `kind` varchar(8) NOT NULL,
+  `bookId` int(11) NOT NULL,
+  `position` int(11) NOT NULL,
+  `name` varchar(255) COLLATE utf8mb4_bin NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+INSERT INTO `migration_names` (`kind`, `bookId`, `position`, `name`)
+  SELECT 'author', b.id, n.n, TRIM(SUBSTRING_INDEX(SUBSTRING_INDEX(b.authors, ',', n.n), ',', -1))
+  FROM `books` b JOIN `migration_numbers` n ON n.n <= CHAR_LENGTH(b.authors) - CHAR_LENGTH(REPLACE(b.authors, ',', '')) + 1;
+INSERT INTO `migration_names` (`kind`, `bookId`, `position`, `name`)
+  SELECT 'category', b.id, n.n, TRIM(SUBSTRING_INDEX(SUBSTRING_INDEX(b.categories, ',', n.n), ',', -1))
+  FROM `books` b JOIN `migration_numbers` n ON n.n <= CHAR_LENGTH(b.categories) - CHAR_LENGTH(REPLACE(b.categories, ',', '')) + 1;
+DELETE FROM `migration_names` WHERE `name` = '';
+INSERT IGNORE INTO `authors` (`name`) SELECT `name` FROM `migration_names` WHERE `kind` = 'author' ORDER BY `bookId`, `position`;
+INSERT IGNORE INTO `categories` (`name`) SELECT `name` FROM `migration_names` WHERE `kind` = 'category' ORDER BY `bookId`, `position`;
+INSERT IGNORE INTO `book_authors` (`bookId`, `authorId`, `position`)
+  SELECT n.bookId, a.id, n.position FROM `migration_names` n JOIN `authors` a ON a.name = n.name WHERE n.kind = 'author';
+INSERT IGNORE INTO `book_categories` (`bookId`, `categoryId`, `position`)
+  SELECT n.bookId, c.id, n.position FROM `migration_names` n JOIN `categories` c ON c.name = n.name WHERE n.kind = 'category';
+DROP TABLE `migration_names`;
+DROP TABLE `migration_numbers`;
+
+ALTER TABLE `books` DROP COLUMN `authors`, DROP COLUMN `categories`;
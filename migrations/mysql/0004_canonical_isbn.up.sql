@@ -0,0 +1,31 @@
+-- Store the canonical ISBN-13 of every book, hyphens and spaces are stripped and ISBN-10s are converted
+DROP TABLE IF EXISTS `migration_isbns`;
+CREATE TABLE `migration_isbns` (
+  `id` int(11) NOT NULL PRIMARY KEY,
+  `userId` varchar(45) NOT NULL,
+  `isbn` varchar(20) NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+INSERT INTO `migration_isbns` (`id`, `userId`, `isbn`)
+  SELECT id, userId, CASE WHEN CHAR_LENGTH(clean) = 10
+    THEN CONCAT('978', SUBSTRING(clean, 1, 9), (10 - (38 + 3 * CAST(SUBSTRING(clean, 1, 1) AS UNSIGNED) + CAST(SUBSTRING(clean, 2, 1) AS UNSIGNED) + 3 * CAST(SUBSTRING(clean, 3, 1) AS UNSIGNED) + CAST(SUBSTRING(clean, 4, 1) AS UNSIGNED) + 3 * CAST(SUBSTRING(clean, 5, 1) AS UNSIGNED) + CAST(SUBSTRING(clean, 6, 1) AS UNSIGNED) + 3 * CAST(SUBSTRING(clean, 7, 1) AS UNSIGNED) + CAST(SUBSTRING(clean, 8, 1) AS UNSIGNED) + 3 * CAST(SUBSTRING(clean, 9, 1) AS UNSIGNED)) % 10) % 10)
+    ELSE clean END
+  FROM (SELECT id, userId, isbn AS raw, UPPER(REPLACE(REPLACE(isbn, '-', ''), ' ', '')) AS clean FROM `books`) b
+  WHERE clean REGEXP '^[0-9]{9}[0-9X]$'
+    OR (clean REGEXP '^[0-9]{13}$' AND clean <> raw);
+-- A book saved under several spellings of its ISBN, ie: its ISBN-10 and its ISBN-13, is a single book.
+-- The last one saved is kept under the ISBN-13 with its authors and categories, the others are deleted
+DROP TABLE IF EXISTS `migration_duplicates`;
+CREATE TABLE `migration_duplicates` (
+  `id` int(11) NOT NULL PRIMARY KEY
+) ENGINE=InnoDB;
+INSERT INTO `migration_duplicates` (`id`)
+  SELECT b.id FROM `books` b JOIN `migration_isbns` m ON m.userId = b.userId AND m.isbn = b.isbn AND m.id > b.id
+  UNION SELECT m.id FROM `migration_isbns` m JOIN `books` b ON b.userId = m.userId AND b.isbn = m.isbn AND b.id > m.id
+  UNION SELECT m.id FROM `migration_isbns` m JOIN `migration_isbns` d ON d.userId = m.userId AND d.isbn = m.isbn AND d.id > m.id;
+DELETE FROM `book_authors` WHERE `bookId` IN (SELECT `id` FROM `migration_duplicates`);
+DELETE FROM `book_categories` WHERE `bookId` IN (SELECT `id` FROM `migration_duplicates`);
+DELETE FROM `books` WHERE `id` IN (SELECT `id` FROM `migration_duplicates`);
+DELETE FROM `migration_isbns` WHERE `id` IN (SELECT `id` FROM `migration_duplicates`);
+UPDATE `books` b JOIN `migration_isbns` m ON m.id = b.id SET b.isbn = m.isbn;
+DROP TABLE `migration_duplicates`;
+DROP TABLE `migration_isbns`;
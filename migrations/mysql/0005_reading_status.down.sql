@@ -0,0 +1,4 @@
+ALTER TABLE `books`
+  DROP INDEX `userId_status`,
+  DROP COLUMN `finishedAt`,
+  DROP COLUMN `startedAt`;
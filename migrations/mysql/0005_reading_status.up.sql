@@ -0,0 +1,4 @@
+ALTER TABLE `books`
+  ADD COLUMN `startedAt` datetime DEFAULT NULL AFTER `status`,
+  ADD COLUMN `finishedAt` datetime DEFAULT NULL AFTER `startedAt`,
+  ADD INDEX `userId_status` (`userId`, `status`);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS `reviews` (
+  `bookId` int(11) NOT NULL,
+  `rating` tinyint(4) NOT NULL DEFAULT 0,
+  `review` mediumtext NOT NULL,
+  `createdAt` datetime NOT NULL,
+  `updatedAt` datetime NOT NULL,
+  PRIMARY KEY (`bookId`),
+  KEY `rating` (`rating`),
+  CONSTRAINT `reviews_bookId` FOREIGN KEY (`bookId`) REFERENCES `books` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
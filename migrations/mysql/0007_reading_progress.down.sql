@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `reading_sessions`;
+ALTER TABLE `books` DROP COLUMN `currentPage`;
@@ -0,0 +1,13 @@
+ALTER TABLE `books`
+  ADD COLUMN `currentPage` int(11) NOT NULL DEFAULT 0 AFTER `pageCount`;
+CREATE TABLE IF NOT EXISTS `reading_sessions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `bookId` int(11) NOT NULL,
+  `startedAt` datetime NOT NULL,
+  `endedAt` datetime NOT NULL,
+  `startPage` int(11) NOT NULL,
+  `endPage` int(11) NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `bookId_endedAt` (`bookId`, `endedAt`),
+  CONSTRAINT `reading_sessions_bookId` FOREIGN KEY (`bookId`) REFERENCES `books` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
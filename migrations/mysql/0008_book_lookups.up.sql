@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS `book_lookups` (
+  `isbn` varchar(20) NOT NULL,
+  `found` tinyint(1) NOT NULL,
+  `book` mediumtext NOT NULL,
+  `expiresAt` datetime NOT NULL,
+  PRIMARY KEY (`isbn`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS books_userId_title;
+ALTER TABLE books
+  ALTER COLUMN title TYPE text,
+  ALTER COLUMN authors TYPE text,
+  ALTER COLUMN imageUrl TYPE text,
+  ALTER COLUMN smallImageUrl TYPE text,
+  ALTER COLUMN publisher TYPE text,
+  ALTER COLUMN categories TYPE text,
+  ALTER COLUMN language TYPE text,
+  ALTER COLUMN source TYPE text;
@@ -0,0 +1,11 @@
+-- Postgres databases are created with UTF8 encoding, column widths match MySQL
+ALTER TABLE books
+  ALTER COLUMN title TYPE varchar(255),
+  ALTER COLUMN authors TYPE varchar(1024),
+  ALTER COLUMN imageUrl TYPE varchar(2048),
+  ALTER COLUMN smallImageUrl TYPE varchar(2048),
+  ALTER COLUMN publisher TYPE varchar(255),
+  ALTER COLUMN categories TYPE varchar(1024),
+  ALTER COLUMN language TYPE varchar(32),
+  ALTER COLUMN source TYPE varchar(255);
+CREATE INDEX IF NOT EXISTS books_userId_title ON books (userId, title);
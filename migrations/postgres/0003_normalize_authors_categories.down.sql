@@ -0,0 +1,8 @@
+ALTER TABLE books ADD COLUMN authors varchar(1024) NOT NULL DEFAULT '', ADD COLUMN categories varchar(1024);
+UPDATE books b SET
+  authors = coalesce((SELECT string_agg(a.name, ', ' ORDER BY ba.position) FROM book_authors ba JOIN authors a ON a.id = ba.authorId WHERE ba.bookId = b.id), ''),
+  categories = (SELECT string_agg(c.name, ', ' ORDER BY bc.position) FROM book_categories bc JOIN categories c ON c.id = bc.categoryId WHERE bc.bookId = b.id);
+DROP TABLE IF EXISTS book_categories;
+DROP TABLE IF EXISTS book_authors;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS authors;
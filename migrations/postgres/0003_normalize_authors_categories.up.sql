@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS authors (
+  id BIGSERIAL PRIMARY KEY,
+  name varchar(255) NOT NULL,
+  UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS categories (
+  id BIGSERIAL PRIMARY KEY,
+  name varchar(255) NOT NULL,
+  UNIQUE (name)
+);
+CREATE TABLE IF NOT EXISTS book_authors (
+  bookId bigint NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+  authorId bigint NOT NULL REFERENCES authors (id),
+  position integer NOT NULL,
+  PRIMARY KEY (bookId, position),
+  UNIQUE (bookId, authorId)
+);
+CREATE INDEX IF NOT EXISTS book_authors_authorId ON book_authors (authorId);
+CREATE TABLE IF NOT EXISTS book_categories (
+  bookId bigint NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+  categoryId bigint NOT NULL REFERENCES categories (id),
+  position integer NOT NULL,
+  PRIMARY KEY (bookId, position),
+  UNIQUE (bookId, categoryId)
+);
+CREATE INDEX IF NOT EXISTS book_categories_categoryId ON book_categories (categoryId);
+
+-- Split the comma joined names of existing books
+CREATE TEMPORARY TABLE migration_names ON COMMIT DROP AS
+  SELECT 'author' AS kind, b.id AS bookId, s.position, trim(s.name) AS name
+  FROM books b, unnest(string_to_array(b.authors, ',')) WITH ORDINALITY AS s(name, position)
+  UNION ALL
+  SELECT 'category', b.id, s.position, trim(s.name)
+  FROM books b, unnest(string_to_array(b.categories, ',')) WITH ORDINALITY AS s(name, position);
+DELETE FROM migration_names WHERE name = '';
+INSERT INTO authors (name) SELECT name FROM migration_names WHERE kind = 'author' GROUP BY name ORDER BY min(bookId), min(position) ON CONFLICT (name) DO NOTHING;
+INSERT INTO categories (name) SELECT name FROM migration_names WHERE kind = 'category' GROUP BY name ORDER BY min(bookId), min(position) ON CONFLICT (name) DO NOTHING;
+INSERT INTO book_authors (bookId, authorId, position)
+  SELECT n.bookId, a.id, n.position FROM migration_names n JOIN authors a ON a.name = n.name WHERE n.kind = 'author'
+  ON CONFLICT DO NOTHING;
+INSERT INTO book_categories (bookId, categoryId, position)
+  SELECT n.bookId, c.id, n.position FROM migration_names n JOIN categories c ON c.name = n.name WHERE n.kind = 'category'
+  ON CONFLICT DO NOTHING;
+
+ALTER TABLE books DROP COLUMN authors, DROP COLUMN categories;
@@ -0,0 +1 @@
+-- The canonical ISBN-13s stay and the duplicates deleted are not restored, every endpoint accepts both forms
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS books_userId_status;
+ALTER TABLE books DROP COLUMN finishedAt, DROP COLUMN startedAt;
@@ -0,0 +1,2 @@
+ALTER TABLE books ADD COLUMN startedAt timestamp DEFAULT NULL, ADD COLUMN finishedAt timestamp DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS books_userId_status ON books (userId, status);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS reviews (
+  bookId bigint NOT NULL PRIMARY KEY REFERENCES books (id) ON DELETE CASCADE,
+  rating smallint NOT NULL DEFAULT 0,
+  review text NOT NULL DEFAULT '',
+  createdAt timestamp NOT NULL,
+  updatedAt timestamp NOT NULL
+);
+CREATE INDEX IF NOT EXISTS reviews_rating ON reviews (rating);
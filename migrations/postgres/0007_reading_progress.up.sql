@@ -0,0 +1,10 @@
+ALTER TABLE books ADD COLUMN currentPage integer NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS reading_sessions (
+  id BIGSERIAL PRIMARY KEY,
+  bookId bigint NOT NULL REFERENCES books (id) ON DELETE CASCADE,
+  startedAt timestamp NOT NULL,
+  endedAt timestamp NOT NULL,
+  startPage integer NOT NULL,
+  endPage integer NOT NULL
+);
+CREATE INDEX IF NOT EXISTS reading_sessions_bookId_endedAt ON reading_sessions (bookId, endedAt);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `books` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `isbn` varchar(20) NOT NULL,
+  `title` text NOT NULL,
+  `authors` text NOT NULL,
+  `imageUrl` text,
+  `smallImageUrl` text,
+  `publicationYear` integer NOT NULL,
+  `publisher` text,
+  `userId` varchar(45) NOT NULL,
+  `status` integer NOT NULL,
+  `description` text,
+  `pageCount` integer DEFAULT NULL,
+  `categories` text,
+  `language` text,
+  `source` text NOT NULL,
+  `deletedAt` datetime DEFAULT NULL,
+  UNIQUE (`userId`, `isbn`)
+);
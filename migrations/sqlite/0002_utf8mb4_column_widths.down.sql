@@ -0,0 +1 @@
+DROP INDEX IF EXISTS `books_userId_title`;
@@ -0,0 +1,2 @@
+-- SQLite stores text as UTF-8 and does not enforce column widths, only the index is added
+CREATE INDEX IF NOT EXISTS `books_userId_title` ON `books` (`userId`, `title`);
@@ -0,0 +1,9 @@
+ALTER TABLE `books` ADD COLUMN `authors` text NOT NULL DEFAULT '';
+ALTER TABLE `books` ADD COLUMN `categories` text;
+UPDATE `books` SET
+  `authors` = coalesce((SELECT group_concat(a.name, ', ' ORDER BY ba.position) FROM `book_authors` ba JOIN `authors` a ON a.id = ba.authorId WHERE ba.bookId = `books`.id), ''),
+  `categories` = (SELECT group_concat(c.name, ', ' ORDER BY bc.position) FROM `book_categories` bc JOIN `categories` c ON c.id = bc.categoryId WHERE bc.bookId = `books`.id);
+DROP TABLE IF EXISTS `book_categories`;
+DROP TABLE IF EXISTS `book_authors`;
+DROP TABLE IF EXISTS `categories`;
+DROP TABLE IF EXISTS `authors`;
@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS `authors` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(255) NOT NULL,
+  UNIQUE (`name`)
+);
+CREATE TABLE IF NOT EXISTS `categories` (
+  `id` INTEGER PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(255) NOT NULL,
+  UNIQUE (`name`)
+);
+CREATE TABLE IF NOT EXISTS `book_authors` (
+  `bookId` integer NOT NULL REFERENCES `books` (`id`) ON DELETE CASCADE,
+  `authorId` integer NOT NULL REFERENCES `authors` (`id`),
+  `position` integer NOT NULL,
+  PRIMARY KEY (`bookId`, `position`),
+  UNIQUE (`bookId`, `authorId`)
+);
+CREATE INDEX IF NOT EXISTS `book_authors_authorId` ON `book_authors` (`authorId`);
+CREATE TABLE IF NOT EXISTS `book_categories` (
+  `bookId` integer NOT NULL REFERENCES `books` (`id`) ON DELETE CASCADE,
+  `categoryId` integer NOT NULL REFERENCES `categories` (`id`),
+  `position` integer NOT NULL,
+  PRIMARY KEY (`bookId`, `position`),
+  UNIQUE (`bookId`, `categoryId`)
+);
+CREATE INDEX IF NOT EXISTS `book_categories_categoryId` ON `book_categories` (`categoryId`);
+
+-- Split the comma joined names of existing books
+CREATE TABLE `migration_names` AS
+WITH RECURSIVE split(kind, bookId, position, name, rest) AS (
+  SELECT 'author', id, 0, '', authors || ',' FROM `books`
+  UNION ALL
+  SELECT 'category', id, 0, '', categories || ',' FROM `books` WHERE categories IS NOT NULL
+  UNION ALL
+  SELECT kind, bookId, position + 1, trim(substr(rest, 1, instr(rest, ',') - 1)), substr(rest, instr(rest, ',') + 1) FROM split WHERE rest <> ''
+)
+SELECT kind, bookId, position, name FROM split WHERE name <> '';
+INSERT OR IGNORE INTO `authors` (`name`) SELECT name FROM `migration_names` WHERE kind = 'author' ORDER BY bookId, position;
+INSERT OR IGNORE INTO `categories` (`name`) SELECT name FROM `migration_names` WHERE kind = 'category' ORDER BY bookId, position;
+INSERT OR IGNORE INTO `book_authors` (`bookId`, `authorId`, `position`)
+  SELECT n.bookId, a.id, n.position FROM `migration_names` n JOIN `authors` a ON a.name = n.name WHERE n.kind = 'author';
+INSERT OR IGNORE INTO `book_categories` (`bookId`, `categoryId`, `position`)
+  SELECT n.bookId, c.id, n.position FROM `migration_names` n JOIN `categories` c ON c.name = n.name WHERE n.kind = 'category';
+DROP TABLE `migration_names`;
+
+ALTER TABLE `books` DROP COLUMN `authors`;
+ALTER TABLE `books` DROP COLUMN `categories`;
@@ -0,0 +1,23 @@
+-- Store the canonical ISBN-13 of every book, hyphens and spaces are stripped and ISBN-10s are converted
+DROP TABLE IF EXISTS `migration_isbns`;
+CREATE TABLE `migration_isbns` AS
+  SELECT id, userId, CASE WHEN length(clean) = 10
+    THEN '978' || substr(clean, 1, 9) || CAST((10 - (38 + 3 * CAST(substr(clean, 1, 1) AS INTEGER) + CAST(substr(clean, 2, 1) AS INTEGER) + 3 * CAST(substr(clean, 3, 1) AS INTEGER) + CAST(substr(clean, 4, 1) AS INTEGER) + 3 * CAST(substr(clean, 5, 1) AS INTEGER) + CAST(substr(clean, 6, 1) AS INTEGER) + 3 * CAST(substr(clean, 7, 1) AS INTEGER) + CAST(substr(clean, 8, 1) AS INTEGER) + 3 * CAST(substr(clean, 9, 1) AS INTEGER)) % 10) % 10 AS TEXT)
+    ELSE clean END AS isbn
+  FROM (SELECT id, userId, isbn AS raw, upper(replace(replace(isbn, '-', ''), ' ', '')) AS clean FROM `books`) b
+  WHERE clean GLOB '[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9X]'
+    OR (length(clean) = 13 AND clean NOT GLOB '*[^0-9]*' AND clean <> raw);
+-- A book saved under several spellings of its ISBN, ie: its ISBN-10 and its ISBN-13, is a single book.
+-- The last one saved is kept under the ISBN-13 with its authors and categories, the others are deleted
+DROP TABLE IF EXISTS `migration_duplicates`;
+CREATE TABLE `migration_duplicates` AS
+  SELECT b.id FROM `books` b JOIN `migration_isbns` m ON m.userId = b.userId AND m.isbn = b.isbn AND m.id > b.id
+  UNION SELECT m.id FROM `migration_isbns` m JOIN `books` b ON b.userId = m.userId AND b.isbn = m.isbn AND b.id > m.id
+  UNION SELECT m.id FROM `migration_isbns` m JOIN `migration_isbns` d ON d.userId = m.userId AND d.isbn = m.isbn AND d.id > m.id;
+DELETE FROM `book_authors` WHERE bookId IN (SELECT id FROM `migration_duplicates`);
+DELETE FROM `book_categories` WHERE bookId IN (SELECT id FROM `migration_duplicates`);
+DELETE FROM `books` WHERE id IN (SELECT id FROM `migration_duplicates`);
+DELETE FROM `migration_isbns` WHERE id IN (SELECT id FROM `migration_duplicates`);
+UPDATE `books` SET isbn = (SELECT m.isbn FROM `migration_isbns` m WHERE m.id = `books`.id) WHERE id IN (SELECT id FROM `migration_isbns`);
+DROP TABLE `migration_duplicates`;
+DROP TABLE `migration_isbns`;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `books_userId_status`;
+ALTER TABLE `books` DROP COLUMN `finishedAt`;
+ALTER TABLE `books` DROP COLUMN `startedAt`;
@@ -0,0 +1,3 @@
+ALTER TABLE `books` ADD COLUMN `startedAt` datetime DEFAULT NULL;
+ALTER TABLE `books` ADD COLUMN `finishedAt` datetime DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS `books_userId_status` ON `books` (`userId`, `status`);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS `reviews` (
+  `bookId` integer NOT NULL PRIMARY KEY REFERENCES `books` (`id`) ON DELETE CASCADE,
+  `rating` integer NOT NULL DEFAULT 0,
+  `review` text NOT NULL DEFAULT '',
+  `createdAt` datetime NOT NULL,
+  `updatedAt` datetime NOT NULL
+);
+CREATE INDEX IF NOT EXISTS `reviews_rating` ON `reviews` (`rating`);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS `book_lookups` (
+  `isbn` varchar(20) NOT NULL PRIMARY KEY,
+  `found` integer NOT NULL,
+  `book` text NOT NULL DEFAULT '',
+  `expiresAt` datetime NOT NULL
+);
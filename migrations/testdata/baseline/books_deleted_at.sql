@@ -0,0 +1 @@
+ALTER TABLE `books` ADD COLUMN `deletedAt` datetime DEFAULT NULL;
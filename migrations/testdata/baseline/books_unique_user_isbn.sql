@@ -0,0 +1,2 @@
+DELETE b1 FROM `books` b1 JOIN `books` b2 ON b1.userId = b2.userId AND b1.isbn = b2.isbn AND b1.id < b2.id;
+ALTER TABLE `books` MODIFY `isbn` varchar(20) NOT NULL, ADD UNIQUE KEY `userId_isbn` (`userId`, `isbn`);
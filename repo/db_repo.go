@@ -3,113 +3,373 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"net"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/reflectx"
 
 	"go.uber.org/zap"
 
 	"github.com/abx123/library/constant"
 	"github.com/abx123/library/entities"
+	"github.com/abx123/library/migrations"
 )
 
 // DBRepo defines a DBRepo object
 type DBRepo struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	dialect  dialect
+	timeouts Timeouts
 }
 
-// NewDbRepo creates a new instance of DBRepo object
+// NewDbRepo creates a new instance of DBRepo object backed by MySQL
 func NewDbRepo(db *sqlx.DB) *DBRepo {
 	return &DBRepo{
-		db: db,
+		db:      db,
+		dialect: mysqlDialect,
 	}
 }
 
+// NewSQLiteRepo creates a new instance of DBRepo object backed by SQLite
+func NewSQLiteRepo(db *sqlx.DB) *DBRepo {
+	return &DBRepo{
+		db:      db,
+		dialect: sqliteDialect,
+	}
+}
+
+// NewPostgresRepo creates a new instance of DBRepo object backed by Postgres
+func NewPostgresRepo(db *sqlx.DB) *DBRepo {
+	// Postgres returns column names in lower case
+	db.Mapper = reflectx.NewMapperTagFunc("db", strings.ToLower, strings.ToLower)
+	return &DBRepo{
+		db:      db,
+		dialect: postgresDialect,
+	}
+}
+
+// WithTimeouts sets the per operation timeouts of DBRepo
+func (r *DBRepo) WithTimeouts(t Timeouts) *DBRepo {
+	r.timeouts = t
+	return r
+}
+
 // Upsert updates the record if a record is found, inserts a new record if no record is found.
+// The record is matched on the unique (userId, isbn) key in a single statement, upserting a deleted record restores it.
+// The authors, categories and review of the record are replaced in the same transaction, the current page of the record is kept.
 func (r *DBRepo) Upsert(ctx context.Context, book *entities.Book) (*entities.Book, error) {
-	b, err := r.Get(ctx, book)
-	if err != nil {
-		if err == constant.ErrBookNotFound {
-			//insert
-			b, err = r.insert(ctx, book)
-			if err != nil {
-				return nil, err
-			}
-			return b, nil
+	ctx, cancel := r.timeouts.context(ctx, OpUpsert)
+	defer cancel()
+	args := []interface{}{book.ISBN, book.Title, book.ImageURL, book.SmallImageURL, book.PublicationYear, book.Publisher, book.UserID, book.Status, book.Description, book.PageCount, book.Language, book.Source, book.StartedAt, book.FinishedAt}
+	var id int64
+	var review *entities.Review
+	err := r.withTx(ctx, func(tx *sqlx.Tx) (err error) {
+		// Execute Statement
+		id, err = r.insertID(ctx, tx, r.dialect.upsert, args...)
+		if err != nil {
+			return err
 		}
+		if err = r.replaceNames(ctx, tx, authorsTable, id, book.Authors); err != nil {
+			return err
+		}
+		if err = r.replaceNames(ctx, tx, categoriesTable, id, book.Categories); err != nil {
+			return err
+		}
+		review, err = r.replaceReview(ctx, tx, id, func(*entities.Review) *entities.Review {
+			return book.Review
+		})
+		return err
+	})
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	book.BookID = id
+	book.Review = review
+	book.Authors = entities.NormalizeNames(book.Authors)
+	book.Categories = entities.NormalizeNames(book.Categories)
 
-		return nil, err
+	return book, nil
+}
+
+// insertID executes an insert statement of the dialect and returns the id of the inserted or updated record
+func (r *DBRepo) insertID(ctx context.Context, tx *sqlx.Tx, query string, args ...interface{}) (int64, error) {
+	var id int64
+	if r.dialect.returning {
+		err := tx.QueryRowxContext(ctx, r.dialect.rebind(query), args...).Scan(&id)
+		return id, err
 	}
-	book.BookID = b.BookID
-	// update
-	b, err = r.update(ctx, book)
+	res, err := tx.ExecContext(ctx, r.dialect.rebind(query), args...)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	return b, nil
+	// Error getting ID of upserted record is returned as is
+	return res.LastInsertId()
 }
 
-// Get get searches the database for a record match
+// withTx runs fn in a transaction which is committed if fn succeeds and rolled back otherwise
+func (r *DBRepo) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Get get searches the database for a record match, deleted records are excluded
 func (r *DBRepo) Get(ctx context.Context, book *entities.Book) (*entities.Book, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpGet)
+	defer cancel()
 	b := &entities.Book{}
-	err := r.db.Get(b, "SELECT * FROM `books` WHERE isbn = ? AND userId = ?", book.ISBN, book.UserID)
+	err := r.db.GetContext(ctx, b, r.dialect.rebind("SELECT * FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL"), book.ISBN, book.UserID)
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, constant.ErrBookNotFound
+		return nil, dbError(ctx, err)
+	}
+	if err = r.loadNames(ctx, []*entities.Book{b}); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if err = r.loadReviews(ctx, []*entities.Book{b}); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return b, nil
+}
+
+// Patch updates the fields set in patch of the record that matches the search criteria, returns the updated record
+func (r *DBRepo) Patch(ctx context.Context, book *entities.Book, patch *entities.BookPatch) (*entities.Book, error) {
+	if patched(patch) {
+		pctx, cancel := r.timeouts.context(ctx, OpPatch)
+		defer cancel()
+		err := r.withTx(pctx, func(tx *sqlx.Tx) error {
+			id, err := r.selectID(pctx, tx, book)
+			if err != nil {
+				return err
+			}
+			return r.patchTx(pctx, tx, id, patch)
+		})
+		if err != nil {
+			return nil, dbError(pctx, err)
 		}
+	}
+	// Read the record back to return every field
+	return r.Get(ctx, book)
+}
 
-		return nil, constant.ErrDBErr
+// patched reports whether patch sets any field
+func patched(patch *entities.BookPatch) bool {
+	cols, _ := patchColumns(patch)
+	return len(cols) > 0 || patch.Authors != nil || patch.Categories != nil || patch.Rating != nil || patch.Review != nil
+}
+
+// selectID returns the id of the record that matches the search criteria, deleted records are excluded
+func (r *DBRepo) selectID(ctx context.Context, tx *sqlx.Tx, book *entities.Book) (int64, error) {
+	var id int64
+	err := tx.GetContext(ctx, &id, r.dialect.rebind("SELECT id FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL"), book.ISBN, book.UserID)
+	return id, err
+}
+
+// patchTx updates the fields set in patch of the record with id in tx
+func (r *DBRepo) patchTx(ctx context.Context, tx *sqlx.Tx, id int64, patch *entities.BookPatch) error {
+	cols, args := patchColumns(patch)
+	if len(cols) > 0 {
+		// Execute Statement
+		_, err := tx.ExecContext(ctx, r.dialect.rebind("UPDATE `books` SET "+strings.Join(cols, ", ")+" WHERE id = ?"), append(args, id)...)
+		if err != nil {
+			return err
+		}
 	}
-	return b, nil
+	if patch.Authors != nil {
+		if err := r.replaceNames(ctx, tx, authorsTable, id, *patch.Authors); err != nil {
+			return err
+		}
+	}
+	if patch.Categories != nil {
+		if err := r.replaceNames(ctx, tx, categoriesTable, id, *patch.Categories); err != nil {
+			return err
+		}
+	}
+	if patch.Rating != nil || patch.Review != nil {
+		_, err := r.replaceReview(ctx, tx, id, func(prev *entities.Review) *entities.Review {
+			return entities.PatchedReview(prev, patch)
+		})
+		return err
+	}
+	return nil
 }
 
-func (r *DBRepo) insert(ctx context.Context, book *entities.Book) (*entities.Book, error) {
-	// Execute Statement
-	res, err := r.db.Exec("INSERT INTO `books` (isbn, title, authors, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, categories, language, source) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", book.ISBN, book.Title, book.Authors, book.ImageURL, book.SmallImageURL, book.PublicationYear, book.Publisher, book.UserID, book.Status, book.Description, book.PageCount, book.Categories, book.Language, book.Source)
+func patchColumns(patch *entities.BookPatch) ([]string, []interface{}) {
+	cols := []string{}
+	args := []interface{}{}
+	set := func(col string, val interface{}) {
+		cols = append(cols, col+"=?")
+		args = append(args, val)
+	}
+	if patch.Title != nil {
+		set("title", *patch.Title)
+	}
+	if patch.ImageURL != nil {
+		set("imageUrl", *patch.ImageURL)
+	}
+	if patch.SmallImageURL != nil {
+		set("smallImageUrl", *patch.SmallImageURL)
+	}
+	if patch.PublicationYear != nil {
+		set("publicationYear", *patch.PublicationYear)
+	}
+	if patch.Publisher != nil {
+		set("publisher", *patch.Publisher)
+	}
+	if patch.Status != nil {
+		set("status", *patch.Status)
+		set("startedAt", patch.StartedAt)
+		set("finishedAt", patch.FinishedAt)
+	}
+	if patch.Description != nil {
+		set("description", *patch.Description)
+	}
+	if patch.PageCount != nil {
+		set("pageCount", *patch.PageCount)
+	}
+	if patch.Language != nil {
+		set("language", *patch.Language)
+	}
+	if patch.Source != nil {
+		set("source", *patch.Source)
+	}
+	if patch.CurrentPage != nil {
+		set("currentPage", *patch.CurrentPage)
+	}
+	return cols, args
+}
+
+// List returns the page of records that matches the query, deleted records are excluded
+func (r *DBRepo) List(ctx context.Context, q *entities.ListQuery) ([]*entities.Book, error) {
+	query, args, err := listQuery(q)
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		// Error paring statement result into struct
-		return nil, constant.ErrDBErr
+		return nil, err
+	}
+	return r.list(ctx, OpList, query, args...)
+}
+
+// Count returns the number of records that match the filters of the query, deleted records are excluded
+func (r *DBRepo) Count(ctx context.Context, q *entities.ListQuery) (int64, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpCount)
+	defer cancel()
+	query, args := countQuery(q)
+	var n int64
+	if err := r.db.GetContext(ctx, &n, r.dialect.rebind(query), args...); err != nil {
+		return 0, dbError(ctx, err)
+	}
+	return n, nil
+}
+
+// ListDeleted returns list of deleted records that matches the search criteria, most recently deleted first
+func (r *DBRepo) ListDeleted(ctx context.Context, limit, offset int64, userId string) ([]*entities.Book, error) {
+	if err := checkPage(limit, offset); err != nil {
+		return nil, err
 	}
-	id, err := res.LastInsertId()
+	return r.list(ctx, OpListDeleted, "SELECT * FROM `books` WHERE userId=? AND deletedAt IS NOT NULL ORDER BY deletedAt DESC LIMIT ? OFFSET ?", userId, limit, offset)
+}
+
+func (r *DBRepo) list(ctx context.Context, op string, query string, args ...interface{}) ([]*entities.Book, error) {
+	ctx, cancel := r.timeouts.context(ctx, op)
+	defer cancel()
+	books := []*entities.Book{}
+	err := r.db.SelectContext(ctx, &books, r.dialect.rebind(query), args...)
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		// Error getting ID of newly created record
-		return nil, constant.ErrDBErr
+		return nil, dbError(ctx, err)
 	}
-	book.BookID = id
+	if err = r.loadNames(ctx, books); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	if err = r.loadReviews(ctx, books); err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return books, nil
+}
 
-	return book, nil
+// Delete marks the record that matches the search criteria as deleted
+func (r *DBRepo) Delete(ctx context.Context, book *entities.Book) error {
+	return r.exec(ctx, OpDelete, "UPDATE `books` SET deletedAt = ? WHERE isbn = ? AND userId = ? AND deletedAt IS NULL", time.Now().UTC(), book.ISBN, book.UserID)
 }
 
-func (r *DBRepo) update(ctx context.Context, book *entities.Book) (*entities.Book, error) {
+// Restore unmarks the deleted record that matches the search criteria
+func (r *DBRepo) Restore(ctx context.Context, book *entities.Book) error {
+	return r.exec(ctx, OpRestore, "UPDATE `books` SET deletedAt = NULL WHERE isbn = ? AND userId = ? AND deletedAt IS NOT NULL", book.ISBN, book.UserID)
+}
+
+// Purge permanently removes records deleted before the given time, returns the number of records removed
+func (r *DBRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpPurge)
+	defer cancel()
 	// Execute Statement
-	res, err := r.db.Exec("UPDATE `books` SET isbn=?, title=?, authors=?, imageUrl=?, smallImageUrl=?, publicationYear=?, userId=?, status=?, description=?, pageCount=?, categories=?, language=?, source=?, publisher=? WHERE isbn = ? AND userId = ?", book.ISBN, book.Title, book.Authors, book.ImageURL, book.SmallImageURL, book.PublicationYear, book.UserID, book.Status, book.Description, book.PageCount, book.Categories, book.Language, book.Source, book.Publisher, book.ISBN, book.UserID)
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind("DELETE FROM `books` WHERE deletedAt IS NOT NULL AND deletedAt < ?"), before)
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		// Error paring statement result into struct
-		return nil, constant.ErrDBErr
+		return 0, dbError(ctx, err)
 	}
-	_, err = res.RowsAffected()
+	n, err := res.RowsAffected()
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		// Error getting ID of newly created record
-		return nil, constant.ErrDBErr
+		// Error getting number of purged records
+		return 0, dbError(ctx, err)
 	}
-	return book, nil
+	return n, nil
 }
 
-// List returns list of records that matches the search criteria
-func (r *DBRepo) List(ctx context.Context, limit, offset int64, userId string) ([]*entities.Book, error) {
-	books := []*entities.Book{}
-	err := r.db.Select(&books, "SELECT * FROM `books` WHERE userId=?  LIMIT ? OFFSET ?", userId, limit, offset)
+// exec executes a statement that is expected to affect a single record, returns ErrBookNotFound if none is affected
+func (r *DBRepo) exec(ctx context.Context, op string, query string, args ...interface{}) error {
+	ctx, cancel := r.timeouts.context(ctx, op)
+	defer cancel()
+	// Execute Statement
+	res, err := r.db.ExecContext(ctx, r.dialect.rebind(query), args...)
 	if err != nil {
-		zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, constant.ErrBookNotFound
-		}
-		return nil, constant.ErrDBErr
+		return dbError(ctx, err)
 	}
-	return books, nil
+	n, err := res.RowsAffected()
+	if err != nil {
+		// Error getting number of affected records
+		return dbError(ctx, err)
+	}
+	if n == 0 {
+		return constant.ErrBookNotFound
+	}
+	return nil
+}
+
+// dbError logs err and maps it to a constant error, cancellation and deadline of ctx are reported as such
+func dbError(ctx context.Context, err error) error {
+	zap.L().Error(constant.ErrDBErr.Error(), zap.Error(err))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return constant.ErrBookNotFound
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return constant.ErrTimeout
+	case errors.Is(err, context.Canceled), errors.Is(ctx.Err(), context.Canceled):
+		return constant.ErrRequestCanceled
+	case unavailable(err):
+		return constant.ErrDBUnavailable
+	}
+	return constant.ErrDBErr
+}
+
+// unavailable reports whether err is caused by a lost or refused connection to the database
+func unavailable(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, mysql.ErrInvalidConn) || errors.As(err, &netErr)
+}
+
+// Migrator returns the schema migrator of the underlying database
+func (r *DBRepo) Migrator() (*migrations.Migrator, error) {
+	return migrations.New(r.db.DB, r.dialect.name)
+}
+
+// Close closes the underlying database
+func (r *DBRepo) Close() error {
+	return r.db.Close()
 }
@@ -3,11 +3,16 @@ package repo
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/stretchr/testify/assert"
 	sqlxmock "github.com/zhashkevych/go-sqlxmock"
@@ -24,15 +29,46 @@ func NewMockDb() (*sqlx.DB, sqlxmock.Sqlmock) {
 	return db, mock
 }
 
+var (
+	authorsQuery    = regexp.QuoteMeta("SELECT j.bookId, n.name FROM `book_authors` j JOIN `authors` n ON n.id = j.authorId WHERE j.bookId IN (")
+	categoriesQuery = regexp.QuoteMeta("SELECT j.bookId, n.name FROM `book_categories` j JOIN `categories` n ON n.id = j.categoryId WHERE j.bookId IN (")
+	reviewsQuery    = regexp.QuoteMeta("SELECT bookId, rating, review, createdAt, updatedAt FROM `reviews` WHERE bookId IN (")
+	reviewQuery     = regexp.QuoteMeta("SELECT rating, review, createdAt, updatedAt FROM `reviews` WHERE bookId = ?")
+	reviewedAt      = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// expectNames expects the authors and categories of books to be loaded
+func expectNames(mock sqlxmock.Sqlmock, authors, categories [][]driver.Value) {
+	authorRows := sqlxmock.NewRows([]string{"bookId", "name"})
+	for _, a := range authors {
+		authorRows.AddRow(a...)
+	}
+	categoryRows := sqlxmock.NewRows([]string{"bookId", "name"})
+	for _, c := range categories {
+		categoryRows.AddRow(c...)
+	}
+	mock.ExpectQuery(authorsQuery).WillReturnRows(authorRows)
+	mock.ExpectQuery(categoriesQuery).WillReturnRows(categoryRows)
+}
+
+// expectReviews expects the reviews of books to be loaded
+func expectReviews(mock sqlxmock.Sqlmock, reviews [][]driver.Value) {
+	rows := sqlxmock.NewRows([]string{"bookId", "rating", "review", "createdAt", "updatedAt"})
+	for _, r := range reviews {
+		rows.AddRow(r...)
+	}
+	mock.ExpectQuery(reviewsQuery).WillReturnRows(rows)
+}
+
 func TestGet(t *testing.T) {
-	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ?")
-	rows := sqlxmock.NewRows([]string{"id", "isbn", "title", "authors", "imageUrl", "smallImageUrl", "publicationYear", "publisher", "userId", "status", "description", "pageCount", "categories", "language", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "Michael Robotham", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "", "goodreads")
+	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
 	type testCase struct {
-		name   string
-		desc   string
-		err    error
-		expRes *entities.Book
-		expErr error
+		name     string
+		desc     string
+		err      error
+		namesErr error
+		expRes   *entities.Book
+		expErr   error
 	}
 	testCases := []testCase{
 		{
@@ -42,12 +78,13 @@ func TestGet(t *testing.T) {
 				BookID:    1,
 				ISBN:      "9780751562774",
 				Title:     "The Secrets She Keeps",
-				Authors:   "Michael Robotham",
+				Authors:   []string{"Michael Robotham"},
 				ImageURL:  "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png",
 				Publisher: "BB Publishing House",
 				UserID:    "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
 				Status:    1,
 				Source:    "goodreads",
+				Review:    &entities.Review{Rating: 9, Text: "Gripping", CreatedAt: reviewedAt, UpdatedAt: reviewedAt},
 			},
 		},
 		{
@@ -62,6 +99,12 @@ func TestGet(t *testing.T) {
 			err:    fmt.Errorf("mock error"),
 			expErr: constant.ErrDBErr,
 		},
+		{
+			name:     "Sad Case",
+			desc:     "loading authors returns error",
+			namesErr: fmt.Errorf("mock error"),
+			expErr:   constant.ErrDBErr,
+		},
 	}
 
 	for _, v := range testCases {
@@ -70,7 +113,12 @@ func TestGet(t *testing.T) {
 		if v.err != nil {
 			mock.ExpectQuery(query).WillReturnError(v.err)
 		}
-		mock.ExpectQuery(query).WillReturnRows(rows)
+		mock.ExpectQuery(query).WillReturnRows(sqlxmock.NewRows([]string{"id", "isbn", "title", "imageUrl", "smallImageUrl", "publicationYear", "publisher", "userId", "status", "description", "pageCount", "language", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "goodreads"))
+		if v.namesErr != nil {
+			mock.ExpectQuery(authorsQuery).WillReturnError(v.namesErr)
+		}
+		expectNames(mock, [][]driver.Value{{1, "Michael Robotham"}}, nil)
+		expectReviews(mock, [][]driver.Value{{1, 9, "Gripping", reviewedAt, reviewedAt}})
 
 		actRes, actErr := repo.Get(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
 		assert.Equal(t, v.expRes, actRes)
@@ -78,8 +126,81 @@ func TestGet(t *testing.T) {
 	}
 }
 
-func TestInsert(t *testing.T) {
-	query := regexp.QuoteMeta("INSERT INTO `books` (isbn, title, authors, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, categories, language, source) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+func TestList(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE userId = ? AND deletedAt IS NULL ORDER BY id ASC LIMIT ? OFFSET ?")
+	row := sqlxmock.NewRows([]string{"id", "isbn", "title", "imageUrl", "smallImageUrl", "publicationYear", "publisher", "userId", "status", "description", "pageCount", "language", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "goodreads").AddRow(2, "9781407243207", "The Bourne Ultimatum", "https://images.isbndb.com/covers/32/07/9781407243207.jpg", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "en_US", "isbndb")
+	type testCase struct {
+		name   string
+		desc   string
+		expRes []*entities.Book
+		expErr error
+		err    error
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			expRes: []*entities.Book{
+				{
+					BookID:     1,
+					ISBN:       "9780751562774",
+					Title:      "The Secrets She Keeps",
+					Authors:    []string{"Michael Robotham"},
+					ImageURL:   "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png",
+					Publisher:  "BB Publishing House",
+					UserID:     "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+					Status:     1,
+					Categories: []string{"Crime", "Thriller"},
+					Source:     "goodreads",
+				},
+				{
+					BookID:    2,
+					ISBN:      "9781407243207",
+					Title:     "The Bourne Ultimatum",
+					ImageURL:  "https://images.isbndb.com/covers/32/07/9781407243207.jpg",
+					Publisher: "BB Publishing House",
+					UserID:    "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+					Status:    1,
+					Language:  "en_US",
+					Source:    "isbndb",
+				},
+			},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "db returns empty row",
+			err:    sql.ErrNoRows,
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "db returns error",
+			err:    fmt.Errorf("mock error"),
+			expErr: constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.err != nil {
+			mock.ExpectQuery(query).WillReturnError(v.err)
+		}
+		mock.ExpectQuery(query).WillReturnRows(row)
+		expectNames(mock, [][]driver.Value{{1, "Michael Robotham"}}, [][]driver.Value{{1, "Crime"}, {1, "Thriller"}})
+		expectReviews(mock, nil)
+
+		actRes, actErr := repo.List(context.Background(), &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Limit: 10})
+		assert.Equal(t, v.expRes, actRes)
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	query := regexp.QuoteMeta("INSERT INTO `books` (isbn, title, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, language, source, startedAt, finishedAt) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id), title=VALUES(title), imageUrl=VALUES(imageUrl), smallImageUrl=VALUES(smallImageUrl), publicationYear=VALUES(publicationYear), publisher=VALUES(publisher), status=VALUES(status), description=VALUES(description), pageCount=VALUES(pageCount), language=VALUES(language), source=VALUES(source), startedAt=VALUES(startedAt), finishedAt=VALUES(finishedAt), deletedAt=NULL")
+	deleteAuthors := regexp.QuoteMeta("DELETE FROM `book_authors` WHERE bookId = ?")
+	upsertAuthor := regexp.QuoteMeta("INSERT INTO `authors` (name) VALUES(?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id)")
+	insertAuthor := regexp.QuoteMeta("INSERT INTO `book_authors` (bookId, authorId, position) VALUES(?, ?, ?)")
+	deleteCategories := regexp.QuoteMeta("DELETE FROM `book_categories` WHERE bookId = ?")
 
 	type testCase struct {
 		name         string
@@ -88,6 +209,8 @@ func TestInsert(t *testing.T) {
 		dbErr        bool
 		expRes       *entities.Book
 		lastInertErr bool
+		authorErr    bool
+		commitErr    bool
 		expErr       error
 	}
 	testCases := []testCase{
@@ -95,9 +218,10 @@ func TestInsert(t *testing.T) {
 			name: "Happy Case",
 			desc: "all ok",
 			expRes: &entities.Book{
-				BookID: 99,
-				ISBN:   "9780751562774",
-				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				BookID:  99,
+				ISBN:    "9780751562774",
+				UserID:  "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Authors: []string{"Michael Robotham"},
 			},
 		},
 		{
@@ -114,43 +238,162 @@ func TestInsert(t *testing.T) {
 			expErr: constant.ErrDBErr,
 			dbErr:  true,
 		},
+		{
+			name:      "Sad Case",
+			desc:      "inserting author returns error",
+			err:       fmt.Errorf("mock error"),
+			expErr:    constant.ErrDBErr,
+			authorErr: true,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "commit returns error",
+			err:       fmt.Errorf("mock error"),
+			expErr:    constant.ErrDBErr,
+			commitErr: true,
+		},
 	}
 	for _, v := range testCases {
 		db, mock := NewMockDb()
 		repo := NewDbRepo(db)
-		if v.dbErr {
+		mock.ExpectBegin()
+		switch {
+		case v.dbErr:
 			mock.ExpectExec(query).WillReturnError(v.err)
-		}
-		if v.lastInertErr {
+			mock.ExpectRollback()
+		case v.lastInertErr:
 			mock.ExpectExec(query).WillReturnResult(sqlxmock.NewErrorResult(v.err))
+			mock.ExpectRollback()
+		default:
+			mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(99, 1))
+			mock.ExpectExec(deleteAuthors).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 1))
+			if v.authorErr {
+				mock.ExpectExec(upsertAuthor).WithArgs("Michael Robotham").WillReturnError(v.err)
+				mock.ExpectRollback()
+				break
+			}
+			mock.ExpectExec(upsertAuthor).WithArgs("Michael Robotham").WillReturnResult(sqlxmock.NewResult(7, 1))
+			mock.ExpectExec(insertAuthor).WithArgs(99, 7, 0).WillReturnResult(sqlxmock.NewResult(0, 1))
+			mock.ExpectExec(deleteCategories).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 0))
+			mock.ExpectQuery(reviewQuery).WithArgs(99).WillReturnRows(sqlxmock.NewRows([]string{"rating", "review", "createdAt", "updatedAt"}))
+			if v.commitErr {
+				mock.ExpectCommit().WillReturnError(v.err)
+				break
+			}
+			mock.ExpectCommit()
 		}
-		mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(99, 1))
 
-		actRes, actErr := repo.insert(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
-		assert.Equal(t, v.expRes, actRes)
+		actRes, actErr := repo.Upsert(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Authors: []string{" Michael Robotham", "Michael Robotham"}})
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
+
+func TestContextErrors(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
+	type testCase struct {
+		name     string
+		desc     string
+		timeouts Timeouts
+		cancel   bool
+		expErr   error
+	}
+	testCases := []testCase{
+		{
+			name:     "Sad Case",
+			desc:     "operation timeout exceeded",
+			timeouts: Timeouts{Default: time.Second, Ops: map[string]time.Duration{OpGet: 10 * time.Millisecond}},
+			expErr:   constant.ErrTimeout,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "request canceled",
+			cancel: true,
+			expErr: constant.ErrRequestCanceled,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db).WithTimeouts(v.timeouts)
+		mock.ExpectQuery(query).WillDelayFor(100 * time.Millisecond).WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(1))
+		ctx, cancel := context.WithCancel(context.Background())
+		if v.cancel {
+			cancel()
+		}
+		actRes, actErr := repo.Get(ctx, &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
+		cancel()
+		assert.Nil(t, actRes)
 		assert.Equal(t, v.expErr, actErr)
 	}
 }
 
-func TestUpdate(t *testing.T) {
-	query := regexp.QuoteMeta("UPDATE `books` SET isbn=?, title=?, authors=?, imageUrl=?, smallImageUrl=?, publicationYear=?, userId=?, status=?, description=?, pageCount=?, categories=?, language=?, source=?, publisher=? WHERE isbn = ? AND userId = ?")
+func TestDbError(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		err    error
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Sad Case",
+			desc:   "no rows",
+			err:    sql.ErrNoRows,
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "bad connection",
+			err:    driver.ErrBadConn,
+			expErr: constant.ErrDBUnavailable,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "connection refused",
+			err:    &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expErr: constant.ErrDBUnavailable,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "mysql invalid connection",
+			err:    mysql.ErrInvalidConn,
+			expErr: constant.ErrDBUnavailable,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "other database error",
+			err:    errors.New("syntax error"),
+			expErr: constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		assert.Equal(t, v.expErr, dbError(context.Background(), v.err), v.desc)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	query := regexp.QuoteMeta("UPDATE `books` SET deletedAt = ? WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
 	type testCase struct {
 		name            string
 		desc            string
 		err             error
-		expRes          *entities.Book
 		expErr          error
 		dbErr           bool
 		rowsAffectedErr bool
+		rowsAffected    int64
 	}
 	testCases := []testCase{
 		{
-			name: "Happy Case",
-			desc: "all ok",
-			expRes: &entities.Book{
-				ISBN:   "9780751562774",
-				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
-			},
+			name:         "Happy Case",
+			desc:         "all ok",
+			rowsAffected: 1,
+		},
+		{
+			name:         "Sad Case",
+			desc:         "no record deleted",
+			rowsAffected: 0,
+			expErr:       constant.ErrBookNotFound,
 		},
 		{
 			name:   "Sad Case",
@@ -176,16 +419,53 @@ func TestUpdate(t *testing.T) {
 		if v.rowsAffectedErr {
 			mock.ExpectExec(query).WillReturnResult(sqlxmock.NewErrorResult(v.err))
 		}
-		mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(1, 1))
-		actRes, actErr := repo.update(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
-		assert.Equal(t, v.expRes, actRes)
+		mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(0, v.rowsAffected))
+		actErr := repo.Delete(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
 		assert.Equal(t, v.expErr, actErr)
 	}
 }
 
-func TestList(t *testing.T) {
-	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE userId=?  LIMIT ? OFFSET ?")
-	row := sqlxmock.NewRows([]string{"id", "isbn", "title", "authors", "imageUrl", "smallImageUrl", "publicationYear", "publisher", "userId", "status", "description", "pageCount", "categories", "language", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "Michael Robotham", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "", "goodreads").AddRow(2, "9781407243207", "The Bourne Ultimatum", "", "https://images.isbndb.com/covers/32/07/9781407243207.jpg", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "en_US", "isbndb")
+func TestCount(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT COUNT(*) FROM `books` WHERE userId = ? AND deletedAt IS NULL AND language = ?")
+	type testCase struct {
+		name   string
+		desc   string
+		err    error
+		expRes int64
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: 3,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "db returns error",
+			err:    fmt.Errorf("mock error"),
+			expErr: constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.err != nil {
+			mock.ExpectQuery(query).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", "en").WillReturnError(v.err)
+		} else {
+			mock.ExpectQuery(query).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", "en").WillReturnRows(sqlxmock.NewRows([]string{"COUNT(*)"}).AddRow(3))
+		}
+
+		actRes, actErr := repo.Count(context.Background(), &entities.ListQuery{UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Language: "en", Limit: 10, Offset: 20})
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+	}
+}
+
+func TestListDeleted(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT * FROM `books` WHERE userId=? AND deletedAt IS NOT NULL ORDER BY deletedAt DESC LIMIT ? OFFSET ?")
+	deletedAt := time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)
+	row := sqlxmock.NewRows([]string{"id", "isbn", "title", "userId", "status", "source", "deletedAt"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "goodreads", deletedAt)
 	type testCase struct {
 		name   string
 		desc   string
@@ -202,36 +482,60 @@ func TestList(t *testing.T) {
 					BookID:    1,
 					ISBN:      "9780751562774",
 					Title:     "The Secrets She Keeps",
-					Authors:   "Michael Robotham",
-					ImageURL:  "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png",
-					Publisher: "BB Publishing House",
 					UserID:    "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
 					Status:    1,
 					Source:    "goodreads",
-				},
-				{
-					BookID:    2,
-					ISBN:      "9781407243207",
-					Title:     "The Bourne Ultimatum",
-					ImageURL:  "https://images.isbndb.com/covers/32/07/9781407243207.jpg",
-					Publisher: "BB Publishing House",
-					UserID:    "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
-					Status:    1,
-					Language:  "en_US",
-					Source:    "isbndb",
+					DeletedAt: &deletedAt,
 				},
 			},
 		},
 		{
 			name:   "Sad Case",
-			desc:   "db returns empty row",
-			err:    sql.ErrNoRows,
+			desc:   "db returns error",
+			err:    fmt.Errorf("mock error"),
+			expErr: constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.err != nil {
+			mock.ExpectQuery(query).WillReturnError(v.err)
+		}
+		mock.ExpectQuery(query).WillReturnRows(row)
+		expectNames(mock, nil, nil)
+		expectReviews(mock, nil)
+
+		actRes, actErr := repo.ListDeleted(context.Background(), 10, 0, "8BeqLfieIiTOkruBBrQ6p8jOTsk2")
+		assert.Equal(t, v.expRes, actRes)
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	query := regexp.QuoteMeta("UPDATE `books` SET deletedAt = NULL WHERE isbn = ? AND userId = ? AND deletedAt IS NOT NULL")
+	type testCase struct {
+		name         string
+		desc         string
+		err          error
+		expErr       error
+		rowsAffected int64
+	}
+	testCases := []testCase{
+		{
+			name:         "Happy Case",
+			desc:         "all ok",
+			rowsAffected: 1,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "no deleted record found",
 			expErr: constant.ErrBookNotFound,
 		},
 		{
 			name:   "Sad Case",
 			desc:   "db returns error",
-			err:    fmt.Errorf("mock error"),
+			err:    fmt.Errorf("db returns error"),
 			expErr: constant.ErrDBErr,
 		},
 	}
@@ -239,109 +543,644 @@ func TestList(t *testing.T) {
 		db, mock := NewMockDb()
 		repo := NewDbRepo(db)
 		if v.err != nil {
-			mock.ExpectQuery(query).WillReturnError(v.err)
+			mock.ExpectExec(query).WillReturnError(v.err)
 		}
-		mock.ExpectQuery(query).WillReturnRows(row)
+		mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(0, v.rowsAffected))
+		actErr := repo.Restore(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
 
-		actRes, actErr := repo.List(context.Background(), 10, 0, "8BeqLfieIiTOkruBBrQ6p8jOTsk2")
+func TestPurge(t *testing.T) {
+	query := regexp.QuoteMeta("DELETE FROM `books` WHERE deletedAt IS NOT NULL AND deletedAt < ?")
+	before := time.Date(2021, 8, 1, 0, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name            string
+		desc            string
+		err             error
+		expRes          int64
+		expErr          error
+		dbErr           bool
+		rowsAffectedErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: 3,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "db returns error",
+			err:    fmt.Errorf("db returns error"),
+			expErr: constant.ErrDBErr,
+			dbErr:  true,
+		},
+		{
+			name:            "Sad Case",
+			desc:            "rows affected returns error",
+			err:             fmt.Errorf("db returns error"),
+			expErr:          constant.ErrDBErr,
+			rowsAffectedErr: true,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.dbErr {
+			mock.ExpectExec(query).WithArgs(before).WillReturnError(v.err)
+		}
+		if v.rowsAffectedErr {
+			mock.ExpectExec(query).WithArgs(before).WillReturnResult(sqlxmock.NewErrorResult(v.err))
+		}
+		mock.ExpectExec(query).WithArgs(before).WillReturnResult(sqlxmock.NewResult(0, 3))
+		actRes, actErr := repo.Purge(context.Background(), before)
 		assert.Equal(t, v.expRes, actRes)
 		assert.Equal(t, v.expErr, actErr)
 	}
 }
 
-func TestUpsert(t *testing.T) {
-	getQuery := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ?")
-	getRows := sqlxmock.NewRows([]string{"id", "isbn", "title", "authors", "imageUrl", "smallImageUrl", "publicationYear", "publisher", "userId", "status", "description", "pageCount", "categories", "language", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "Michael Robotham", "https://s.gr-assets.com/assets/nophoto/book/111x148-bcc042a9c91a29c1d680899eff700a03.png", "", 0, "BB Publishing House", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, "", 0, "", "", "goodreads")
-	updateQuery := regexp.QuoteMeta("UPDATE `books` SET isbn=?, title=?, authors=?, imageUrl=?, smallImageUrl=?, publicationYear=?, userId=?, status=?, description=?, pageCount=?, categories=?, language=?, source=?, publisher=? WHERE isbn = ? AND userId = ?")
-	insertQuery := regexp.QuoteMeta("INSERT INTO `books` (isbn, title, authors, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, categories, language, source) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+func TestUpsertReview(t *testing.T) {
+	query := regexp.QuoteMeta("INSERT INTO `books`")
+	upsertReview := regexp.QuoteMeta("INSERT INTO `reviews` (bookId, rating, review, createdAt, updatedAt) VALUES(?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE rating=VALUES(rating), review=VALUES(review), updatedAt=VALUES(updatedAt)")
+	deleteReview := regexp.QuoteMeta("DELETE FROM `reviews` WHERE bookId = ?")
+	stored := &entities.Review{Rating: 9, Text: "Gripping", CreatedAt: reviewedAt, UpdatedAt: reviewedAt}
 	type testCase struct {
 		name      string
 		desc      string
-		err       error
+		review    *entities.Review
+		prev      []driver.Value
+		prevErr   error
+		write     string
+		expRating entities.Rating
+		expText   string
+		expKept   bool
+		expErr    error
+	}
+	testCases := []testCase{
+		{
+			name:      "Happy Case",
+			desc:      "new review is written",
+			review:    &entities.Review{Rating: 9, Text: "Gripping"},
+			write:     upsertReview,
+			expRating: 9,
+			expText:   "Gripping",
+		},
+		{
+			name:      "Happy Case",
+			desc:      "changed rating keeps the creation time",
+			review:    &entities.Review{Rating: 10, Text: "Gripping"},
+			prev:      []driver.Value{9, "Gripping", reviewedAt, reviewedAt},
+			write:     upsertReview,
+			expRating: 10,
+			expText:   "Gripping",
+		},
+		{
+			name:      "Happy Case",
+			desc:      "unchanged review is not written",
+			review:    &entities.Review{Rating: 9, Text: "Gripping"},
+			prev:      []driver.Value{9, "Gripping", reviewedAt, reviewedAt},
+			expRating: 9,
+			expText:   "Gripping",
+			expKept:   true,
+		},
+		{
+			name:  "Happy Case",
+			desc:  "review without rating and text is removed",
+			prev:  []driver.Value{9, "Gripping", reviewedAt, reviewedAt},
+			write: deleteReview,
+		},
+		{
+			name:    "Sad Case",
+			desc:    "selecting review returns error",
+			review:  &entities.Review{Rating: 9},
+			prevErr: fmt.Errorf("mock error"),
+			expErr:  constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WillReturnResult(sqlxmock.NewResult(99, 1))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `book_authors` WHERE bookId = ?")).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 0))
+		mock.ExpectExec(regexp.QuoteMeta("DELETE FROM `book_categories` WHERE bookId = ?")).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 0))
+		rows := sqlxmock.NewRows([]string{"rating", "review", "createdAt", "updatedAt"})
+		if v.prev != nil {
+			rows.AddRow(v.prev...)
+		}
+		if v.prevErr != nil {
+			mock.ExpectQuery(reviewQuery).WithArgs(99).WillReturnError(v.prevErr)
+			mock.ExpectRollback()
+		} else {
+			mock.ExpectQuery(reviewQuery).WithArgs(99).WillReturnRows(rows)
+		}
+		switch v.write {
+		case upsertReview:
+			mock.ExpectExec(upsertReview).WithArgs(99, v.expRating, v.expText, sqlxmock.AnyArg(), sqlxmock.AnyArg()).WillReturnResult(sqlxmock.NewResult(0, 1))
+		case deleteReview:
+			mock.ExpectExec(deleteReview).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 1))
+		}
+		if v.prevErr == nil {
+			mock.ExpectCommit()
+		}
+
+		actRes, actErr := repo.Upsert(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Review: v.review})
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+		if v.expErr != nil {
+			continue
+		}
+		switch {
+		case v.expKept:
+			assert.Equal(t, stored, actRes.Review, v.desc)
+		case v.expRating == 0:
+			assert.Nil(t, actRes.Review, v.desc)
+		default:
+			assert.Equal(t, v.expRating, actRes.Review.Rating, v.desc)
+			assert.Equal(t, v.expText, actRes.Review.Text, v.desc)
+			assert.False(t, actRes.Review.UpdatedAt.Before(actRes.Review.CreatedAt), v.desc)
+			if v.prev != nil {
+				assert.Equal(t, reviewedAt, actRes.Review.CreatedAt, v.desc)
+			}
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	idQuery := regexp.QuoteMeta("SELECT id FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
+	updateQuery := regexp.QuoteMeta("UPDATE `books` SET title=?, status=?, startedAt=?, finishedAt=?, pageCount=? WHERE id = ?")
+	deleteCategories := regexp.QuoteMeta("DELETE FROM `book_categories` WHERE bookId = ?")
+	upsertCategory := regexp.QuoteMeta("INSERT INTO `categories` (name) VALUES(?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id)")
+	insertCategory := regexp.QuoteMeta("INSERT INTO `book_categories` (bookId, categoryId, position) VALUES(?, ?, ?)")
+	getQuery := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
+	title := "The Secrets She Keeps"
+	status := entities.StatusToRead
+	pageCount := int64(0)
+	categories := []string{"Thriller"}
+	type testCase struct {
+		name      string
+		desc      string
+		patch     *entities.BookPatch
 		expRes    *entities.Book
 		expErr    error
-		updateErr bool
-		getErr    bool
-		insertErr bool
-		insert    bool
 		update    bool
+		idErr     error
+		updateErr bool
+		getErr    error
 	}
 	testCases := []testCase{
 		{
-			name: "Happy Case",
-			desc: "update",
+			name:  "Happy Case",
+			desc:  "all ok",
+			patch: &entities.BookPatch{Title: &title, Status: &status, PageCount: &pageCount, Categories: &categories},
+			expRes: &entities.Book{
+				BookID:     1,
+				ISBN:       "9780751562774",
+				Title:      "The Secrets She Keeps",
+				UserID:     "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status:     2,
+				Source:     "goodreads",
+				Categories: []string{"Thriller"},
+			},
+			update: true,
+		},
+		{
+			name:  "Happy Case",
+			desc:  "empty patch",
+			patch: &entities.BookPatch{},
 			expRes: &entities.Book{
-				BookID: 1,
-				ISBN:   "9780751562774",
-				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				BookID:     1,
+				ISBN:       "9780751562774",
+				Title:      "The Secrets She Keeps",
+				UserID:     "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status:     2,
+				Source:     "goodreads",
+				Categories: []string{"Thriller"},
 			},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "update returns error",
+			patch:     &entities.BookPatch{Title: &title, Status: &status, PageCount: &pageCount, Categories: &categories},
+			expErr:    constant.ErrDBErr,
+			update:    true,
+			updateErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "record not found",
+			patch:  &entities.BookPatch{Title: &title, Status: &status, PageCount: &pageCount, Categories: &categories},
+			expErr: constant.ErrBookNotFound,
 			update: true,
+			idErr:  sql.ErrNoRows,
 		},
+		{
+			name:   "Sad Case",
+			desc:   "record not found with empty patch",
+			patch:  &entities.BookPatch{},
+			expErr: constant.ErrBookNotFound,
+			getErr: sql.ErrNoRows,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.update {
+			mock.ExpectBegin()
+			if v.idErr != nil {
+				mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnError(v.idErr)
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(1))
+				if v.updateErr {
+					mock.ExpectExec(updateQuery).WithArgs(title, status, nil, nil, pageCount, 1).WillReturnError(fmt.Errorf("mock error"))
+					mock.ExpectRollback()
+				} else {
+					mock.ExpectExec(updateQuery).WithArgs(title, status, nil, nil, pageCount, 1).WillReturnResult(sqlxmock.NewResult(0, 1))
+					mock.ExpectExec(deleteCategories).WithArgs(1).WillReturnResult(sqlxmock.NewResult(0, 1))
+					mock.ExpectExec(upsertCategory).WithArgs("Thriller").WillReturnResult(sqlxmock.NewResult(3, 1))
+					mock.ExpectExec(insertCategory).WithArgs(1, 3, 0).WillReturnResult(sqlxmock.NewResult(0, 1))
+					mock.ExpectCommit()
+				}
+			}
+		}
+		if !v.updateErr && v.idErr == nil {
+			if v.getErr != nil {
+				mock.ExpectQuery(getQuery).WillReturnError(v.getErr)
+			} else {
+				mock.ExpectQuery(getQuery).WillReturnRows(sqlxmock.NewRows([]string{"id", "isbn", "title", "userId", "status", "pageCount", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 2, 0, "goodreads"))
+				expectNames(mock, nil, [][]driver.Value{{1, "Thriller"}})
+				expectReviews(mock, nil)
+			}
+		}
+		actRes, actErr := repo.Patch(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"}, v.patch)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
+
+func TestAddSession(t *testing.T) {
+	idQuery := regexp.QuoteMeta("SELECT id FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
+	insertQuery := regexp.QuoteMeta("INSERT INTO `reading_sessions` (bookId, startedAt, endedAt, startPage, endPage) VALUES (?, ?, ?, ?, ?)")
+	updateQuery := regexp.QuoteMeta("UPDATE `books` SET currentPage=? WHERE id = ?")
+	getQuery := regexp.QuoteMeta("SELECT * FROM `books` WHERE isbn = ? AND userId = ? AND deletedAt IS NULL")
+	startedAt := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	endedAt := time.Date(2021, 6, 1, 21, 0, 0, 0, time.UTC)
+	page := int64(80)
+	type testCase struct {
+		name      string
+		desc      string
+		expRes    *entities.Book
+		expErr    error
+		idErr     error
+		insertErr error
+		updateErr error
+	}
+	testCases := []testCase{
 		{
 			name: "Happy Case",
-			desc: "insert",
-			err:  sql.ErrNoRows,
+			desc: "all ok",
 			expRes: &entities.Book{
-				BookID: 99,
-				ISBN:   "9780751562774",
-				UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				BookID:      1,
+				ISBN:        "9780751562774",
+				Title:       "The Secrets She Keeps",
+				UserID:      "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+				Status:      entities.StatusReading,
+				PageCount:   300,
+				CurrentPage: 80,
+				Source:      "goodreads",
 			},
-			getErr: true,
-			insert: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "record not found",
+			idErr:  sql.ErrNoRows,
+			expErr: constant.ErrBookNotFound,
 		},
 		{
 			name:      "Sad Case",
 			desc:      "insert returns error",
-			err:       sql.ErrNoRows,
-			getErr:    true,
-			insert:    true,
-			insertErr: true,
+			insertErr: fmt.Errorf("mock error"),
+			expErr:    constant.ErrDBErr,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "update returns error",
+			updateErr: fmt.Errorf("mock error"),
 			expErr:    constant.ErrDBErr,
 		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		mock.ExpectBegin()
+		switch {
+		case v.idErr != nil:
+			mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnError(v.idErr)
+			mock.ExpectRollback()
+		case v.insertErr != nil:
+			mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectExec(insertQuery).WithArgs(1, startedAt, endedAt, 50, 80).WillReturnError(v.insertErr)
+			mock.ExpectRollback()
+		case v.updateErr != nil:
+			mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectExec(insertQuery).WithArgs(1, startedAt, endedAt, 50, 80).WillReturnResult(sqlxmock.NewResult(1, 1))
+			mock.ExpectExec(updateQuery).WithArgs(page, 1).WillReturnError(v.updateErr)
+			mock.ExpectRollback()
+		default:
+			mock.ExpectQuery(idQuery).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(1))
+			mock.ExpectExec(insertQuery).WithArgs(1, startedAt, endedAt, 50, 80).WillReturnResult(sqlxmock.NewResult(1, 1))
+			mock.ExpectExec(updateQuery).WithArgs(page, 1).WillReturnResult(sqlxmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			mock.ExpectQuery(getQuery).WillReturnRows(sqlxmock.NewRows([]string{"id", "isbn", "title", "userId", "status", "pageCount", "currentPage", "source"}).AddRow(1, "9780751562774", "The Secrets She Keeps", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 3, 300, 80, "goodreads"))
+			expectNames(mock, nil, nil)
+			expectReviews(mock, nil)
+		}
+		s := &entities.Session{StartedAt: startedAt, EndedAt: endedAt, StartPage: 50, EndPage: 80}
+		actRes, actErr := repo.AddSession(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"}, s, &entities.BookPatch{CurrentPage: &page})
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT s.bookId, s.startedAt, s.endedAt, s.startPage, s.endPage FROM `reading_sessions` s JOIN `books` b ON b.id = s.bookId WHERE b.userId = ? AND s.endedAt >= ? ORDER BY s.endedAt, s.id")
+	since := time.Date(2021, 5, 18, 0, 0, 0, 0, time.UTC)
+	startedAt := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	endedAt := time.Date(2021, 6, 1, 21, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name   string
+		desc   string
+		expRes []*entities.Session
+		expErr error
+		err    error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: []*entities.Session{{BookID: 1, StartedAt: startedAt, EndedAt: endedAt, StartPage: 50, EndPage: 80}},
+		},
 		{
 			name:   "Sad Case",
-			desc:   "get returns error",
-			err:    fmt.Errorf("mock get error"),
-			getErr: true,
+			desc:   "db returns error",
+			err:    fmt.Errorf("mock error"),
 			expErr: constant.ErrDBErr,
 		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.err != nil {
+			mock.ExpectQuery(query).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", since).WillReturnError(v.err)
+		} else {
+			mock.ExpectQuery(query).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", since).WillReturnRows(sqlxmock.NewRows([]string{"bookId", "startedAt", "endedAt", "startPage", "endPage"}).AddRow(1, startedAt, endedAt, 50, 80))
+		}
+		actRes, actErr := repo.ListSessions(context.Background(), "8BeqLfieIiTOkruBBrQ6p8jOTsk2", since)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
+
+func TestStats(t *testing.T) {
+	statusQuery := regexp.QuoteMeta("SELECT status, COUNT(*) AS n FROM `books` WHERE userId = ? AND deletedAt IS NULL GROUP BY status ORDER BY status")
+	pagesQuery := regexp.QuoteMeta("SELECT DATE_FORMAT(s.endedAt, '%Y-%m') AS period, SUM(CASE WHEN s.endPage > s.startPage THEN s.endPage - s.startPage ELSE 0 END) AS n FROM `reading_sessions` s")
+	finishedQuery := regexp.QuoteMeta("SELECT DATE_FORMAT(finishedAt, '%Y') AS period, COUNT(*) AS n FROM `books`")
+	authorsQuery := regexp.QuoteMeta("SELECT t.name, COUNT(*) AS n FROM `book_authors` j JOIN `authors` t ON t.id = j.authorId")
+	categoriesQuery := regexp.QuoteMeta("SELECT t.name, COUNT(*) AS n FROM `book_categories` j JOIN `categories` t ON t.id = j.categoryId")
+	publishersQuery := regexp.QuoteMeta("SELECT publisher AS name, COUNT(*) AS n FROM `books`")
+	yearsQuery := regexp.QuoteMeta("SELECT publicationYear AS year, COUNT(*) AS n FROM `books`")
+	ratingQuery := regexp.QuoteMeta("SELECT COALESCE(AVG(r.rating), 0) AS rating, COUNT(*) AS n FROM `reviews` r")
+	type testCase struct {
+		name      string
+		desc      string
+		expRes    *entities.Stats
+		expErr    error
+		statusErr error
+		ratingErr error
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+			expRes: &entities.Stats{
+				Statuses:         []entities.StatusCount{{Status: entities.StatusReading, Count: 1}, {Status: entities.StatusRead, Count: 2}},
+				PagesPerMonth:    []entities.PeriodCount{{Period: "2021-06", Count: 120}},
+				FinishedPerYear:  []entities.PeriodCount{{Period: "2021", Count: 2}},
+				TopAuthors:       []entities.NameCount{{Name: "Terry Pratchett", Count: 3}},
+				TopCategories:    []entities.NameCount{{Name: "Fantasy", Count: 2}},
+				TopPublishers:    []entities.NameCount{{Name: "Corgi", Count: 3}},
+				PublicationYears: []entities.YearCount{{Year: 1983, Count: 3}},
+				AverageRating:    7.5,
+				Rated:            2,
+			},
+		},
 		{
 			name:      "Sad Case",
-			desc:      "Update returns error",
-			err:       fmt.Errorf("update returns error"),
-			updateErr: true,
+			desc:      "aggregate returns error",
+			statusErr: fmt.Errorf("mock error"),
+			expErr:    constant.ErrDBErr,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "average rating returns error",
+			ratingErr: fmt.Errorf("mock error"),
 			expErr:    constant.ErrDBErr,
-			update:    true,
 		},
 	}
 	for _, v := range testCases {
 		db, mock := NewMockDb()
 		repo := NewDbRepo(db)
-
-		if v.getErr {
-			mock.ExpectQuery(getQuery).WillReturnError(v.err)
+		if v.statusErr != nil {
+			mock.ExpectQuery(statusQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnError(v.statusErr)
 		} else {
-			mock.ExpectQuery(getQuery).WillReturnRows(getRows)
-		}
-		if v.update {
-			if v.updateErr {
-				mock.ExpectExec(updateQuery).WillReturnError(v.err)
+			mock.ExpectQuery(statusQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"status", "n"}).AddRow(3, 1).AddRow(4, 2))
+			mock.ExpectQuery(pagesQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"period", "n"}).AddRow("2021-06", 120))
+			mock.ExpectQuery(finishedQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", entities.StatusRead).WillReturnRows(sqlxmock.NewRows([]string{"period", "n"}).AddRow("2021", 2))
+			mock.ExpectQuery(authorsQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", entities.TopStats).WillReturnRows(sqlxmock.NewRows([]string{"name", "n"}).AddRow("Terry Pratchett", 3))
+			mock.ExpectQuery(categoriesQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", entities.TopStats).WillReturnRows(sqlxmock.NewRows([]string{"name", "n"}).AddRow("Fantasy", 2))
+			mock.ExpectQuery(publishersQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2", entities.TopStats).WillReturnRows(sqlxmock.NewRows([]string{"name", "n"}).AddRow("Corgi", 3))
+			mock.ExpectQuery(yearsQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"year", "n"}).AddRow(1983, 3))
+			if v.ratingErr != nil {
+				mock.ExpectQuery(ratingQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnError(v.ratingErr)
 			} else {
-				mock.ExpectExec(updateQuery).WillReturnResult(sqlxmock.NewResult(1, 1))
+				mock.ExpectQuery(ratingQuery).WithArgs("8BeqLfieIiTOkruBBrQ6p8jOTsk2").WillReturnRows(sqlxmock.NewRows([]string{"rating", "n"}).AddRow([]byte("7.5000"), 2))
 			}
 		}
+		actRes, actErr := repo.Stats(context.Background(), "8BeqLfieIiTOkruBBrQ6p8jOTsk2")
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
 
-		if v.insert {
-			if v.insertErr {
-				mock.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("mock insert error"))
-			} else {
-				mock.ExpectExec(insertQuery).WillReturnResult(sqlxmock.NewResult(99, 1))
-			}
-		}
+func TestPostgres(t *testing.T) {
+	db, mock := NewMockDb()
+	repo := NewPostgresRepo(db)
 
-		actRes, actErr := repo.Upsert(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
+	upsert := regexp.QuoteMeta(`INSERT INTO "books" (isbn, title, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, language, source, startedAt, finishedAt) VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14) ON CONFLICT(userId, isbn) DO UPDATE SET`)
+	mock.ExpectBegin()
+	mock.ExpectQuery(upsert).WithArgs("9780751562774", "The Secrets She Keeps", "", "", int64(0), "", "8BeqLfieIiTOkruBBrQ6p8jOTsk2", int64(1), "", int64(0), "", "", nil, nil).
+		WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(99))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "book_authors" WHERE bookId = $1`)).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "authors" (name) VALUES($1) ON CONFLICT(name) DO UPDATE SET name=excluded.name RETURNING id`)).WithArgs("Michael Robotham").
+		WillReturnRows(sqlxmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "book_authors" (bookId, authorId, position) VALUES($1, $2, $3)`)).WithArgs(99, 7, 0).WillReturnResult(sqlxmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "book_categories" WHERE bookId = $1`)).WithArgs(99).WillReturnResult(sqlxmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT rating, review, createdAt, updatedAt FROM "reviews" WHERE bookId = $1`)).WithArgs(99).
+		WillReturnRows(sqlxmock.NewRows([]string{"rating", "review", "createdat", "updatedat"}))
+	mock.ExpectCommit()
+	actRes, actErr := repo.Upsert(context.Background(), &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2", Status: 1, Authors: []string{"Michael Robotham"}})
+	assert.NoError(t, actErr)
+	assert.Equal(t, int64(99), actRes.BookID)
 
-		assert.Equal(t, v.expRes, actRes)
-		assert.Equal(t, v.expErr, actErr)
+	// Postgres returns unquoted column names in lower case
+	get := regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1 AND userId = $2 AND deletedAt IS NULL`)
+	mock.ExpectQuery(get).WithArgs("9780751562774", "8BeqLfieIiTOkruBBrQ6p8jOTsk2").
+		WillReturnRows(sqlxmock.NewRows([]string{"id", "isbn", "title", "imageurl", "smallimageurl", "publicationyear", "userid", "status", "pagecount", "deletedat"}).
+			AddRow(99, "9780751562774", "The Secrets She Keeps", "https://images/large.png", "https://images/small.png", 2017, "8BeqLfieIiTOkruBBrQ6p8jOTsk2", 1, 432, nil))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT j.bookId, n.name FROM "book_authors" j JOIN "authors" n ON n.id = j.authorId WHERE j.bookId IN ($1)`)).WithArgs(99).
+		WillReturnRows(sqlxmock.NewRows([]string{"bookid", "name"}).AddRow(99, "Michael Robotham"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT j.bookId, n.name FROM "book_categories" j JOIN "categories" n ON n.id = j.categoryId WHERE j.bookId IN ($1)`)).WithArgs(99).
+		WillReturnRows(sqlxmock.NewRows([]string{"bookid", "name"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT bookId, rating, review, createdAt, updatedAt FROM "reviews" WHERE bookId IN ($1)`)).WithArgs(99).
+		WillReturnRows(sqlxmock.NewRows([]string{"bookid", "rating", "review", "createdat", "updatedat"}).AddRow(99, 9, "Gripping", reviewedAt, reviewedAt))
+	actRes, actErr = repo.Get(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"})
+	assert.NoError(t, actErr)
+	assert.Equal(t, &entities.Book{
+		BookID:          99,
+		ISBN:            "9780751562774",
+		Title:           "The Secrets She Keeps",
+		Authors:         []string{"Michael Robotham"},
+		ImageURL:        "https://images/large.png",
+		SmallImageURL:   "https://images/small.png",
+		PublicationYear: 2017,
+		UserID:          "8BeqLfieIiTOkruBBrQ6p8jOTsk2",
+		Status:          1,
+		PageCount:       432,
+		Review:          &entities.Review{Rating: 9, Text: "Gripping", CreatedAt: reviewedAt, UpdatedAt: reviewedAt},
+	}, actRes)
+
+	del := regexp.QuoteMeta(`UPDATE "books" SET deletedAt = $1 WHERE isbn = $2 AND userId = $3 AND deletedAt IS NULL`)
+	mock.ExpectExec(del).WillReturnResult(sqlxmock.NewResult(0, 0))
+	assert.Equal(t, constant.ErrBookNotFound, repo.Delete(context.Background(), &entities.Book{ISBN: "9780751562774", UserID: "8BeqLfieIiTOkruBBrQ6p8jOTsk2"}))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetLookup(t *testing.T) {
+	query := regexp.QuoteMeta("SELECT isbn, found, book, expiresAt FROM `book_lookups` WHERE isbn = ?")
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	type testCase struct {
+		name      string
+		desc      string
+		found     bool
+		book      string
+		expiresAt time.Time
+		noRows    bool
+		expRes    *entities.Lookup
+		expErr    error
+		err       error
+	}
+	testCases := []testCase{
+		{
+			name:      "Happy Case",
+			desc:      "all ok",
+			found:     true,
+			book:      `{"ISBN":"9780751562774","Title":"The Secrets She Keeps","Source":"google"}`,
+			expiresAt: expiresAt,
+			expRes:    &entities.Lookup{ISBN: "9780751562774", Book: &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps", Source: "google"}, ExpiresAt: expiresAt},
+		},
+		{
+			name:      "Happy Case",
+			desc:      "book not found by providers",
+			expiresAt: expiresAt,
+			expRes:    &entities.Lookup{ISBN: "9780751562774", ExpiresAt: expiresAt},
+		},
+		{
+			name:      "Sad Case",
+			desc:      "lookup expired",
+			found:     true,
+			book:      `{"ISBN":"9780751562774"}`,
+			expiresAt: time.Now().UTC().Add(-time.Minute),
+			expErr:    constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "lookup not cached",
+			noRows: true,
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "db returns error",
+			err:    fmt.Errorf("mock error"),
+			expErr: constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		switch {
+		case v.err != nil:
+			mock.ExpectQuery(query).WithArgs("9780751562774").WillReturnError(v.err)
+		case v.noRows:
+			mock.ExpectQuery(query).WithArgs("9780751562774").WillReturnRows(sqlxmock.NewRows([]string{"isbn", "found", "book", "expiresAt"}))
+		default:
+			mock.ExpectQuery(query).WithArgs("9780751562774").WillReturnRows(sqlxmock.NewRows([]string{"isbn", "found", "book", "expiresAt"}).AddRow("9780751562774", v.found, v.book, v.expiresAt))
+		}
+		actRes, actErr := repo.GetLookup(context.Background(), "9780751562774")
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
+	}
+}
+
+func TestPutLookup(t *testing.T) {
+	query := regexp.QuoteMeta("INSERT INTO `book_lookups` (isbn, found, book, expiresAt) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE found=VALUES(found), book=VALUES(book), expiresAt=VALUES(expiresAt)")
+	expiresAt := time.Date(2021, 6, 1, 21, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name    string
+		desc    string
+		book    *entities.Book
+		expArgs []driver.Value
+		expErr  error
+		err     error
+	}
+	testCases := []testCase{
+		{
+			name:    "Happy Case",
+			desc:    "all ok",
+			book:    &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps"},
+			expArgs: []driver.Value{"9780751562774", true, sqlxmock.AnyArg(), expiresAt},
+		},
+		{
+			name:    "Happy Case",
+			desc:    "book not found by providers",
+			expArgs: []driver.Value{"9780751562774", false, "", expiresAt},
+		},
+		{
+			name:    "Sad Case",
+			desc:    "db returns error",
+			expArgs: []driver.Value{"9780751562774", false, "", expiresAt},
+			err:     fmt.Errorf("mock error"),
+			expErr:  constant.ErrDBErr,
+		},
+	}
+	for _, v := range testCases {
+		db, mock := NewMockDb()
+		repo := NewDbRepo(db)
+		if v.err != nil {
+			mock.ExpectExec(query).WithArgs(v.expArgs...).WillReturnError(v.err)
+		} else {
+			mock.ExpectExec(query).WithArgs(v.expArgs...).WillReturnResult(sqlxmock.NewResult(0, 1))
+		}
+		actErr := repo.PutLookup(context.Background(), &entities.Lookup{ISBN: "9780751562774", Book: v.book, ExpiresAt: expiresAt})
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		assert.NoError(t, mock.ExpectationsWereMet(), v.desc)
 	}
 }
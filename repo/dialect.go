@@ -0,0 +1,78 @@
+package repo
+
+import (
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// dialect defines the SQL that differs between database engines
+type dialect struct {
+	name string
+	// bindType is the placeholder style of the engine, queries are written with ?
+	bindType int
+	// quote quotes identifiers, queries are written with backticks
+	quote string
+	// upsert inserts a book or updates it when the (userId, isbn) key already exists
+	upsert string
+	// upsertName inserts an author or category name into the %s table or finds the existing one
+	upsertName string
+	// upsertReview inserts the review of a book or updates it when the book already has one
+	upsertReview string
+	// upsertLookup inserts the cached lookup of a book or replaces the one cached for its isbn
+	upsertLookup string
+	// month and year format the time column %s as 2006-01 and 2006
+	month string
+	year  string
+	// returning is true when upsert and upsertName return the id of the record, otherwise it is read from LastInsertId
+	returning bool
+}
+
+// rebind rewrites a query written with backticks and ? placeholders into the dialect of the engine
+func (d dialect) rebind(query string) string {
+	if d.quote != "`" {
+		query = strings.ReplaceAll(query, "`", d.quote)
+	}
+	return sqlx.Rebind(d.bindType, query)
+}
+
+var mysqlDialect = dialect{
+	name:     "mysql",
+	bindType: sqlx.QUESTION,
+	quote:    "`",
+	// LAST_INSERT_ID(id) makes LastInsertId return the id of an updated record
+	upsert:       "INSERT INTO `books` (isbn, title, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, language, source, startedAt, finishedAt) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id), title=VALUES(title), imageUrl=VALUES(imageUrl), smallImageUrl=VALUES(smallImageUrl), publicationYear=VALUES(publicationYear), publisher=VALUES(publisher), status=VALUES(status), description=VALUES(description), pageCount=VALUES(pageCount), language=VALUES(language), source=VALUES(source), startedAt=VALUES(startedAt), finishedAt=VALUES(finishedAt), deletedAt=NULL",
+	upsertName:   "INSERT INTO `%s` (name) VALUES(?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id)",
+	upsertReview: "INSERT INTO `reviews` (bookId, rating, review, createdAt, updatedAt) VALUES(?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE rating=VALUES(rating), review=VALUES(review), updatedAt=VALUES(updatedAt)",
+	upsertLookup: "INSERT INTO `book_lookups` (isbn, found, book, expiresAt) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE found=VALUES(found), book=VALUES(book), expiresAt=VALUES(expiresAt)",
+	month:        "DATE_FORMAT(%s, '%%Y-%%m')",
+	year:         "DATE_FORMAT(%s, '%%Y')",
+}
+
+// SQLite stores times as RFC 3339 text in UTC, which its date functions do not parse with nanoseconds, months and years are prefixes
+var sqliteDialect = dialect{
+	name:         "sqlite",
+	bindType:     sqlx.QUESTION,
+	quote:        "`",
+	upsert:       "INSERT INTO `books` (isbn, title, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, language, source, startedAt, finishedAt) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(userId, isbn) DO UPDATE SET title=excluded.title, imageUrl=excluded.imageUrl, smallImageUrl=excluded.smallImageUrl, publicationYear=excluded.publicationYear, publisher=excluded.publisher, status=excluded.status, description=excluded.description, pageCount=excluded.pageCount, language=excluded.language, source=excluded.source, startedAt=excluded.startedAt, finishedAt=excluded.finishedAt, deletedAt=NULL RETURNING id",
+	upsertName:   "INSERT INTO `%s` (name) VALUES(?) ON CONFLICT(name) DO UPDATE SET name=excluded.name RETURNING id",
+	upsertReview: "INSERT INTO `reviews` (bookId, rating, review, createdAt, updatedAt) VALUES(?, ?, ?, ?, ?) ON CONFLICT(bookId) DO UPDATE SET rating=excluded.rating, review=excluded.review, updatedAt=excluded.updatedAt",
+	upsertLookup: "INSERT INTO `book_lookups` (isbn, found, book, expiresAt) VALUES(?, ?, ?, ?) ON CONFLICT(isbn) DO UPDATE SET found=excluded.found, book=excluded.book, expiresAt=excluded.expiresAt",
+	month:        "substr(%s, 1, 7)",
+	year:         "substr(%s, 1, 4)",
+	returning:    true,
+}
+
+// Postgres folds unquoted identifiers to lower case, columns are left unquoted and mapped case insensitively
+var postgresDialect = dialect{
+	name:         "postgres",
+	bindType:     sqlx.DOLLAR,
+	quote:        `"`,
+	upsert:       "INSERT INTO `books` (isbn, title, imageUrl, smallImageUrl, publicationYear, publisher, userId, status, description, pageCount, language, source, startedAt, finishedAt) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT(userId, isbn) DO UPDATE SET title=excluded.title, imageUrl=excluded.imageUrl, smallImageUrl=excluded.smallImageUrl, publicationYear=excluded.publicationYear, publisher=excluded.publisher, status=excluded.status, description=excluded.description, pageCount=excluded.pageCount, language=excluded.language, source=excluded.source, startedAt=excluded.startedAt, finishedAt=excluded.finishedAt, deletedAt=NULL RETURNING id",
+	upsertName:   "INSERT INTO `%s` (name) VALUES(?) ON CONFLICT(name) DO UPDATE SET name=excluded.name RETURNING id",
+	upsertReview: "INSERT INTO `reviews` (bookId, rating, review, createdAt, updatedAt) VALUES(?, ?, ?, ?, ?) ON CONFLICT(bookId) DO UPDATE SET rating=excluded.rating, review=excluded.review, updatedAt=excluded.updatedAt",
+	upsertLookup: "INSERT INTO `book_lookups` (isbn, found, book, expiresAt) VALUES(?, ?, ?, ?) ON CONFLICT(isbn) DO UPDATE SET found=excluded.found, book=excluded.book, expiresAt=excluded.expiresAt",
+	month:        "to_char(%s, 'YYYY-MM')",
+	year:         "to_char(%s, 'YYYY')",
+	returning:    true,
+}
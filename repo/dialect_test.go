@@ -0,0 +1,51 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	type testCase struct {
+		name    string
+		desc    string
+		dialect dialect
+		query   string
+		expRes  string
+	}
+	query := "SELECT * FROM `books` WHERE isbn = ? AND userId = ? LIMIT ? OFFSET ?"
+	testCases := []testCase{
+		{
+			name:    "Happy Case",
+			desc:    "mysql is unchanged",
+			dialect: mysqlDialect,
+			query:   query,
+			expRes:  query,
+		},
+		{
+			name:    "Happy Case",
+			desc:    "sqlite is unchanged",
+			dialect: sqliteDialect,
+			query:   query,
+			expRes:  query,
+		},
+		{
+			name:    "Happy Case",
+			desc:    "postgres uses double quotes and numbered placeholders",
+			dialect: postgresDialect,
+			query:   query,
+			expRes:  `SELECT * FROM "books" WHERE isbn = $1 AND userId = $2 LIMIT $3 OFFSET $4`,
+		},
+		{
+			name:    "Happy Case",
+			desc:    "postgres upsert",
+			dialect: postgresDialect,
+			query:   "INSERT INTO `books` (isbn, userId) VALUES(?, ?) ON CONFLICT(userId, isbn) DO UPDATE SET deletedAt=NULL RETURNING id",
+			expRes:  `INSERT INTO "books" (isbn, userId) VALUES($1, $2) ON CONFLICT(userId, isbn) DO UPDATE SET deletedAt=NULL RETURNING id`,
+		},
+	}
+	for _, v := range testCases {
+		assert.Equal(t, v.expRes, v.dialect.rebind(v.query), v.desc)
+	}
+}
@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/abx123/library/entities"
 )
@@ -10,5 +11,16 @@ import (
 type IdbRepo interface {
 	Get(context.Context, *entities.Book) (*entities.Book, error)
 	Upsert(context.Context, *entities.Book) (*entities.Book, error)
-	List(context.Context, int64, int64, string) ([]*entities.Book, error)
+	Patch(context.Context, *entities.Book, *entities.BookPatch) (*entities.Book, error)
+	List(context.Context, *entities.ListQuery) ([]*entities.Book, error)
+	Count(context.Context, *entities.ListQuery) (int64, error)
+	ListDeleted(context.Context, int64, int64, string) ([]*entities.Book, error)
+	Delete(context.Context, *entities.Book) error
+	Restore(context.Context, *entities.Book) error
+	Purge(context.Context, time.Time) (int64, error)
+	AddSession(context.Context, *entities.Book, *entities.Session, *entities.BookPatch) (*entities.Book, error)
+	ListSessions(context.Context, string, time.Time) ([]*entities.Session, error)
+	Stats(context.Context, string) (*entities.Stats, error)
+	GetLookup(context.Context, string) (*entities.Lookup, error)
+	PutLookup(context.Context, *entities.Lookup) error
 }
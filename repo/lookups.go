@@ -0,0 +1,65 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// bookLookup is a cached lookup, the book is stored as JSON
+type bookLookup struct {
+	ISBN      string    `db:"isbn"`
+	Found     bool      `db:"found"`
+	Book      string    `db:"book"`
+	ExpiresAt time.Time `db:"expiresAt"`
+}
+
+// GetLookup returns the cached lookup of the book with isbn, returns ErrBookNotFound when the lookup is not cached or expired
+func (r *DBRepo) GetLookup(ctx context.Context, isbn string) (*entities.Lookup, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpGetLookup)
+	defer cancel()
+	row := bookLookup{}
+	err := r.db.GetContext(ctx, &row, r.dialect.rebind("SELECT isbn, found, book, expiresAt FROM `book_lookups` WHERE isbn = ?"), isbn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, constant.ErrBookNotFound
+	}
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	// Expired lookups are left in the table until they are replaced
+	l := &entities.Lookup{ISBN: row.ISBN, ExpiresAt: row.ExpiresAt}
+	if l.Expired(time.Now()) {
+		return nil, constant.ErrBookNotFound
+	}
+	if row.Found {
+		l.Book = &entities.Book{}
+		if err = json.Unmarshal([]byte(row.Book), l.Book); err != nil {
+			return nil, dbError(ctx, err)
+		}
+	}
+	return l, nil
+}
+
+// PutLookup caches the lookup of a book, replaces the lookup cached for its isbn
+func (r *DBRepo) PutLookup(ctx context.Context, l *entities.Lookup) error {
+	ctx, cancel := r.timeouts.context(ctx, OpPutLookup)
+	defer cancel()
+	book := ""
+	if l.Book != nil {
+		b, err := json.Marshal(l.Book)
+		if err != nil {
+			return err
+		}
+		book = string(b)
+	}
+	// Execute Statement
+	if _, err := r.db.ExecContext(ctx, r.dialect.rebind(r.dialect.upsertLookup), l.ISBN, l.Book != nil, book, l.ExpiresAt.UTC()); err != nil {
+		return dbError(ctx, err)
+	}
+	return nil
+}
@@ -0,0 +1,553 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+type memKey struct {
+	userId string
+	isbn   string
+}
+
+// MemRepo defines an in-memory repository, it is intended for local development and tests
+type MemRepo struct {
+	mu       sync.RWMutex
+	lastID   int64
+	books    map[memKey]*entities.Book
+	sessions map[int64][]entities.Session
+	lookups  map[string]entities.Lookup
+}
+
+// NewMemRepo creates a new instance of MemRepo object
+func NewMemRepo() *MemRepo {
+	return &MemRepo{
+		books:    map[memKey]*entities.Book{},
+		sessions: map[int64][]entities.Session{},
+		lookups:  map[string]entities.Lookup{},
+	}
+}
+
+// Upsert updates the record if a record is found, inserts a new record if no record is found, upserting a deleted record restores it.
+func (r *MemRepo) Upsert(ctx context.Context, book *entities.Book) (*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := memKey{userId: book.UserID, isbn: book.ISBN}
+	b := *book
+	b.DeletedAt = nil
+	// Normalizing copies the names so the stored record does not share them with the caller
+	b.Authors = entities.NormalizeNames(book.Authors)
+	b.Categories = entities.NormalizeNames(book.Categories)
+	var prev *entities.Review
+	if existing, ok := r.books[k]; ok {
+		b.BookID = existing.BookID
+		// Reading progress is only written by reading sessions
+		b.CurrentPage = existing.CurrentPage
+		prev = existing.Review
+	} else {
+		r.lastID++
+		b.BookID = r.lastID
+	}
+	b.Review = prev.Update(book.Review, time.Now().UTC())
+	r.books[k] = &b
+	book.BookID = b.BookID
+	book.CurrentPage = b.CurrentPage
+	book.Review = cloneReview(b.Review)
+	book.Authors = entities.NormalizeNames(book.Authors)
+	book.Categories = entities.NormalizeNames(book.Categories)
+
+	return book, nil
+}
+
+// Get searches for a record match, deleted records are excluded
+func (r *MemRepo) Get(ctx context.Context, book *entities.Book) (*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, ok := r.books[memKey{userId: book.UserID, isbn: book.ISBN}]
+	if !ok || b.DeletedAt != nil {
+		return nil, constant.ErrBookNotFound
+	}
+	return clone(b), nil
+}
+
+// Patch updates the fields set in patch of the record that matches the search criteria, returns the updated record
+func (r *MemRepo) Patch(ctx context.Context, book *entities.Book, patch *entities.BookPatch) (*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.books[memKey{userId: book.UserID, isbn: book.ISBN}]
+	if !ok || b.DeletedAt != nil {
+		return nil, constant.ErrBookNotFound
+	}
+	applyPatch(b, patch)
+	return clone(b), nil
+}
+
+func applyPatch(b *entities.Book, patch *entities.BookPatch) {
+	if patch.Title != nil {
+		b.Title = *patch.Title
+	}
+	if patch.Authors != nil {
+		b.Authors = entities.NormalizeNames(*patch.Authors)
+	}
+	if patch.ImageURL != nil {
+		b.ImageURL = *patch.ImageURL
+	}
+	if patch.SmallImageURL != nil {
+		b.SmallImageURL = *patch.SmallImageURL
+	}
+	if patch.PublicationYear != nil {
+		b.PublicationYear = *patch.PublicationYear
+	}
+	if patch.Publisher != nil {
+		b.Publisher = *patch.Publisher
+	}
+	if patch.Status != nil {
+		b.Status = *patch.Status
+		b.StartedAt, b.FinishedAt = patch.StartedAt, patch.FinishedAt
+	}
+	if patch.Description != nil {
+		b.Description = *patch.Description
+	}
+	if patch.PageCount != nil {
+		b.PageCount = *patch.PageCount
+	}
+	if patch.Categories != nil {
+		b.Categories = entities.NormalizeNames(*patch.Categories)
+	}
+	if patch.Language != nil {
+		b.Language = *patch.Language
+	}
+	if patch.Source != nil {
+		b.Source = *patch.Source
+	}
+	if patch.Rating != nil || patch.Review != nil {
+		b.Review = b.Review.Update(entities.PatchedReview(b.Review, patch), time.Now().UTC())
+	}
+	if patch.CurrentPage != nil {
+		b.CurrentPage = *patch.CurrentPage
+	}
+}
+
+// AddSession records a reading session of the record that matches the search criteria and updates the fields set in patch, returns the updated record
+func (r *MemRepo) AddSession(ctx context.Context, book *entities.Book, s *entities.Session, patch *entities.BookPatch) (*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.books[memKey{userId: book.UserID, isbn: book.ISBN}]
+	if !ok || b.DeletedAt != nil {
+		return nil, constant.ErrBookNotFound
+	}
+	session := *s
+	session.BookID = b.BookID
+	r.sessions[b.BookID] = append(r.sessions[b.BookID], session)
+	applyPatch(b, patch)
+	return clone(b), nil
+}
+
+// ListSessions returns the reading sessions of the books of a user that ended at or after since, in the order they ended
+func (r *MemRepo) ListSessions(ctx context.Context, userId string, since time.Time) ([]*entities.Session, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sessions := []*entities.Session{}
+	for _, b := range r.books {
+		if b.UserID != userId {
+			continue
+		}
+		for _, s := range r.sessions[b.BookID] {
+			if !s.EndedAt.Before(since) {
+				s := s
+				sessions = append(sessions, &s)
+			}
+		}
+	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].EndedAt.Before(sessions[j].EndedAt)
+	})
+	return sessions, nil
+}
+
+// List returns the page of records that matches the query, deleted records are excluded
+func (r *MemRepo) List(ctx context.Context, q *entities.ListQuery) ([]*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkPage(q.Limit, q.Offset); err != nil {
+		return nil, err
+	}
+	for _, s := range q.Sort {
+		if !entities.SortFields[s.Field] {
+			return nil, fmt.Errorf("%w: cannot sort by %s", constant.ErrInvalidRequest, s.Field)
+		}
+	}
+	if q.After != nil && len(q.After.Values) != len(q.Sort) {
+		return nil, fmt.Errorf("%w: %s", constant.ErrInvalidRequest, entities.ErrInvalidCursor)
+	}
+	books := r.filter(func(b *entities.Book) bool {
+		return b.UserID == q.UserID && b.DeletedAt == nil && matches(b, q) && (q.After == nil || after(b, q.Sort, q.After))
+	})
+	sort.Slice(books, func(i, j int) bool {
+		return less(books[i], books[j], q.Sort)
+	})
+	return paginate(books, q.Limit, q.Offset), nil
+}
+
+// Count returns the number of records that match the filters of the query, deleted records are excluded
+func (r *MemRepo) Count(ctx context.Context, q *entities.ListQuery) (int64, error) {
+	if err := ctxError(ctx); err != nil {
+		return 0, err
+	}
+	books := r.filter(func(b *entities.Book) bool {
+		return b.UserID == q.UserID && b.DeletedAt == nil && matches(b, q)
+	})
+	return int64(len(books)), nil
+}
+
+// less reports whether a sorts before b, books are ordered by the sort fields and then by id
+func less(a, b *entities.Book, sort []entities.SortField) bool {
+	for _, s := range sort {
+		c := compare(entities.SortValue(a, s.Field), entities.SortValue(b, s.Field))
+		if c != 0 {
+			return (c < 0) != s.Desc
+		}
+	}
+	return a.BookID < b.BookID
+}
+
+// after reports whether b sorts after the cursor
+func after(b *entities.Book, sort []entities.SortField, c *entities.Cursor) bool {
+	for i, s := range sort {
+		cmp := compare(entities.SortValue(b, s.Field), c.Values[i])
+		if cmp != 0 {
+			return (cmp > 0) != s.Desc
+		}
+	}
+	return b.BookID > c.ID
+}
+
+// matches reports whether b matches the filters of q
+func matches(b *entities.Book, q *entities.ListQuery) bool {
+	switch {
+	case q.Status != nil && b.Status != *q.Status,
+		q.Author != "" && !contains(b.Authors, q.Author),
+		q.Category != "" && !contains(b.Categories, q.Category),
+		q.Language != "" && b.Language != q.Language,
+		q.Publisher != "" && b.Publisher != q.Publisher,
+		q.YearFrom != nil && b.PublicationYear < *q.YearFrom,
+		q.YearTo != nil && b.PublicationYear > *q.YearTo,
+		q.RatingFrom != nil && rating(b) < *q.RatingFrom,
+		q.RatingTo != nil && rating(b) > *q.RatingTo:
+		return false
+	}
+	title, description := strings.ToLower(b.Title), strings.ToLower(b.Description)
+	for _, w := range strings.Fields(strings.ToLower(q.Q)) {
+		if !strings.Contains(title, w) && !strings.Contains(description, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// rating returns the rating of b, 0 when b is not rated
+func rating(b *entities.Book) entities.Rating {
+	if b.Review == nil {
+		return 0
+	}
+	return b.Review.Rating
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares two sort values of the same field, returns a negative number when a sorts first
+func compare(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	case int64:
+		b, _ := b.(int64)
+		return compareInt(a, b)
+	}
+	return 0
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// ListDeleted returns list of deleted records that matches the search criteria, most recently deleted first
+func (r *MemRepo) ListDeleted(ctx context.Context, limit, offset int64, userId string) ([]*entities.Book, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	if err := checkPage(limit, offset); err != nil {
+		return nil, err
+	}
+	books := r.filter(func(b *entities.Book) bool {
+		return b.UserID == userId && b.DeletedAt != nil
+	})
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].DeletedAt.After(*books[j].DeletedAt)
+	})
+	return paginate(books, limit, offset), nil
+}
+
+func (r *MemRepo) filter(match func(*entities.Book) bool) []*entities.Book {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	books := []*entities.Book{}
+	for _, b := range r.books {
+		if match(b) {
+			books = append(books, clone(b))
+		}
+	}
+	return books
+}
+
+func paginate(books []*entities.Book, limit, offset int64) []*entities.Book {
+	if offset >= int64(len(books)) {
+		return []*entities.Book{}
+	}
+	books = books[offset:]
+	if limit < int64(len(books)) {
+		books = books[:limit]
+	}
+	return books
+}
+
+// Delete marks the record that matches the search criteria as deleted
+func (r *MemRepo) Delete(ctx context.Context, book *entities.Book) error {
+	if err := ctxError(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.books[memKey{userId: book.UserID, isbn: book.ISBN}]
+	if !ok || b.DeletedAt != nil {
+		return constant.ErrBookNotFound
+	}
+	now := time.Now().UTC()
+	b.DeletedAt = &now
+	return nil
+}
+
+// Restore unmarks the deleted record that matches the search criteria
+func (r *MemRepo) Restore(ctx context.Context, book *entities.Book) error {
+	if err := ctxError(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.books[memKey{userId: book.UserID, isbn: book.ISBN}]
+	if !ok || b.DeletedAt == nil {
+		return constant.ErrBookNotFound
+	}
+	b.DeletedAt = nil
+	return nil
+}
+
+// Purge permanently removes records deleted before the given time, returns the number of records removed
+func (r *MemRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	if err := ctxError(ctx); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for k, b := range r.books {
+		if b.DeletedAt != nil && b.DeletedAt.Before(before) {
+			delete(r.books, k)
+			delete(r.sessions, b.BookID)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Stats aggregates the books in the library of a user, deleted books are excluded
+func (r *MemRepo) Stats(ctx context.Context, userId string) (*entities.Stats, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	statuses := map[entities.Status]int64{}
+	pages, finished := map[string]int64{}, map[string]int64{}
+	authors, categories, publishers := map[string]int64{}, map[string]int64{}, map[string]int64{}
+	years := map[int64]int64{}
+	s := &entities.Stats{}
+	var ratings int64
+	for _, b := range r.books {
+		if b.UserID != userId || b.DeletedAt != nil {
+			continue
+		}
+		statuses[b.Status]++
+		for _, session := range r.sessions[b.BookID] {
+			pages[session.EndedAt.UTC().Format("2006-01")] += session.PagesRead()
+		}
+		if b.Status == entities.StatusRead && b.FinishedAt != nil {
+			finished[b.FinishedAt.UTC().Format("2006")]++
+		}
+		for _, n := range b.Authors {
+			authors[n]++
+		}
+		for _, n := range b.Categories {
+			categories[n]++
+		}
+		if b.Publisher != "" {
+			publishers[b.Publisher]++
+		}
+		if b.PublicationYear > 0 {
+			years[b.PublicationYear]++
+		}
+		if rating(b) > 0 {
+			ratings += int64(rating(b))
+			s.Rated++
+		}
+	}
+	for st, n := range statuses {
+		s.Statuses = append(s.Statuses, entities.StatusCount{Status: st, Count: n})
+	}
+	sort.Slice(s.Statuses, func(i, j int) bool { return s.Statuses[i].Status < s.Statuses[j].Status })
+	s.PagesPerMonth, s.FinishedPerYear = periodCounts(pages), periodCounts(finished)
+	s.TopAuthors, s.TopCategories, s.TopPublishers = topNames(authors), topNames(categories), topNames(publishers)
+	for y, n := range years {
+		s.PublicationYears = append(s.PublicationYears, entities.YearCount{Year: y, Count: n})
+	}
+	sort.Slice(s.PublicationYears, func(i, j int) bool { return s.PublicationYears[i].Year < s.PublicationYears[j].Year })
+	if s.Rated > 0 {
+		s.AverageRating = float64(ratings) / float64(s.Rated)
+	}
+	return s, nil
+}
+
+// periodCounts returns counts by period in ascending order
+func periodCounts(counts map[string]int64) []entities.PeriodCount {
+	var res []entities.PeriodCount
+	for p, n := range counts {
+		res = append(res, entities.PeriodCount{Period: p, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Period < res[j].Period })
+	return res
+}
+
+// topNames returns the TopStats names with the highest counts, ties are ordered by name
+func topNames(counts map[string]int64) []entities.NameCount {
+	var res []entities.NameCount
+	for name, n := range counts {
+		res = append(res, entities.NameCount{Name: name, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > entities.TopStats {
+		res = res[:entities.TopStats]
+	}
+	return res
+}
+
+// Close releases all records
+func (r *MemRepo) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.books = map[memKey]*entities.Book{}
+	r.sessions = map[int64][]entities.Session{}
+	r.lookups = map[string]entities.Lookup{}
+	return nil
+}
+
+// GetLookup returns the cached lookup of the book with isbn, returns ErrBookNotFound when the lookup is not cached or expired
+func (r *MemRepo) GetLookup(ctx context.Context, isbn string) (*entities.Lookup, error) {
+	if err := ctxError(ctx); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	l, ok := r.lookups[isbn]
+	if !ok || l.Expired(time.Now()) {
+		return nil, constant.ErrBookNotFound
+	}
+	if l.Book != nil {
+		l.Book = clone(l.Book)
+	}
+	return &l, nil
+}
+
+// PutLookup caches the lookup of a book, replaces the lookup cached for its isbn
+func (r *MemRepo) PutLookup(ctx context.Context, l *entities.Lookup) error {
+	if err := ctxError(ctx); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := *l
+	if c.Book != nil {
+		c.Book = clone(c.Book)
+	}
+	r.lookups[l.ISBN] = c
+	return nil
+}
+
+// clone returns a copy of b which shares no names or review with b
+func clone(b *entities.Book) *entities.Book {
+	c := *b
+	c.Authors = append([]string(nil), b.Authors...)
+	c.Categories = append([]string(nil), b.Categories...)
+	c.Review = cloneReview(b.Review)
+	return &c
+}
+
+func cloneReview(r *entities.Review) *entities.Review {
+	if r == nil {
+		return nil
+	}
+	c := *r
+	return &c
+}
+
+// ctxError maps the error of a done context to a constant error
+func ctxError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return constant.ErrTimeout
+	case context.Canceled:
+		return constant.ErrRequestCanceled
+	}
+	return nil
+}
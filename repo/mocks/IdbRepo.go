@@ -8,6 +8,8 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	entities "github.com/abx123/library/entities"
+
+	time "time"
 )
 
 // IdbRepo is an autogenerated mock type for the IdbRepo type
@@ -15,6 +17,64 @@ type IdbRepo struct {
 	mock.Mock
 }
 
+// AddSession provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IdbRepo) AddSession(_a0 context.Context, _a1 *entities.Book, _a2 *entities.Session, _a3 *entities.BookPatch) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Book, *entities.Session, *entities.BookPatch) *entities.Book); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.Book, *entities.Session, *entities.BookPatch) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Count provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) Count(_a0 context.Context, _a1 *entities.ListQuery) (int64, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.ListQuery) int64); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.ListQuery) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Delete provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) Delete(_a0 context.Context, _a1 *entities.Book) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Book) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: _a0, _a1
 func (_m *IdbRepo) Get(_a0 context.Context, _a1 *entities.Book) (*entities.Book, error) {
 	ret := _m.Called(_a0, _a1)
@@ -38,8 +98,54 @@ func (_m *IdbRepo) Get(_a0 context.Context, _a1 *entities.Book) (*entities.Book,
 	return r0, r1
 }
 
-// List provides a mock function with given fields: _a0, _a1, _a2, _a3
-func (_m *IdbRepo) List(_a0 context.Context, _a1 int64, _a2 int64, _a3 string) ([]*entities.Book, error) {
+// GetLookup provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) GetLookup(_a0 context.Context, _a1 string) (*entities.Lookup, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *entities.Lookup
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.Lookup); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Lookup)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) List(_a0 context.Context, _a1 *entities.ListQuery) ([]*entities.Book, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 []*entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.ListQuery) []*entities.Book); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.ListQuery) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDeleted provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IdbRepo) ListDeleted(_a0 context.Context, _a1 int64, _a2 int64, _a3 string) ([]*entities.Book, error) {
 	ret := _m.Called(_a0, _a1, _a2, _a3)
 
 	var r0 []*entities.Book
@@ -61,6 +167,124 @@ func (_m *IdbRepo) List(_a0 context.Context, _a1 int64, _a2 int64, _a3 string) (
 	return r0, r1
 }
 
+// ListSessions provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdbRepo) ListSessions(_a0 context.Context, _a1 string, _a2 time.Time) ([]*entities.Session, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 []*entities.Session
+	if rf, ok := ret.Get(0).(func(context.Context, string, time.Time) []*entities.Session); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entities.Session)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, time.Time) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Patch provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdbRepo) Patch(_a0 context.Context, _a1 *entities.Book, _a2 *entities.BookPatch) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Book, *entities.BookPatch) *entities.Book); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.Book, *entities.BookPatch) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Purge provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) Purge(_a0 context.Context, _a1 time.Time) (int64, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int64); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PutLookup provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) PutLookup(_a0 context.Context, _a1 *entities.Lookup) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Lookup) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Restore provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) Restore(_a0 context.Context, _a1 *entities.Book) error {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Book) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Stats provides a mock function with given fields: _a0, _a1
+func (_m *IdbRepo) Stats(_a0 context.Context, _a1 string) (*entities.Stats, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *entities.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.Stats); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Upsert provides a mock function with given fields: _a0, _a1
 func (_m *IdbRepo) Upsert(_a0 context.Context, _a1 *entities.Book) (*entities.Book, error) {
 	ret := _m.Called(_a0, _a1)
@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/abx123/library/entities"
+)
+
+// nameTable defines a table of unique names and the table joining them to books in order
+type nameTable struct {
+	table  string
+	join   string
+	column string
+}
+
+var (
+	authorsTable    = nameTable{table: "authors", join: "book_authors", column: "authorId"}
+	categoriesTable = nameTable{table: "categories", join: "book_categories", column: "categoryId"}
+)
+
+// bookName is a name joined to a book
+type bookName struct {
+	BookID int64  `db:"bookId"`
+	Name   string `db:"name"`
+}
+
+// replaceNames replaces the names joined to the book with id, names are normalized before they are stored
+func (r *DBRepo) replaceNames(ctx context.Context, tx *sqlx.Tx, t nameTable, id int64, names []string) error {
+	_, err := tx.ExecContext(ctx, r.dialect.rebind(fmt.Sprintf("DELETE FROM `%s` WHERE bookId = ?", t.join)), id)
+	if err != nil {
+		return err
+	}
+	for i, n := range entities.NormalizeNames(names) {
+		nameID, err := r.insertID(ctx, tx, fmt.Sprintf(r.dialect.upsertName, t.table), n)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(fmt.Sprintf("INSERT INTO `%s` (bookId, %s, position) VALUES(?, ?, ?)", t.join, t.column)), id, nameID, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadNames sets the authors and categories of books
+func (r *DBRepo) loadNames(ctx context.Context, books []*entities.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(books))
+	byID := make(map[int64]*entities.Book, len(books))
+	for i, b := range books {
+		ids[i] = b.BookID
+		byID[b.BookID] = b
+	}
+	authors, err := r.selectNames(ctx, authorsTable, ids)
+	if err != nil {
+		return err
+	}
+	for _, n := range authors {
+		byID[n.BookID].Authors = append(byID[n.BookID].Authors, n.Name)
+	}
+	categories, err := r.selectNames(ctx, categoriesTable, ids)
+	if err != nil {
+		return err
+	}
+	for _, n := range categories {
+		byID[n.BookID].Categories = append(byID[n.BookID].Categories, n.Name)
+	}
+	return nil
+}
+
+// selectNames returns the names joined to the books with ids, ordered by book and position
+func (r *DBRepo) selectNames(ctx context.Context, t nameTable, ids []int64) ([]bookName, error) {
+	query, args, err := sqlx.In(fmt.Sprintf("SELECT j.bookId, n.name FROM `%s` j JOIN `%s` n ON n.id = j.%s WHERE j.bookId IN (?) ORDER BY j.bookId, j.position", t.join, t.table, t.column), ids)
+	if err != nil {
+		return nil, err
+	}
+	names := []bookName{}
+	err = r.db.SelectContext(ctx, &names, r.dialect.rebind(query), args...)
+	return names, err
+}
@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// DSN schemes of the supported storage backends, a DSN without scheme is a MySQL DSN
+const (
+	SchemeMySQL      = "mysql://"
+	SchemeSQLite     = "sqlite://"
+	SchemeMemory     = "mem://"
+	SchemePostgres   = "postgres://"
+	SchemePostgreSQL = "postgresql://"
+)
+
+// Store defines a repository backed by a storage backend which has to be closed after use
+type Store interface {
+	IdbRepo
+	Close() error
+}
+
+// Open opens the storage backend selected by the scheme of dsn:
+// mem:// opens an empty in-memory repository, sqlite://path opens the SQLite database at path, sqlite://:memory: opens a migrated in-memory SQLite database,
+// postgres://dsn or postgresql://dsn opens the Postgres database, mysql://dsn or a DSN without scheme opens the MySQL database.
+func Open(dsn string, timeouts Timeouts) (Store, error) {
+	switch {
+	case strings.HasPrefix(dsn, SchemeMemory):
+		return NewMemRepo(), nil
+	case strings.HasPrefix(dsn, SchemeSQLite):
+		return openSQLite(strings.TrimPrefix(dsn, SchemeSQLite), timeouts)
+	case strings.HasPrefix(dsn, SchemePostgres), strings.HasPrefix(dsn, SchemePostgreSQL):
+		return openPostgres(dsn, timeouts)
+	}
+	return openMySQL(strings.TrimPrefix(dsn, SchemeMySQL), timeouts)
+}
+
+func openMySQL(dsn string, timeouts Timeouts) (Store, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// deletedAt is scanned into time.Time
+	cfg.ParseTime = true
+	db, err := sqlx.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+	return NewDbRepo(db).WithTimeouts(timeouts), nil
+}
+
+func openSQLite(path string, timeouts Timeouts) (Store, error) {
+	memory := path == "" || path == ":memory:"
+	if memory {
+		path = "file::memory:"
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	// Concurrent writers wait for the database lock instead of failing
+	db, err := sqlx.Open("sqlite", path+sep+"_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	if memory {
+		// Every connection to :memory: opens a new database, a single connection keeps the database alive
+		db.SetMaxOpenConns(1)
+	}
+	r := NewSQLiteRepo(db).WithTimeouts(timeouts)
+	if memory {
+		// The database only lives as long as the process, it is always migrated
+		if err = r.migrate(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func openPostgres(dsn string, timeouts Timeouts) (Store, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sqlx.NewDb(sql.OpenDB(connector), "postgres")
+	return NewPostgresRepo(db).WithTimeouts(timeouts), nil
+}
+
+func (r *DBRepo) migrate() error {
+	m, err := r.Migrator()
+	if err != nil {
+		return err
+	}
+	if _, err = m.Up(context.Background()); err != nil {
+		return fmt.Errorf("migrating %s schema: %w", r.dialect.name, err)
+	}
+	return nil
+}
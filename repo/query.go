@@ -0,0 +1,148 @@
+package repo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// ratingColumn is the rating of a book in the reviews table, 0 when the book is not rated
+const ratingColumn = "COALESCE((SELECT rating FROM `reviews` WHERE bookId = `books`.id), 0)"
+
+// likeEscaper escapes the wildcards of a LIKE pattern with !, which is portable across engines unlike backslash
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// checkPage rejects a negative limit or offset, engines either fail on them or read a negative limit as no limit
+func checkPage(limit, offset int64) error {
+	if limit < 0 || offset < 0 {
+		return fmt.Errorf("%w: negative limit or offset", constant.ErrInvalidRequest)
+	}
+	return nil
+}
+
+// listQuery builds the statement selecting the page of books matching q, ordered by the sort fields of q and then by id
+func listQuery(q *entities.ListQuery) (string, []interface{}, error) {
+	if err := checkPage(q.Limit, q.Offset); err != nil {
+		return "", nil, err
+	}
+	where, args := filters(q)
+	order := []string{}
+	for _, s := range q.Sort {
+		if !entities.SortFields[s.Field] {
+			return "", nil, fmt.Errorf("%w: cannot sort by %s", constant.ErrInvalidRequest, s.Field)
+		}
+		if s.Desc {
+			order = append(order, column(s.Field)+" DESC")
+		} else {
+			order = append(order, column(s.Field)+" ASC")
+		}
+	}
+	order = append(order, "id ASC")
+	if q.After != nil {
+		cond, after, err := keyset(q.Sort, q.After)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+		args = append(args, after...)
+	}
+	args = append(args, q.Limit, q.Offset)
+
+	return fmt.Sprintf("SELECT * FROM `books` WHERE %s ORDER BY %s LIMIT ? OFFSET ?", strings.Join(where, " AND "), strings.Join(order, ", ")), args, nil
+}
+
+// countQuery builds the statement counting the books matching the filters of q
+func countQuery(q *entities.ListQuery) (string, []interface{}) {
+	where, args := filters(q)
+	return fmt.Sprintf("SELECT COUNT(*) FROM `books` WHERE %s", strings.Join(where, " AND ")), args
+}
+
+// filters returns the conditions and arguments matching the filters of q
+func filters(q *entities.ListQuery) ([]string, []interface{}) {
+	where := []string{"userId = ?", "deletedAt IS NULL"}
+	args := []interface{}{q.UserID}
+	if q.Status != nil {
+		where = append(where, "status = ?")
+		args = append(args, *q.Status)
+	}
+	if q.Author != "" {
+		where = append(where, hasName(authorsTable))
+		args = append(args, q.Author)
+	}
+	if q.Category != "" {
+		where = append(where, hasName(categoriesTable))
+		args = append(args, q.Category)
+	}
+	if q.Language != "" {
+		where = append(where, "language = ?")
+		args = append(args, q.Language)
+	}
+	if q.Publisher != "" {
+		where = append(where, "publisher = ?")
+		args = append(args, q.Publisher)
+	}
+	if q.YearFrom != nil {
+		where = append(where, "publicationYear >= ?")
+		args = append(args, *q.YearFrom)
+	}
+	if q.YearTo != nil {
+		where = append(where, "publicationYear <= ?")
+		args = append(args, *q.YearTo)
+	}
+	if q.RatingFrom != nil {
+		where = append(where, ratingColumn+" >= ?")
+		args = append(args, *q.RatingFrom)
+	}
+	if q.RatingTo != nil {
+		where = append(where, ratingColumn+" <= ?")
+		args = append(args, *q.RatingTo)
+	}
+	for _, w := range strings.Fields(strings.ToLower(q.Q)) {
+		where = append(where, "(LOWER(title) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!')")
+		pattern := "%" + likeEscaper.Replace(w) + "%"
+		args = append(args, pattern, pattern)
+	}
+	return where, args
+}
+
+// keyset returns the condition matching the books after the cursor in the sort order,
+// ie. for title,-publicationYear: title > ? OR (title = ? AND publicationYear < ?) OR (title = ? AND publicationYear = ? AND id > ?)
+func keyset(sort []entities.SortField, after *entities.Cursor) (string, []interface{}, error) {
+	if len(after.Values) != len(sort) {
+		return "", nil, fmt.Errorf("%w: %s", constant.ErrInvalidRequest, entities.ErrInvalidCursor)
+	}
+	fields := append(append([]entities.SortField{}, sort...), entities.SortField{Field: "id"})
+	values := append(append([]interface{}{}, after.Values...), after.ID)
+	or := []string{}
+	args := []interface{}{}
+	for i, f := range fields {
+		and := []string{}
+		for j := 0; j < i; j++ {
+			and = append(and, column(fields[j].Field)+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if f.Desc {
+			op = " < ?"
+		}
+		and = append(and, column(f.Field)+op)
+		args = append(args, values[i])
+		or = append(or, "("+strings.Join(and, " AND ")+")")
+	}
+	return "(" + strings.Join(or, " OR ") + ")", args, nil
+}
+
+// hasName returns the condition matching books joined to the name of table t
+func hasName(t nameTable) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM `%s` j JOIN `%s` n ON n.id = j.%s WHERE j.bookId = `books`.id AND n.name = ?)", t.join, t.table, t.column)
+}
+
+// column returns the column or expression of the sort field f
+func column(f string) string {
+	if f == entities.SortRating {
+		return ratingColumn
+	}
+	return f
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+func TestListQuery(t *testing.T) {
+	status := entities.StatusToRead
+	year := int64(1990)
+	type testCase struct {
+		name    string
+		desc    string
+		query   *entities.ListQuery
+		expRes  string
+		expArgs []interface{}
+		expErr  bool
+	}
+	testCases := []testCase{
+		{
+			name:    "Happy Case",
+			desc:    "no filters are ordered by id",
+			query:   &entities.ListQuery{UserID: "u", Limit: 10},
+			expRes:  "SELECT * FROM `books` WHERE userId = ? AND deletedAt IS NULL ORDER BY id ASC LIMIT ? OFFSET ?",
+			expArgs: []interface{}{"u", int64(10), int64(0)},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "every filter and sort field",
+			query: &entities.ListQuery{UserID: "u", Status: &status, Author: "Terry Pratchett", Category: "Fantasy", Language: "en", Publisher: "Gollancz", YearFrom: &year, YearTo: &year, Q: "Good 100%", Sort: []entities.SortField{{Field: "title"}, {Field: "publicationYear", Desc: true}}, Limit: 10, Offset: 20},
+			expRes: "SELECT * FROM `books` WHERE userId = ? AND deletedAt IS NULL AND status = ?" +
+				" AND EXISTS (SELECT 1 FROM `book_authors` j JOIN `authors` n ON n.id = j.authorId WHERE j.bookId = `books`.id AND n.name = ?)" +
+				" AND EXISTS (SELECT 1 FROM `book_categories` j JOIN `categories` n ON n.id = j.categoryId WHERE j.bookId = `books`.id AND n.name = ?)" +
+				" AND language = ? AND publisher = ? AND publicationYear >= ? AND publicationYear <= ?" +
+				" AND (LOWER(title) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!')" +
+				" AND (LOWER(title) LIKE ? ESCAPE '!' OR LOWER(description) LIKE ? ESCAPE '!')" +
+				" ORDER BY title ASC, publicationYear DESC, id ASC LIMIT ? OFFSET ?",
+			expArgs: []interface{}{"u", status, "Terry Pratchett", "Fantasy", "en", "Gollancz", int64(1990), int64(1990), "%good%", "%good%", "%100!%%", "%100!%%", int64(10), int64(20)},
+		},
+		{
+			name:    "Happy Case",
+			desc:    "keyset after cursor",
+			query:   &entities.ListQuery{UserID: "u", Sort: []entities.SortField{{Field: "title"}, {Field: "publicationYear", Desc: true}}, After: &entities.Cursor{Values: []interface{}{"Good Omens", int64(1990)}, ID: 7}, Limit: 10},
+			expRes:  "SELECT * FROM `books` WHERE userId = ? AND deletedAt IS NULL AND ((title > ?) OR (title = ? AND publicationYear < ?) OR (title = ? AND publicationYear = ? AND id > ?)) ORDER BY title ASC, publicationYear DESC, id ASC LIMIT ? OFFSET ?",
+			expArgs: []interface{}{"u", "Good Omens", "Good Omens", int64(1990), "Good Omens", int64(1990), int64(7), int64(10), int64(0)},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "cursor of another sort order",
+			query:  &entities.ListQuery{UserID: "u", Sort: []entities.SortField{{Field: "title"}}, After: &entities.Cursor{ID: 7}},
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown sort field",
+			query:  &entities.ListQuery{UserID: "u", Sort: []entities.SortField{{Field: "isbn; DROP TABLE books"}}},
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actArgs, actErr := listQuery(v.query)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expArgs, actArgs, v.desc)
+		assert.Equal(t, v.expErr, errors.Is(actErr, constant.ErrInvalidRequest), v.desc)
+	}
+}
@@ -0,0 +1,75 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/abx123/library/entities"
+)
+
+// bookReview is the review of a book
+type bookReview struct {
+	BookID int64 `db:"bookId"`
+	entities.Review
+}
+
+// replaceReview replaces the review of the book with id by the rating and text next returns for the stored review, returns the stored review.
+// The creation time of the review is kept and nothing is written when neither the rating nor the text changes
+func (r *DBRepo) replaceReview(ctx context.Context, tx *sqlx.Tx, id int64, next func(prev *entities.Review) *entities.Review) (*entities.Review, error) {
+	prev, err := r.selectReview(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	review := prev.Update(next(prev), time.Now().UTC())
+	switch {
+	case review == prev:
+		return prev, nil
+	case review == nil:
+		_, err = tx.ExecContext(ctx, r.dialect.rebind("DELETE FROM `reviews` WHERE bookId = ?"), id)
+	default:
+		_, err = tx.ExecContext(ctx, r.dialect.rebind(r.dialect.upsertReview), id, review.Rating, review.Text, review.CreatedAt, review.UpdatedAt)
+	}
+	return review, err
+}
+
+// selectReview returns the review of the book with id, nil when the book is not reviewed
+func (r *DBRepo) selectReview(ctx context.Context, tx *sqlx.Tx, id int64) (*entities.Review, error) {
+	review := &entities.Review{}
+	err := tx.GetContext(ctx, review, r.dialect.rebind("SELECT rating, review, createdAt, updatedAt FROM `reviews` WHERE bookId = ?"), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// loadReviews sets the reviews of books
+func (r *DBRepo) loadReviews(ctx context.Context, books []*entities.Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(books))
+	byID := make(map[int64]*entities.Book, len(books))
+	for i, b := range books {
+		ids[i] = b.BookID
+		byID[b.BookID] = b
+	}
+	query, args, err := sqlx.In("SELECT bookId, rating, review, createdAt, updatedAt FROM `reviews` WHERE bookId IN (?)", ids)
+	if err != nil {
+		return err
+	}
+	reviews := []bookReview{}
+	if err = r.db.SelectContext(ctx, &reviews, r.dialect.rebind(query), args...); err != nil {
+		return err
+	}
+	for i := range reviews {
+		byID[reviews[i].BookID].Review = &reviews[i].Review
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/abx123/library/entities"
+)
+
+// AddSession records a reading session of the record that matches the search criteria and updates the fields set in patch
+// in the same transaction, returns the updated record
+func (r *DBRepo) AddSession(ctx context.Context, book *entities.Book, s *entities.Session, patch *entities.BookPatch) (*entities.Book, error) {
+	sctx, cancel := r.timeouts.context(ctx, OpAddSession)
+	defer cancel()
+	err := r.withTx(sctx, func(tx *sqlx.Tx) error {
+		id, err := r.selectID(sctx, tx, book)
+		if err != nil {
+			return err
+		}
+		// Execute Statement
+		_, err = tx.ExecContext(sctx, r.dialect.rebind("INSERT INTO `reading_sessions` (bookId, startedAt, endedAt, startPage, endPage) VALUES (?, ?, ?, ?, ?)"), id, s.StartedAt, s.EndedAt, s.StartPage, s.EndPage)
+		if err != nil {
+			return err
+		}
+		return r.patchTx(sctx, tx, id, patch)
+	})
+	if err != nil {
+		return nil, dbError(sctx, err)
+	}
+	// Read the record back to return every field
+	return r.Get(ctx, book)
+}
+
+// ListSessions returns the reading sessions of the books of a user that ended at or after since, in the order they ended
+func (r *DBRepo) ListSessions(ctx context.Context, userId string, since time.Time) ([]*entities.Session, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpListSessions)
+	defer cancel()
+	sessions := []*entities.Session{}
+	err := r.db.SelectContext(ctx, &sessions, r.dialect.rebind("SELECT s.bookId, s.startedAt, s.endedAt, s.startPage, s.endPage FROM `reading_sessions` s JOIN `books` b ON b.id = s.bookId WHERE b.userId = ? AND s.endedAt >= ? ORDER BY s.endedAt, s.id"), userId, since)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	return sessions, nil
+}
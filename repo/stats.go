@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abx123/library/entities"
+)
+
+// Stats aggregates the books in the library of a user, deleted books are excluded
+func (r *DBRepo) Stats(ctx context.Context, userId string) (*entities.Stats, error) {
+	ctx, cancel := r.timeouts.context(ctx, OpStats)
+	defer cancel()
+	s := &entities.Stats{}
+	queries := []struct {
+		dest  interface{}
+		query string
+		args  []interface{}
+	}{
+		{&s.Statuses, "SELECT status, COUNT(*) AS n FROM `books` WHERE userId = ? AND deletedAt IS NULL GROUP BY status ORDER BY status", []interface{}{userId}},
+		{&s.PagesPerMonth, fmt.Sprintf("SELECT %s AS period, SUM(CASE WHEN s.endPage > s.startPage THEN s.endPage - s.startPage ELSE 0 END) AS n FROM `reading_sessions` s JOIN `books` b ON b.id = s.bookId WHERE b.userId = ? AND b.deletedAt IS NULL GROUP BY period ORDER BY period", fmt.Sprintf(r.dialect.month, "s.endedAt")), []interface{}{userId}},
+		{&s.FinishedPerYear, fmt.Sprintf("SELECT %s AS period, COUNT(*) AS n FROM `books` WHERE userId = ? AND deletedAt IS NULL AND status = ? AND finishedAt IS NOT NULL GROUP BY period ORDER BY period", fmt.Sprintf(r.dialect.year, "finishedAt")), []interface{}{userId, entities.StatusRead}},
+		{&s.TopAuthors, topNamesQuery(authorsTable), []interface{}{userId, entities.TopStats}},
+		{&s.TopCategories, topNamesQuery(categoriesTable), []interface{}{userId, entities.TopStats}},
+		{&s.TopPublishers, "SELECT publisher AS name, COUNT(*) AS n FROM `books` WHERE userId = ? AND deletedAt IS NULL AND publisher IS NOT NULL AND publisher <> '' GROUP BY publisher ORDER BY n DESC, publisher LIMIT ?", []interface{}{userId, entities.TopStats}},
+		{&s.PublicationYears, "SELECT publicationYear AS year, COUNT(*) AS n FROM `books` WHERE userId = ? AND deletedAt IS NULL AND publicationYear > 0 GROUP BY publicationYear ORDER BY publicationYear", []interface{}{userId}},
+	}
+	for _, q := range queries {
+		if err := r.db.SelectContext(ctx, q.dest, r.dialect.rebind(q.query), q.args...); err != nil {
+			return nil, dbError(ctx, err)
+		}
+	}
+	rating := struct {
+		Average float64 `db:"rating"`
+		Rated   int64   `db:"n"`
+	}{}
+	err := r.db.GetContext(ctx, &rating, r.dialect.rebind("SELECT COALESCE(AVG(r.rating), 0) AS rating, COUNT(*) AS n FROM `reviews` r JOIN `books` b ON b.id = r.bookId WHERE b.userId = ? AND b.deletedAt IS NULL AND r.rating > 0"), userId)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+	s.AverageRating, s.Rated = rating.Average, rating.Rated
+	return s, nil
+}
+
+// topNamesQuery selects the names of t joined to the most books of a user
+func topNamesQuery(t nameTable) string {
+	return fmt.Sprintf("SELECT t.name, COUNT(*) AS n FROM `%s` j JOIN `%s` t ON t.id = j.%s JOIN `books` b ON b.id = j.bookId WHERE b.userId = ? AND b.deletedAt IS NULL GROUP BY t.name ORDER BY n DESC, t.name LIMIT ?", t.join, t.table, t.column)
+}
@@ -0,0 +1,85 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Database operations which can be given their own timeout
+const (
+	OpGet          = "get"
+	OpList         = "list"
+	OpCount        = "count"
+	OpListDeleted  = "listDeleted"
+	OpUpsert       = "upsert"
+	OpPatch        = "patch"
+	OpDelete       = "delete"
+	OpRestore      = "restore"
+	OpPurge        = "purge"
+	OpAddSession   = "addSession"
+	OpListSessions = "listSessions"
+	OpStats        = "stats"
+	OpGetLookup    = "getLookup"
+	OpPutLookup    = "putLookup"
+)
+
+// ops are the operations which can be given their own timeout, in the order they are listed in errors
+var ops = []string{
+	OpGet, OpList, OpCount, OpListDeleted, OpUpsert, OpPatch, OpDelete, OpRestore,
+	OpPurge, OpAddSession, OpListSessions, OpStats, OpGetLookup, OpPutLookup,
+}
+
+// Timeouts defines the maximum duration of database operations, a zero duration means no timeout
+type Timeouts struct {
+	Default time.Duration
+	Ops     map[string]time.Duration
+}
+
+// ParseTimeouts parses a comma separated list of timeouts, ie: "3s,list=10s,purge=1m".
+// A bare duration sets the default timeout, op=duration overrides the timeout of a single operation, an unknown operation is an error.
+func ParseTimeouts(s string) (Timeouts, error) {
+	t := Timeouts{Ops: map[string]time.Duration{}}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		op, val := "", v
+		if i := strings.Index(v, "="); i >= 0 {
+			op, val = strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:])
+		}
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return Timeouts{}, fmt.Errorf("invalid timeout %q: %w", v, err)
+		}
+		if op == "" {
+			t.Default = d
+			continue
+		}
+		if !slices.Contains(ops, op) {
+			return Timeouts{}, fmt.Errorf("invalid timeout %q: unknown operation %q, expected one of %s", v, op, strings.Join(ops, ", "))
+		}
+		t.Ops[op] = d
+	}
+	return t, nil
+}
+
+// For returns the timeout of op, falls back to the default timeout
+func (t Timeouts) For(op string) time.Duration {
+	if d, ok := t.Ops[op]; ok {
+		return d
+	}
+	return t.Default
+}
+
+// context derives a context bound by the timeout of op
+func (t Timeouts) context(ctx context.Context, op string) (context.Context, context.CancelFunc) {
+	d := t.For(op)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
@@ -0,0 +1,56 @@
+package repo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		input  string
+		expRes Timeouts
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "default and per operation timeouts",
+			input:  "3s, list=10s,purge=1m",
+			expRes: Timeouts{Default: 3 * time.Second, Ops: map[string]time.Duration{OpList: 10 * time.Second, OpPurge: time.Minute}},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "empty string",
+			input:  "",
+			expRes: Timeouts{Ops: map[string]time.Duration{}},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "invalid duration",
+			input:  "3s,list=ten",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown operation",
+			input:  "3s,lsit=10s",
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := ParseTimeouts(v.input)
+		assert.Equal(t, v.expRes, actRes)
+		assert.Equal(t, v.expErr, actErr != nil)
+	}
+}
+
+func TestTimeoutsFor(t *testing.T) {
+	timeouts := Timeouts{Default: 3 * time.Second, Ops: map[string]time.Duration{OpList: 10 * time.Second}}
+	assert.Equal(t, 10*time.Second, timeouts.For(OpList))
+	assert.Equal(t, 3*time.Second, timeouts.For(OpGet))
+	assert.Equal(t, time.Duration(0), Timeouts{}.For(OpGet))
+}
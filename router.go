@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	goisbn "github.com/abx123/go-isbn"
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo-contrib/prometheus"
 	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
 
 	"github.com/abx123/library/handler"
 	"github.com/abx123/library/handler/middleware"
@@ -14,24 +16,46 @@ import (
 	"github.com/abx123/library/services"
 )
 
+const (
+	purgeInterval = time.Hour
+)
+
 type router struct {
-	port int
-	conn *sqlx.DB
+	port      int
+	repo      repo.IdbRepo
+	retention time.Duration
+	providers []services.ProviderConfig
+	rules     services.MergeRules
+	cache     services.CacheConfig
+	crawler   services.CrawlerConfig
 }
 
-// NewRouter creates a new router instance
-func NewRouter(port int, conn *sqlx.DB) *router {
+// NewRouter creates a new router instance, books are looked up through the chain of providers, merged by rules and cached.
+// The crawler provider scrapes book pages with the crawler config
+func NewRouter(port int, repo repo.IdbRepo, retention time.Duration, providers []services.ProviderConfig, rules services.MergeRules, cache services.CacheConfig, crawler services.CrawlerConfig) *router {
 	return &router{
-		port: port,
-		conn: conn,
+		port:      port,
+		repo:      repo,
+		retention: retention,
+		providers: providers,
+		rules:     rules,
+		cache:     cache,
+		crawler:   crawler,
 	}
 }
 
 func (router *router) InitRouter() *echo.Echo {
 
-	dbRepo := repo.NewDbRepo(router.conn)
 	gi := goisbn.NewGoISBN(goisbn.DEFAULT_PROVIDERS)
-	handler := handler.NewHandler(services.NewDbService(dbRepo), services.NewBookService(gi))
+	bookSvc := services.NewBookService(gi)
+	chain, err := bookSvc.Registry().Chain(router.providers)
+	if err != nil {
+		zap.L().Fatal(err.Error(), zap.Error(err))
+	}
+	dbSvc := services.NewDbService(router.repo)
+	books := services.NewCachedBooks(bookSvc.WithProviders(chain).WithMergeRules(router.rules).WithCrawler(router.crawler), router.repo, router.cache)
+	handler := handler.NewHandler(dbSvc, books)
+	go router.purge(dbSvc)
 	r := echo.New()
 
 	// Middleware
@@ -44,8 +68,27 @@ func (router *router) InitRouter() *echo.Echo {
 	r.GET("/:userId/book/:isbn", handler.GetBook)
 	r.GET("/:userId/books", handler.ListBook)
 	r.POST("/:userId/book", handler.UpsertBook)
+	r.PATCH("/:userId/book/:isbn", handler.PatchBook)
+	r.DELETE("/:userId/book/:isbn", handler.DeleteBook)
+	r.GET("/:userId/trash", handler.ListTrash)
+	r.GET("/:userId/stats", handler.GetStats)
+	r.POST("/:userId/book/:isbn/restore", handler.RestoreBook)
+	r.POST("/:userId/book/:isbn/progress", handler.ProgressBook)
 	r.GET("/book/:isbn", handler.GetNewBook)
+	r.GET("/diagnostics/providers", handler.GetProviders)
 
 	r.Start(fmt.Sprintf(":%d", router.port))
 	return r
 }
+
+// purge periodically removes books that have been in trash longer than the retention period
+func (router *router) purge(svc services.IdbService) {
+	for range time.Tick(purgeInterval) {
+		n, err := svc.Purge(context.Background(), router.retention)
+		if err != nil {
+			zap.L().Error(err.Error(), zap.Error(err))
+			continue
+		}
+		zap.L().Info("purged deleted books", zap.Int64("count", n))
+	}
+}
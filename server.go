@@ -1,38 +1,79 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
-
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/jmoiron/sqlx"
+	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/abx123/library/logger"
+	"github.com/abx123/library/repo"
+	"github.com/abx123/library/services"
+)
+
+const (
+	defaultRetention = 30 * 24 * time.Hour
+	defaultTimeouts  = "5s,purge=1m"
+	// go-isbn reports its errors as books not found, its timeout is what retries and opens the circuit breaker when it hangs
+	defaultProviders = "goisbn:3s,crawler:3s"
+)
+
+// Flags override the environment variables of the settings, they are all defined before the command line is parsed once in main
+var (
+	dsnFlag       = flag.String("dsn", "", "datasource string, mem:// for in-memory, sqlite://path for SQLite, postgres:// for Postgres, otherwise MySQL")
+	portFlag      = flag.Int("p", 0, "port on which the application should run on")
+	retentionFlag = flag.Duration("retention", 0, "how long deleted books are kept in trash before being purged")
+	timeoutFlag   = flag.String("dbtimeout", "", "database timeouts, ie: 5s,list=10s,purge=1m")
+	providersFlag = flag.String("providers", "", "chain of book metadata providers in lookup order, ie: google:2s,openlibrary:2s:stop,crawler")
+	mergeFlag     = flag.String("merge", "", "rules merging the fields of books found by several providers, ie: description=longest,imageURL=resolution")
+	cacheFlag     = flag.String("bookcache", "", "cache of books looked up from providers, ie: size=1000,ttl=24h,negative=1h,unconfirmed=5m,store=true")
+	crawlerFlag   = flag.String("crawler", "", "JSON config file of the crawler: baseURL, headers, cookies and extraction")
+	migrateFlag   = flag.Bool("migrate", false, "apply pending schema migrations on start")
 )
 
 func main() {
+	flag.Parse()
+
 	logger := logger.NewLogger()
 	zap.ReplaceGlobals(logger)
 
 	dsn := getDSN()
+	timeouts := getTimeouts()
+	store := initRepo(*dsn, timeouts)
+	defer store.Close()
+
+	// The migrate command runs as a one-off job, it only needs the database settings
+	if flag.Arg(0) == "migrate" {
+		if err := migrate(store, flag.Args()[1:]); err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+		return
+	}
+
 	port := getPort()
-	conn := initDb(*dsn)
-	defer conn.Close()
+	retention := getRetention()
+	providers := getProviders()
+	mergeRules := getMergeRules()
+	cache := getCache()
+	crawler := getCrawler()
+	migrateOnBoot := getMigrate()
+	if *migrateOnBoot {
+		if err := migrate(store, []string{"up"}); err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+	}
 
-	router := NewRouter(*port, conn)
+	router := NewRouter(*port, store, *retention, providers, mergeRules, cache, crawler)
 	router.InitRouter()
 }
 
 func getDSN() *string {
 	envdsn := os.Getenv("DSN")
-
-	dsn := flag.String("dsn", "", "mysql datasource string")
-
-	flag.Parse()
+	dsn := dsnFlag
 	if *dsn == "" {
 		dsn = &envdsn
 		fmt.Printf("-dsn flag not set, defaulting to %s \n", envdsn)
@@ -43,8 +84,7 @@ func getDSN() *string {
 
 func getPort() *int {
 	envport := os.Getenv("PORT")
-	port := flag.Int("p", 0, "port on which the application should run on")
-	flag.Parse()
+	port := portFlag
 	if *port == 0 {
 		p, err := strconv.Atoi(envport)
 		if err != nil {
@@ -56,11 +96,191 @@ func getPort() *int {
 	return port
 }
 
-func initDb(dsn string) *sqlx.DB {
-	db, err := sqlx.Open("mysql", dsn)
+func getRetention() *time.Duration {
+	envretention := os.Getenv("TRASH_RETENTION")
+	retention := retentionFlag
+	if *retention == 0 {
+		r := defaultRetention
+		if envretention != "" {
+			var err error
+			r, err = time.ParseDuration(envretention)
+			if err != nil {
+				zap.L().Fatal(err.Error(), zap.Error(err))
+			}
+		}
+		retention = &r
+		fmt.Printf("-retention flag not set, defaulting to %s \n", r)
+	}
+	return retention
+}
+
+func getTimeouts() repo.Timeouts {
+	envtimeout := os.Getenv("DB_TIMEOUT")
+	timeout := timeoutFlag
+	if *timeout == "" {
+		timeout = &envtimeout
+		if envtimeout == "" {
+			*timeout = defaultTimeouts
+		}
+		fmt.Printf("-dbtimeout flag not set, defaulting to %s \n", *timeout)
+	}
+	t, err := repo.ParseTimeouts(*timeout)
+	if err != nil {
+		zap.L().Fatal(err.Error(), zap.Error(err))
+	}
+	return t
+}
+
+func getProviders() []services.ProviderConfig {
+	envproviders := os.Getenv("BOOK_PROVIDERS")
+	providers := providersFlag
+	if *providers == "" {
+		providers = &envproviders
+		if envproviders == "" {
+			*providers = defaultProviders
+		}
+		fmt.Printf("-providers flag not set, defaulting to %s \n", *providers)
+	}
+	p, err := services.ParseProviders(*providers)
+	if err != nil {
+		zap.L().Fatal(err.Error(), zap.Error(err))
+	}
+	return p
+}
+
+func getMergeRules() services.MergeRules {
+	envmerge := os.Getenv("BOOK_MERGE")
+	merge := mergeFlag
+	if *merge == "" {
+		merge = &envmerge
+		if envmerge == "" {
+			*merge = services.DefaultMergeRules
+		}
+		fmt.Printf("-merge flag not set, defaulting to %s \n", *merge)
+	}
+	r, err := services.ParseMergeRules(*merge)
+	if err != nil {
+		zap.L().Fatal(err.Error(), zap.Error(err))
+	}
+	return r
+}
+
+func getCache() services.CacheConfig {
+	envcache := os.Getenv("BOOK_CACHE")
+	cache := cacheFlag
+	if *cache == "" {
+		cache = &envcache
+		if envcache == "" {
+			*cache = services.DefaultCache
+		}
+		fmt.Printf("-bookcache flag not set, defaulting to %s \n", *cache)
+	}
+	c, err := services.ParseCacheConfig(*cache)
+	if err != nil {
+		zap.L().Fatal(err.Error(), zap.Error(err))
+	}
+	return c
+}
+
+func getCrawler() services.CrawlerConfig {
+	envcrawler := os.Getenv("BOOK_CRAWLER")
+	crawler := crawlerFlag
+	if *crawler == "" {
+		crawler = &envcrawler
+	}
+	c := services.DefaultCrawlerConfig()
+	if *crawler == "" {
+		fmt.Printf("-crawler flag not set, defaulting to %s \n", c.BaseURL)
+	} else {
+		var err error
+		if c, err = services.LoadCrawlerConfig(*crawler); err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+	}
+
+	// The session cookie is a secret, set directly or as a file, ie: a mounted secret
+	cookie := os.Getenv("CRAWLER_COOKIE")
+	if path := os.Getenv("CRAWLER_COOKIE_FILE"); cookie == "" && path != "" {
+		f, err := os.ReadFile(path)
+		if err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+		cookie = string(f)
+	}
+	if cookie != "" {
+		var err error
+		if c, err = c.WithCookie(cookie); err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+	}
+	return c
+}
+
+func getMigrate() *bool {
+	envmigrate := os.Getenv("DB_MIGRATE")
+	migrate := migrateFlag
+	if !*migrate && envmigrate != "" {
+		m, err := strconv.ParseBool(envmigrate)
+		if err != nil {
+			zap.L().Fatal(err.Error(), zap.Error(err))
+		}
+		migrate = &m
+	}
+	return migrate
+}
+
+// migrate runs the migrate subcommand: migrate [up | down [steps] | version]
+func migrate(store repo.Store, args []string) error {
+	r, ok := store.(*repo.DBRepo)
+	if !ok {
+		zap.L().Info("storage backend has no schema, nothing to migrate")
+		return nil
+	}
+	m, err := r.Migrator()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		zap.L().Info("schema migrated", zap.Int("applied", n), zap.Int64("version", m.Latest()))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil {
+				return fmt.Errorf("invalid number of steps %q: %w", args[1], err)
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		zap.L().Info("schema reverted", zap.Int("reverted", n))
+	case "version":
+		v, err := m.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema version %d, latest %d \n", v, m.Latest())
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or version", cmd)
+	}
+	return nil
+}
+
+func initRepo(dsn string, timeouts repo.Timeouts) repo.Store {
+	store, err := repo.Open(dsn, timeouts)
 	if err != nil {
 		zap.L().Fatal(err.Error(), zap.Error(err))
 		return nil
 	}
-	return db
+	return store
 }
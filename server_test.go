@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abx123/library/repo"
+)
+
+// TestMain runs main with the newline separated arguments of LIBRARY_TEST_MAIN when it is set, tests run the server in a process of its own
+func TestMain(m *testing.M) {
+	if args := os.Getenv("LIBRARY_TEST_MAIN"); args != "" {
+		os.Args = append([]string{"library"}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestMigrateCommand(t *testing.T) {
+	dir := t.TempDir()
+	// The logger writes to ./logs
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "logs"), 0755))
+	dsn := "sqlite://" + filepath.Join(dir, "library.db")
+
+	// The migrate command only needs the database settings, the port and lookup settings are left unset
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Dir = dir
+	cmd.Env = append(withoutEnv(os.Environ(), "PORT", "DSN", "BOOK_PROVIDERS", "BOOK_MERGE", "BOOK_CACHE", "BOOK_CRAWLER"),
+		"LIBRARY_TEST_MAIN="+strings.Join([]string{"-dsn", dsn, "migrate", "up"}, "\n"))
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	store, err := repo.Open(dsn, repo.Timeouts{})
+	require.NoError(t, err)
+	defer store.Close()
+	m, err := store.(*repo.DBRepo).Migrator()
+	require.NoError(t, err)
+	v, err := m.Version(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, m.Latest(), v)
+}
+
+// withoutEnv returns the variables of env except names
+func withoutEnv(env []string, names ...string) []string {
+	res := []string{}
+	for _, e := range env {
+		name := strings.SplitN(e, "=", 2)[0]
+		keep := true
+		for _, n := range names {
+			if name == n {
+				keep = false
+			}
+		}
+		if keep {
+			res = append(res, e)
+		}
+	}
+	return res
+}
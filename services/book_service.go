@@ -2,13 +2,13 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	goisbn "github.com/abx123/go-isbn"
 	"go.uber.org/zap"
 
@@ -23,77 +23,116 @@ const (
 
 // BookService defines a book service
 type BookService struct {
-	isbn   goisbn.Queryer
-	client httpClient
+	isbn      goisbn.Queryer
+	client    httpClient
+	providers []Provider
+	rules     MergeRules
+	crawler   CrawlerConfig
 }
 
-// NewBookService creates a new instance of BookService
+// NewBookService creates a new instance of BookService, books are looked up with gi and the crawler without retries or circuit breakers
 func NewBookService(gi goisbn.Queryer) *BookService {
-	return &BookService{
-		isbn:   gi,
-		client: &http.Client{Timeout: timeout},
+	svc := &BookService{
+		isbn:    gi,
+		client:  &http.Client{Timeout: timeout},
+		crawler: DefaultCrawlerConfig(),
 	}
+	svc.rules, _ = ParseMergeRules(DefaultMergeRules)
+	svc.providers = []Provider{
+		{MetadataProvider: NewGoISBNProvider(ProviderGoISBN, gi), Policy: FailNext},
+		{MetadataProvider: ProviderFunc(ProviderCrawler, svc.crawl), Policy: FailNext},
+	}
+	return svc
 }
 
-func (svc *BookService) crawl(ctx context.Context, isbn string) (*entities.Book, error) {
-	req, _ := http.NewRequest(methodGet, fmt.Sprintf("https://isbndb.com/book/%s", isbn), nil)
-	req.Header.Set("cookie", "_ga=GA1.2.885462694.1626779278; SESSab6de86aea7caa3f48ba6097cf7cdcf6=EEgG0nrbk7rMaChfagD5rU6GRDSUF4ugoT5iePIMMkk; __stripe_mid=6fbb6b27-b7fc-4fdb-b2c1-7bb5781d032a841978; _gid=GA1.2.1646186259.1626935305; AWSALB=0gdmlLUlv6jOXKTEbbfAx2OQWsho065Xg+dbDxFh2nHgWaZ0bazyJ2+swZKgYOK4/QTRaBM17ITAXLVxWCG6h6JdNVuKIWPxN1tZXo7wdTqixu3akEgRQukgj6CQ; AWSALBCORS=0gdmlLUlv6jOXKTEbbfAx2OQWsho065Xg+dbDxFh2nHgWaZ0bazyJ2+swZKgYOK4/QTRaBM17ITAXLVxWCG6h6JdNVuKIWPxN1tZXo7wdTqixu3akEgRQukgj6CQ")
-	res, err := svc.client.Do(req)
+// WithMergeRules sets the rules fields found by several providers are merged with
+func (svc *BookService) WithMergeRules(rules MergeRules) *BookService {
+	svc.rules = rules
+	return svc
+}
 
-	if err != nil {
-		zap.L().Error(constant.ErrRetrievingBookDetails.Error(), zap.Error(err))
-		return nil, constant.ErrRetrievingBookDetails
+// WithProviders sets the chain of providers books are looked up with, in order
+func (svc *BookService) WithProviders(chain []Provider) *BookService {
+	svc.providers = chain
+	return svc
+}
+
+// Get returns details of a book merged from every provider of the chain which finds it, the providers are queried concurrently.
+// Providers which do not find the book are left out, failing providers are too unless their policy is to stop which fails the lookup.
+// A book not found after a provider failed is an unconfirmed ErrBookNotFound
+func (svc *BookService) Get(ctx context.Context, isbn string) (*entities.Book, error) {
+	if !svc.isbn.ValidateISBN(isbn) {
+		return nil, fmt.Errorf("%w: isbn %s is not valid", constant.ErrInvalidRequest, isbn)
 	}
-	defer res.Body.Close()
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		zap.L().Error(constant.ErrRetrievingBookDetails.Error(), zap.Error(err))
-		return nil, constant.ErrRetrievingBookDetails
+	isbn = canonicalISBN(isbn)
+	lookupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	books := make([]*entities.Book, len(svc.providers))
+	errs := make([]error, len(svc.providers))
+	var wg sync.WaitGroup
+	for i, p := range svc.providers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			books[i], errs[i] = p.lookup(lookupCtx, isbn)
+			if failed(errs[i]) && p.Policy == FailStop {
+				// The lookup fails, the other providers are abandoned
+				cancel()
+			}
+		}()
 	}
-	doc, _ := goquery.NewDocumentFromReader(res.Body)
-	book := &entities.Book{}
-	doc.Find("body div table tr").Each(func(i int, s *goquery.Selection) {
-		if s.Find("th").Text() == "Full Title" {
-			book.Title = s.Find("td").Text()
-		}
-		if s.Find("th").Text() == "ISBN13" {
-			book.ISBN = s.Find("td").Text()
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil, ctxError(ctx)
+	}
+
+	found := []*entities.Book{}
+	err := fmt.Errorf("%w: no provider", constant.ErrBookNotFound)
+	unconfirmed := false
+	for i, p := range svc.providers {
+		if errs[i] == nil {
+			if books[i].Source == "" {
+				books[i].Source = p.Name()
+			}
+			found = append(found, books[i])
+			continue
 		}
-		if s.Find("th").Text() == "Publisher" {
-			book.Publisher = s.Find("td").Text()
+		err = errs[i]
+		if errors.Is(err, constant.ErrBookNotFound) {
+			unconfirmed = unconfirmed || errors.Is(err, errUnconfirmed)
+			continue
 		}
-		if s.Find("th").Text() == "Authors" {
-			book.Authors = strings.TrimSpace(s.Find("td").Text())
+		unconfirmed = true
+		zap.L().Warn(constant.ErrRetrievingBookDetails.Error(), zap.String("provider", p.Name()), zap.Error(err))
+		if p.Policy == FailStop {
+			return nil, err
 		}
-	})
-
-	doc.Find("body div .container .col-md-3 object").Each(func(i int, s *goquery.Selection) {
-		if img, ok := s.Attr("data"); ok {
-			book.ImageURL = img
+	}
+	if len(found) == 0 {
+		if unconfirmed && !errors.Is(err, errUnconfirmed) && errors.Is(err, constant.ErrBookNotFound) {
+			// A provider which failed may know the book
+			err = fmt.Errorf("%w: %w", err, errUnconfirmed)
 		}
-	})
-	if book.Title == "" {
-		return nil, constant.ErrBookNotFound
+		return nil, err
 	}
-	book.Source = "isbndb_crawl"
-	book.Status = 1
-
-	return book, nil
+	return svc.merge(ctx, found), nil
 }
 
-// Get returns details of a book from providers
-func (svc *BookService) Get(ctx context.Context, isbn string) (*entities.Book, error) {
-	b, err := svc.isbn.Get(isbn)
-	if err != nil {
-		if err == constant.ErrBookNotFound {
-			b, err := svc.crawl(ctx, isbn)
-			if err != nil {
-				return nil, err
-			}
-			return b, nil
-		}
+// Providers returns the configuration and health of the providers of the chain, in order
+func (svc *BookService) Providers() []*entities.ProviderStatus {
+	statuses := make([]*entities.ProviderStatus, len(svc.providers))
+	for i, p := range svc.providers {
+		statuses[i] = p.Status()
 	}
+	return statuses
+}
 
-	return mapBookToEnitiy(b), nil
+// ctxError maps the error of the done context ctx
+func ctxError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return constant.ErrTimeout
+	}
+	return constant.ErrRequestCanceled
 }
 
 func mapBookToEnitiy(b *goisbn.Book) *entities.Book {
@@ -113,16 +152,16 @@ func mapBookToEnitiy(b *goisbn.Book) *entities.Book {
 
 	return &entities.Book{
 		Title:           b.Title,
-		ISBN:            isbn,
-		Authors:         strings.Join(b.Authors, ", "),
+		ISBN:            canonicalISBN(isbn),
+		Authors:         entities.NormalizeNames(b.Authors),
 		ImageURL:        imageURL,
 		SmallImageURL:   smallImageURL,
 		PublicationYear: publicationYear,
 		Publisher:       b.Publisher,
-		Status:          1,
+		Status:          entities.StatusOwned,
 		Description:     b.Description,
 		PageCount:       b.PageCount,
-		Categories:      strings.Join(b.Categories, ", "),
+		Categories:      entities.NormalizeNames(b.Categories),
 		Language:        b.Language,
 		Source:          b.Source,
 	}
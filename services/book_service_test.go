@@ -19,7 +19,7 @@ var book *entities.Book = &entities.Book{
 	BookID:          0,
 	ISBN:            "ISBN13",
 	Title:           "DUMMY",
-	Authors:         "kitefishBB",
+	Authors:         []string{"kitefishBB"},
 	ImageURL:        "imageURL",
 	SmallImageURL:   "smallImageURL",
 	PublicationYear: 2021,
@@ -27,7 +27,7 @@ var book *entities.Book = &entities.Book{
 	Status:          1,
 	Description:     "dummy description",
 	PageCount:       999,
-	Categories:      "dummy, category",
+	Categories:      []string{"dummy", "category"},
 	Language:        "en",
 	Source:          "google",
 }
@@ -94,6 +94,7 @@ func TestGet(t *testing.T) {
 		isbnValid  bool
 		goIsbnBook *goisbn.Book
 		expRes     *entities.Book
+		expSources map[string]string
 		expErr     error
 		goIsbnErr  error
 		crawlerErr error
@@ -106,7 +107,11 @@ func TestGet(t *testing.T) {
 			desc:       "all ok",
 			goIsbnBook: giBook,
 			expRes:     book,
-			isbnValid:  true,
+			expSources: map[string]string{
+				"isbn": "google", "title": "google", "authors": "google", "imageURL": "google", "smallImageURL": "google", "publicationYear": "google",
+				"publisher": "google", "description": "google", "pageCount": "google", "categories": "google", "language": "google",
+			},
+			isbnValid: true,
 		},
 		{
 			name: "Happy Case",
@@ -114,14 +119,15 @@ func TestGet(t *testing.T) {
 			expRes: &entities.Book{
 				ISBN:      "9781784756055",
 				Title:     "Unlucky 13",
-				Authors:   "James Patterson",
+				Authors:   []string{"James Patterson"},
 				Publisher: "BB Books",
 				ImageURL:  "https://images.isbndb.com/covers/60/55/9781784756055.jpg",
 				Source:    "isbndb_crawl",
 				Status:    1,
 			},
-			isbnValid: true,
-			goIsbnErr: constant.ErrBookNotFound,
+			expSources: map[string]string{"isbn": "isbndb_crawl", "title": "isbndb_crawl", "authors": "isbndb_crawl", "imageURL": "isbndb_crawl", "publisher": "isbndb_crawl"},
+			isbnValid:  true,
+			goIsbnErr:  constant.ErrBookNotFound,
 			jsonResp: `<!DOCTYPE html>
 				<html lang="en" dir="ltr" prefix="content: http://purl.org/rss/1.0/modules/content/  dc: http://purl.org/dc/terms/  foaf: http://xmlns.com/foaf/0.1/  og: http://ogp.me/ns#  rdfs: http://www.w3.org/2000/01/rdf-schema#  schema: http://schema.org/  sioc: http://rdfs.org/sioc/ns#  sioct: http://rdfs.org/sioc/types#  skos: http://www.w3.org/2004/02/skos/core#  xsd: http://www.w3.org/2001/XMLSchema# ">
 				  <head>
@@ -631,6 +637,20 @@ func TestGet(t *testing.T) {
 			respCode:   200,
 			expErr:     constant.ErrRetrievingBookDetails,
 		},
+		{
+			name:      "Sad Case",
+			desc:      "goisbn returns an error other than not found, crawler returns empty page",
+			isbnValid: true,
+			goIsbnErr: fmt.Errorf("mock error"),
+			jsonResp:  `<html></html>`,
+			respCode:  200,
+			expErr:    constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "invalid isbn",
+			expErr: constant.ErrInvalidRequest,
+		},
 	}
 
 	for _, v := range testCases {
@@ -652,8 +672,16 @@ func TestGet(t *testing.T) {
 			},
 		}
 		actRes, actErr := svc.Get(context.Background(), "dummy isbn")
-		assert.Equal(t, v.expRes, actRes)
-		assert.Equal(t, v.expErr, actErr)
+		if actRes != nil {
+			assert.Equal(t, v.expSources, actRes.Provenance, v.desc)
+			actRes.Provenance = nil
+		}
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		if v.expErr == nil {
+			assert.NoError(t, actErr, v.desc)
+		} else {
+			assert.ErrorIs(t, actErr, v.expErr, v.desc)
+		}
 	}
 }
 
@@ -696,7 +724,7 @@ func TestMapBookToEntity(t *testing.T) {
 			expRes: &entities.Book{
 				ISBN:            "ISBN10",
 				Title:           "DUMMY",
-				Authors:         "kitefishBB",
+				Authors:         []string{"kitefishBB"},
 				ImageURL:        "imageURL",
 				SmallImageURL:   "imageURL",
 				PublicationYear: 2021,
@@ -704,7 +732,7 @@ func TestMapBookToEntity(t *testing.T) {
 				Status:          1,
 				Description:     "dummy description",
 				PageCount:       999,
-				Categories:      "dummy, category",
+				Categories:      []string{"dummy", "category"},
 				Language:        "en",
 				Source:          "google",
 			},
@@ -739,7 +767,7 @@ func TestMapBookToEntity(t *testing.T) {
 			expRes: &entities.Book{
 				ISBN:            "ISBN10",
 				Title:           "DUMMY",
-				Authors:         "kitefishBB",
+				Authors:         []string{"kitefishBB"},
 				ImageURL:        "smallImageURL",
 				SmallImageURL:   "smallImageURL",
 				PublicationYear: 2021,
@@ -747,7 +775,7 @@ func TestMapBookToEntity(t *testing.T) {
 				Status:          1,
 				Description:     "dummy description",
 				PageCount:       999,
-				Categories:      "dummy, category",
+				Categories:      []string{"dummy", "category"},
 				Language:        "en",
 				Source:          "google",
 			},
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// DefaultCache caches 1000 lookups in memory, books for a day, books not found for an hour and books which may not be found
+// because a provider failed for 5 minutes
+const DefaultCache = "size=1000,ttl=24h,negative=1h,unconfirmed=5m"
+
+// Cache layers and results of the cache metrics
+const (
+	layerMemory = "memory"
+	layerStore  = "store"
+
+	resultHit         = "hit"
+	resultNegativeHit = "negative_hit"
+	resultMiss        = "miss"
+)
+
+var (
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "library",
+		Name:      "book_cache_requests_total",
+		Help:      "Lookups of books from providers by cache layer and result, a negative hit is a cached book not found.",
+	}, []string{"layer", "result"})
+	cacheCoalesced = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "library",
+		Name:      "book_cache_coalesced_total",
+		Help:      "Lookups of books which waited for the same lookup in flight instead of querying providers.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequests, cacheCoalesced)
+}
+
+// CacheConfig configures the cache of book lookups
+type CacheConfig struct {
+	// Size is the number of lookups cached in memory, 0 caches none
+	Size int
+	// TTL is how long a book found is cached, NegativeTTL how long a book not found is, 0 caches none
+	TTL         time.Duration
+	NegativeTTL time.Duration
+	// UnconfirmedTTL is how long a book not found is cached when a provider which may know it failed, ie: any go-isbn provider
+	UnconfirmedTTL time.Duration
+	// Store caches lookups in the book_lookups table too, they outlive restarts and are shared by replicas
+	Store bool
+}
+
+// ParseCacheConfig parses a comma separated list of settings, ie: "size=1000,ttl=24h,negative=1h,unconfirmed=5m,store=true"
+func ParseCacheConfig(s string) (CacheConfig, error) {
+	c := CacheConfig{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i := strings.Index(v, "=")
+		if i < 0 {
+			return CacheConfig{}, fmt.Errorf("invalid cache setting %q, expected name=value", v)
+		}
+		name, val := strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:])
+		var err error
+		switch name {
+		case "size":
+			c.Size, err = strconv.Atoi(val)
+		case "ttl":
+			c.TTL, err = time.ParseDuration(val)
+		case "negative":
+			c.NegativeTTL, err = time.ParseDuration(val)
+		case "unconfirmed":
+			c.UnconfirmedTTL, err = time.ParseDuration(val)
+		case "store":
+			c.Store, err = strconv.ParseBool(val)
+		default:
+			err = fmt.Errorf("unknown setting %q", name)
+		}
+		if err != nil {
+			return CacheConfig{}, fmt.Errorf("invalid cache setting %q: %w", v, err)
+		}
+	}
+	return c, nil
+}
+
+// CachedBooks caches the lookups of books of another Ibooks in memory and optionally in a LookupStore.
+// Concurrent lookups of the same book wait for a single lookup
+type CachedBooks struct {
+	next   Ibooks
+	store  LookupStore
+	config CacheConfig
+	memory *lruCache
+	flight *flightGroup
+	now    func() time.Time
+}
+
+// NewCachedBooks creates a new instance of CachedBooks caching the lookups of next, store is only used when the config enables it
+func NewCachedBooks(next Ibooks, store LookupStore, config CacheConfig) *CachedBooks {
+	if !config.Store {
+		store = nil
+	}
+	return &CachedBooks{
+		next:   next,
+		store:  store,
+		config: config,
+		memory: newLRUCache(config.Size),
+		flight: &flightGroup{calls: map[string]*flightCall{}},
+		now:    time.Now,
+	}
+}
+
+// Get returns details of a book from the cache, looks the book up when it is not cached.
+// Books which are not found are cached too, for a shorter time when a provider failed, other errors are not
+func (c *CachedBooks) Get(ctx context.Context, isbn string) (*entities.Book, error) {
+	key := canonicalISBN(isbn)
+	if l, ok := c.memory.get(key, c.now()); ok {
+		return c.hit(layerMemory, l)
+	}
+	cacheRequests.WithLabelValues(layerMemory, resultMiss).Inc()
+	if c.store != nil {
+		l, err := c.store.GetLookup(ctx, key)
+		if err == nil {
+			c.memory.put(l)
+			return c.hit(layerStore, l)
+		}
+		cacheRequests.WithLabelValues(layerStore, resultMiss).Inc()
+		if !errors.Is(err, constant.ErrBookNotFound) {
+			// The book is looked up when the store fails
+			zap.L().Warn(err.Error(), zap.Error(err))
+		}
+	}
+
+	call, inFlight := c.flight.do(key, func() (*entities.Lookup, error) {
+		// The lookup outlives the request which started it as other requests may be waiting for it
+		return c.lookup(context.WithoutCancel(ctx), isbn, key)
+	})
+	if inFlight {
+		cacheCoalesced.Inc()
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctxError(ctx)
+	case <-call.done:
+	}
+	if call.err != nil {
+		return nil, call.err
+	}
+	return lookupResult(call.lookup)
+}
+
+// Providers returns the configuration and health of the providers books are looked up with
+func (c *CachedBooks) Providers() []*entities.ProviderStatus {
+	return c.next.Providers()
+}
+
+// hit returns the book of a lookup cached in layer
+func (c *CachedBooks) hit(layer string, l *entities.Lookup) (*entities.Book, error) {
+	if l.Book == nil {
+		cacheRequests.WithLabelValues(layer, resultNegativeHit).Inc()
+	} else {
+		cacheRequests.WithLabelValues(layer, resultHit).Inc()
+	}
+	return lookupResult(l)
+}
+
+// lookup looks the book up and caches the result, returns the error when it is not cacheable
+func (c *CachedBooks) lookup(ctx context.Context, isbn, key string) (*entities.Lookup, error) {
+	b, err := c.next.Get(ctx, isbn)
+	ttl := c.config.TTL
+	if err != nil {
+		if !errors.Is(err, constant.ErrBookNotFound) {
+			return nil, err
+		}
+		ttl = c.config.NegativeTTL
+		if errors.Is(err, errUnconfirmed) {
+			// A book not found by every provider may be found once the others recover
+			ttl = c.config.UnconfirmedTTL
+		}
+	}
+	l := &entities.Lookup{ISBN: key, Book: b, ExpiresAt: c.now().Add(ttl)}
+	if ttl <= 0 {
+		return l, nil
+	}
+	c.memory.put(l)
+	if c.store != nil {
+		if err = c.store.PutLookup(ctx, l); err != nil {
+			zap.L().Warn(err.Error(), zap.Error(err))
+		}
+	}
+	return l, nil
+}
+
+// lookupResult returns a copy of the book of a lookup, callers may change it
+func lookupResult(l *entities.Lookup) (*entities.Book, error) {
+	if l.Book == nil {
+		return nil, constant.ErrBookNotFound
+	}
+	b := *l.Book
+	return &b, nil
+}
+
+// lruCache is a lookup cache of a fixed size which evicts the least recently used lookup
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{size: size, order: list.New(), items: map[string]*list.Element{}}
+}
+
+// get returns the lookup of isbn unless it is not cached or expired at now
+func (c *lruCache) get(isbn string, now time.Time) (*entities.Lookup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[isbn]
+	if !ok {
+		return nil, false
+	}
+	l := e.Value.(*entities.Lookup)
+	if l.Expired(now) {
+		c.order.Remove(e)
+		delete(c.items, isbn)
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return l, true
+}
+
+// put caches l, replaces the lookup cached for its isbn
+func (c *lruCache) put(l *entities.Lookup) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[l.ISBN]; ok {
+		e.Value = l
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[l.ISBN] = c.order.PushFront(l)
+	if c.order.Len() > c.size {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.items, e.Value.(*entities.Lookup).ISBN)
+	}
+}
+
+// flightGroup runs a single lookup of an isbn at a time, lookups started while one is in flight wait for it
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+// flightCall is a lookup in flight, its result is set when done is closed
+type flightCall struct {
+	done   chan struct{}
+	lookup *entities.Lookup
+	err    error
+}
+
+// do starts fn unless a lookup of key is in flight, returns the call and whether it was already in flight
+func (g *flightGroup) do(key string, fn func() (*entities.Lookup, error)) (*flightCall, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if call, ok := g.calls[key]; ok {
+		return call, true
+	}
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	go func() {
+		call.lookup, call.err = fn()
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(call.done)
+	}()
+	return call, false
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+	"github.com/abx123/library/repo"
+)
+
+// fakeBooks counts the lookups of books, lookups wait for release when it is set
+type fakeBooks struct {
+	calls   int64
+	book    *entities.Book
+	err     error
+	release chan struct{}
+}
+
+func (f *fakeBooks) Get(ctx context.Context, isbn string) (*entities.Book, error) {
+	atomic.AddInt64(&f.calls, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	b := *f.book
+	return &b, nil
+}
+
+func (f *fakeBooks) Providers() []*entities.ProviderStatus {
+	return nil
+}
+
+func TestParseCacheConfig(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		input  string
+		expRes CacheConfig
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "default cache",
+			input:  DefaultCache,
+			expRes: CacheConfig{Size: 1000, TTL: 24 * time.Hour, NegativeTTL: time.Hour, UnconfirmedTTL: 5 * time.Minute},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "cached in the store",
+			input:  "size=10, ttl=1h, store=true",
+			expRes: CacheConfig{Size: 10, TTL: time.Hour, Store: true},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "invalid size",
+			input:  "size=many",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown setting",
+			input:  "ttl=1h,stale=1h",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "missing value",
+			input:  "store",
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := ParseCacheConfig(v.input)
+		if v.expErr {
+			assert.Error(t, actErr, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+	}
+}
+
+func TestCachedBooks(t *testing.T) {
+	config := CacheConfig{Size: 10, TTL: time.Hour, NegativeTTL: time.Minute, UnconfirmedTTL: time.Second}
+	type testCase struct {
+		name     string
+		desc     string
+		config   CacheConfig
+		err      error
+		isbns    []string
+		elapsed  time.Duration
+		expCalls int64
+		expErr   error
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "book is cached by its ISBN-13",
+			config:   config,
+			isbns:    []string{"9780751562774", "0751562777", "978-0-7515-6277-4"},
+			expCalls: 1,
+		},
+		{
+			name:     "Happy Case",
+			desc:     "cached book expires",
+			config:   config,
+			isbns:    []string{"9780751562774", "9780751562774"},
+			elapsed:  time.Hour,
+			expCalls: 2,
+		},
+		{
+			name:     "Happy Case",
+			desc:     "least recently used book is evicted",
+			config:   CacheConfig{Size: 1, TTL: time.Hour},
+			isbns:    []string{"9780751562774", "9781407243207", "9780751562774"},
+			expCalls: 3,
+		},
+		{
+			name:     "Happy Case",
+			desc:     "nothing is cached in memory without a size",
+			config:   CacheConfig{TTL: time.Hour},
+			isbns:    []string{"9780751562774", "9780751562774"},
+			expCalls: 2,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found is cached",
+			config:   config,
+			err:      constant.ErrBookNotFound,
+			isbns:    []string{"9780751562774", "9780751562774"},
+			expCalls: 1,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found expires sooner",
+			config:   config,
+			err:      constant.ErrBookNotFound,
+			isbns:    []string{"9780751562774", "9780751562774"},
+			elapsed:  time.Minute,
+			expCalls: 2,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found by a provider which may have failed is cached",
+			config:   config,
+			err:      fmt.Errorf("%w: goisbn: %w", constant.ErrBookNotFound, errUnconfirmed),
+			isbns:    []string{"9780751562774", "9780751562774"},
+			elapsed:  time.Second - time.Millisecond,
+			expCalls: 1,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found by a provider which may have failed expires sooner",
+			config:   config,
+			err:      fmt.Errorf("%w: goisbn: %w", constant.ErrBookNotFound, errUnconfirmed),
+			isbns:    []string{"9780751562774", "9780751562774"},
+			elapsed:  time.Second,
+			expCalls: 2,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found by a provider which may have failed is not cached without a ttl",
+			config:   CacheConfig{Size: 10, TTL: time.Hour, NegativeTTL: time.Minute},
+			err:      fmt.Errorf("%w: goisbn: %w", constant.ErrBookNotFound, errUnconfirmed),
+			isbns:    []string{"9780751562774", "9780751562774"},
+			expCalls: 2,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "provider errors are not cached",
+			config:   config,
+			err:      constant.ErrRetrievingBookDetails,
+			isbns:    []string{"9780751562774", "9780751562774"},
+			expCalls: 2,
+			expErr:   constant.ErrRetrievingBookDetails,
+		},
+	}
+	for _, v := range testCases {
+		next := &fakeBooks{book: &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps"}, err: v.err}
+		svc := NewCachedBooks(next, nil, v.config)
+		now := time.Now()
+		for _, isbn := range v.isbns {
+			svc.now = func() time.Time { return now }
+			actRes, actErr := svc.Get(context.Background(), isbn)
+			if v.expErr != nil {
+				assert.ErrorIs(t, actErr, v.expErr, v.desc)
+				assert.Nil(t, actRes, v.desc)
+			} else {
+				assert.NoError(t, actErr, v.desc)
+				assert.Equal(t, "The Secrets She Keeps", actRes.Title, v.desc)
+			}
+			now = now.Add(v.elapsed)
+		}
+		assert.Equal(t, v.expCalls, next.calls, v.desc)
+	}
+}
+
+func TestCachedBooksStore(t *testing.T) {
+	ctx := context.Background()
+	store := repo.NewMemRepo()
+	book := &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps"}
+	config := CacheConfig{Size: 10, TTL: time.Hour, NegativeTTL: time.Minute, Store: true}
+
+	next := &fakeBooks{book: book}
+	actRes, err := NewCachedBooks(next, store, config).Get(ctx, "9780751562774")
+	require.NoError(t, err)
+	assert.Equal(t, book, actRes)
+	l, err := store.GetLookup(ctx, "9780751562774")
+	require.NoError(t, err)
+	assert.Equal(t, book, l.Book)
+
+	// Another instance, ie: after a restart, reads the book from the store
+	storeHits := testutil.ToFloat64(cacheRequests.WithLabelValues(layerStore, resultHit))
+	next = &fakeBooks{book: book}
+	actRes, err = NewCachedBooks(next, store, config).Get(ctx, "9780751562774")
+	require.NoError(t, err)
+	assert.Equal(t, book, actRes)
+	assert.Equal(t, int64(0), next.calls)
+	assert.Equal(t, storeHits+1, testutil.ToFloat64(cacheRequests.WithLabelValues(layerStore, resultHit)))
+
+	// The store is not used unless the config enables it
+	next = &fakeBooks{err: constant.ErrBookNotFound}
+	config.Store = false
+	_, err = NewCachedBooks(next, store, config).Get(ctx, "9781407243207")
+	assert.ErrorIs(t, err, constant.ErrBookNotFound)
+	_, err = store.GetLookup(ctx, "9781407243207")
+	assert.Equal(t, constant.ErrBookNotFound, err)
+}
+
+func TestCachedBooksCoalesced(t *testing.T) {
+	next := &fakeBooks{book: &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps"}, release: make(chan struct{})}
+	svc := NewCachedBooks(next, nil, CacheConfig{})
+	coalesced := testutil.ToFloat64(cacheCoalesced)
+	n := 5
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = svc.Get(context.Background(), "9780751562774")
+		}()
+	}
+	// Every lookup but the first waits for the first one
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(cacheCoalesced) == coalesced+float64(n-1)
+	}, time.Second, time.Millisecond)
+	close(next.release)
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int64(1), next.calls)
+}
+
+func TestCachedBooksCanceled(t *testing.T) {
+	next := &fakeBooks{book: &entities.Book{ISBN: "9780751562774", Title: "The Secrets She Keeps"}, release: make(chan struct{})}
+	svc := NewCachedBooks(next, nil, CacheConfig{Size: 10, TTL: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := svc.Get(ctx, "9780751562774")
+	assert.Equal(t, constant.ErrRequestCanceled, err)
+
+	// The lookup of the canceled request completes and is cached
+	close(next.release)
+	assert.Eventually(t, func() bool {
+		_, ok := svc.memory.get("9780751562774", time.Now())
+		return ok
+	}, time.Second, time.Millisecond)
+	actRes, err := svc.Get(context.Background(), "9780751562774")
+	require.NoError(t, err)
+	assert.Equal(t, "The Secrets She Keeps", actRes.Title)
+	assert.Equal(t, int64(1), next.calls)
+}
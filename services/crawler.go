@@ -0,0 +1,338 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// crawlSource is the source of the books found by the crawler
+const crawlSource = "isbndb_crawl"
+
+var crawlMissing = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "library",
+	Name:      "crawler_missing_fields_total",
+	Help:      "Fields of the extraction config not found on a book page by the crawler, a rise tells the page layout changed.",
+}, []string{"field"})
+
+func init() {
+	prometheus.MustRegister(crawlMissing)
+}
+
+// CrawlerConfig configures the crawler, it is read from a JSON file, the session cookie is a secret set apart, see WithCookie
+type CrawlerConfig struct {
+	// BaseURL is the site the book pages are requested from, the page of a book is BaseURL/book/isbn
+	BaseURL string            `json:"baseURL"`
+	Headers map[string]string `json:"headers"`
+	Cookies map[string]string `json:"cookies"`
+
+	Extraction Extraction `json:"extraction"`
+}
+
+// Extraction declares where the fields of a book are on a book page.
+// Most fields are rows of a table made of a label and a value, the others are elements found by a selector
+type Extraction struct {
+	// Rows selects the rows of the table, Label and Value select the label and the value of a row
+	Rows  string `json:"rows"`
+	Label string `json:"label"`
+	Value string `json:"value"`
+	// Items selects the items of a list value, ie: the links of the subjects, a value without items is comma separated
+	Items string `json:"items"`
+	// Labels maps a field to the label of its row
+	Labels map[string]string `json:"labels"`
+	// Elements maps a field to the element it is read from
+	Elements map[string]Element `json:"elements"`
+}
+
+// Element selects the first element matching Selector, the field is its attribute Attr or its text without one
+type Element struct {
+	Selector string `json:"selector"`
+	Attr     string `json:"attr,omitempty"`
+}
+
+// DefaultCrawlerConfig returns the config of the book pages of isbndb.com, without a cookie
+func DefaultCrawlerConfig() CrawlerConfig {
+	return CrawlerConfig{
+		BaseURL: "https://isbndb.com",
+		Headers: map[string]string{"Accept": "text/html"},
+		Cookies: map[string]string{},
+		Extraction: Extraction{
+			Rows:  "table tr",
+			Label: "th",
+			Value: "td",
+			Items: "a",
+			Labels: map[string]string{
+				"title":           "Full Title",
+				"isbn":            "ISBN13",
+				"authors":         "Authors",
+				"publisher":       "Publisher",
+				"publicationDate": "Publish Date",
+				"pageCount":       "Pages",
+				"language":        "Language",
+				"binding":         "Binding",
+				"subjects":        "Subjects",
+			},
+			Elements: map[string]Element{
+				"imageURL": {Selector: ".artwork object", Attr: "data"},
+			},
+		},
+	}
+}
+
+// crawlFields set the fields of a book the crawler extracts, by name. The items of a list field are its values,
+// a field of a single value has one
+var crawlFields = map[string]func(b *entities.Book, v []string){
+	"title":     func(b *entities.Book, v []string) { b.Title = v[0] },
+	"isbn":      func(b *entities.Book, v []string) { b.ISBN = canonicalISBN(v[0]) },
+	"authors":   func(b *entities.Book, v []string) { b.Authors = entities.NormalizeNames(v) },
+	"publisher": func(b *entities.Book, v []string) { b.Publisher = v[0] },
+	"imageURL":  func(b *entities.Book, v []string) { b.ImageURL = v[0] },
+	"publicationDate": func(b *entities.Book, v []string) {
+		// Dates are years, months or days of various layouts, ie: 2015, 2015-01-29, Jan 29, 2015
+		if y := yearPattern.FindString(v[0]); y != "" {
+			b.PublicationYear, _ = strconv.ParseInt(y, 10, 64)
+		}
+	},
+	"pageCount": func(b *entities.Book, v []string) {
+		b.PageCount, _ = strconv.ParseInt(numberPattern.FindString(v[0]), 10, 64)
+	},
+	"language": func(b *entities.Book, v []string) { b.Language = v[0] },
+	"binding":  func(b *entities.Book, v []string) { b.Binding = v[0] },
+	"subjects": func(b *entities.Book, v []string) { b.Categories = entities.NormalizeNames(v) },
+}
+
+// listFields are the fields holding several items
+var listFields = map[string]bool{"authors": true, "subjects": true}
+
+var (
+	yearPattern   = regexp.MustCompile(`\b\d{4}\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// LoadCrawlerConfig reads the JSON config file at path, the settings missing from the file are those of DefaultCrawlerConfig.
+// A setting of the file replaces the default one as a whole, ie: its extraction replaces every default selector
+func LoadCrawlerConfig(path string) (CrawlerConfig, error) {
+	f, err := os.ReadFile(path)
+	if err != nil {
+		return CrawlerConfig{}, fmt.Errorf("reading crawler config: %w", err)
+	}
+	c := CrawlerConfig{}
+	if err = json.Unmarshal(f, &c); err != nil {
+		return CrawlerConfig{}, fmt.Errorf("invalid crawler config %s: %w", path, err)
+	}
+	def := DefaultCrawlerConfig()
+	if c.BaseURL == "" {
+		c.BaseURL = def.BaseURL
+	}
+	if c.Headers == nil {
+		c.Headers = def.Headers
+	}
+	if c.Cookies == nil {
+		c.Cookies = def.Cookies
+	}
+	if len(c.Extraction.Labels) == 0 && len(c.Extraction.Elements) == 0 {
+		c.Extraction = def.Extraction
+	}
+	if err = c.Validate(); err != nil {
+		return CrawlerConfig{}, fmt.Errorf("invalid crawler config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// WithCookie adds the cookies of the Cookie header value cookie, ie: "SESS1234=abcd; _ga=GA1.2", overriding cookies of the same name
+func (c CrawlerConfig) WithCookie(cookie string) (CrawlerConfig, error) {
+	cookies, err := http.ParseCookie(strings.TrimSpace(cookie))
+	if err != nil {
+		return CrawlerConfig{}, fmt.Errorf("invalid crawler cookie: %w", err)
+	}
+	merged := make(map[string]string, len(c.Cookies)+len(cookies))
+	for k, v := range c.Cookies {
+		merged[k] = v
+	}
+	for _, ck := range cookies {
+		merged[ck.Name] = ck.Value
+	}
+	c.Cookies = merged
+	return c, nil
+}
+
+// Validate checks the base URL and the fields of the extraction, the title is required as a book is not found without one
+func (c CrawlerConfig) Validate() error {
+	if c.BaseURL == "" {
+		return fmt.Errorf("no base URL")
+	}
+	e := c.Extraction
+	for field := range e.Labels {
+		if _, ok := crawlFields[field]; !ok {
+			return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(crawlFieldNames(), ", "))
+		}
+		if e.Rows == "" || e.Label == "" || e.Value == "" {
+			return fmt.Errorf("field %q is a row but rows, label and value are not all set", field)
+		}
+	}
+	for field, el := range e.Elements {
+		if _, ok := crawlFields[field]; !ok {
+			return fmt.Errorf("unknown field %q, expected one of %s", field, strings.Join(crawlFieldNames(), ", "))
+		}
+		if el.Selector == "" {
+			return fmt.Errorf("field %q has no selector", field)
+		}
+	}
+	if _, ok := e.Labels["title"]; !ok {
+		if _, ok = e.Elements["title"]; !ok {
+			return fmt.Errorf("no title field")
+		}
+	}
+	return nil
+}
+
+func crawlFieldNames() []string {
+	names := make([]string, 0, len(crawlFields))
+	for n := range crawlFields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithCrawler sets the config of the crawler provider
+func (svc *BookService) WithCrawler(config CrawlerConfig) *BookService {
+	svc.crawler = config
+	return svc
+}
+
+// crawl scrapes the book page of the crawler site
+func (svc *BookService) crawl(ctx context.Context, isbn string) (*entities.Book, error) {
+	c := svc.crawler
+	req, err := http.NewRequestWithContext(ctx, methodGet, strings.TrimSuffix(c.BaseURL, "/")+"/book/"+isbn, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", constant.ErrRetrievingBookDetails, ProviderCrawler, err)
+	}
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range c.Cookies {
+		req.AddCookie(&http.Cookie{Name: k, Value: v})
+	}
+	res, err := svc.client.Do(req)
+	if err != nil {
+		zap.L().Error(constant.ErrRetrievingBookDetails.Error(), zap.Error(err))
+		return nil, constant.ErrRetrievingBookDetails
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		// The site has no page for the book
+		return nil, constant.ErrBookNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		zap.L().Error(constant.ErrRetrievingBookDetails.Error(), zap.Int("status", res.StatusCode))
+		return nil, constant.ErrRetrievingBookDetails
+	}
+	book, missing, err := c.Extraction.parse(res.Body)
+	if err != nil {
+		zap.L().Error(constant.ErrRetrievingBookDetails.Error(), zap.Error(err))
+		return nil, constant.ErrRetrievingBookDetails
+	}
+	if book.Title == "" {
+		return nil, constant.ErrBookNotFound
+	}
+	for _, field := range missing {
+		crawlMissing.WithLabelValues(field).Inc()
+	}
+	if len(missing) > 0 {
+		zap.L().Debug("crawler fields not found", zap.String("isbn", isbn), zap.Strings("fields", missing))
+	}
+	book.Source = crawlSource
+	book.Status = entities.StatusOwned
+	return book, nil
+}
+
+// parse extracts a book from the page r, missing lists the fields of the extraction which are not on the page in order
+func (e Extraction) parse(r io.Reader) (book *entities.Book, missing []string, err error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing book page: %w", err)
+	}
+	values := map[string][]string{}
+	if len(e.Labels) > 0 {
+		fields := make(map[string]string, len(e.Labels))
+		for field, label := range e.Labels {
+			fields[strings.ToLower(label)] = field
+		}
+		doc.Find(e.Rows).Each(func(_ int, s *goquery.Selection) {
+			field, ok := fields[strings.ToLower(strings.TrimSpace(s.Find(e.Label).First().Text()))]
+			if !ok {
+				return
+			}
+			values[field] = e.value(field, s.Find(e.Value).First())
+		})
+	}
+	for field, el := range e.Elements {
+		s := doc.Find(el.Selector).First()
+		v := strings.TrimSpace(s.Text())
+		if el.Attr != "" {
+			v, _ = s.Attr(el.Attr)
+		}
+		values[field] = e.split(field, v)
+	}
+
+	book = &entities.Book{}
+	for _, field := range crawlFieldNames() {
+		_, row := e.Labels[field]
+		_, element := e.Elements[field]
+		if !row && !element {
+			continue
+		}
+		if len(values[field]) == 0 {
+			missing = append(missing, field)
+			continue
+		}
+		crawlFields[field](book, values[field])
+	}
+	return book, missing, nil
+}
+
+// value returns the values of the value s of a row, the items of a list field or its text
+func (e Extraction) value(field string, s *goquery.Selection) []string {
+	if listFields[field] && e.Items != "" {
+		if items := s.Find(e.Items); items.Length() > 0 {
+			values := []string{}
+			items.Each(func(_ int, i *goquery.Selection) {
+				if v := strings.TrimSpace(i.Text()); v != "" {
+					values = append(values, v)
+				}
+			})
+			return values
+		}
+	}
+	return e.split(field, s.Text())
+}
+
+// split returns the trimmed text v of field, the text of a list field is comma separated, an empty text has no value
+func (e Extraction) split(field, v string) []string {
+	parts := []string{v}
+	if listFields[field] {
+		parts = strings.Split(v, ",")
+	}
+	values := []string{}
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			values = append(values, p)
+		}
+	}
+	return values
+}
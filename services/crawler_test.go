@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abx123/library/constant"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the crawler fixtures")
+
+// crawled is the golden result of parsing a fixture, the fields the crawler extracts and the fields missing from the page
+type crawled struct {
+	ISBN            string   `json:"isbn"`
+	Title           string   `json:"title"`
+	Authors         []string `json:"authors"`
+	ImageURL        string   `json:"imageURL"`
+	Publisher       string   `json:"publisher"`
+	PublicationYear int64    `json:"publicationYear"`
+	PageCount       int64    `json:"pageCount"`
+	Language        string   `json:"language"`
+	Binding         string   `json:"binding"`
+	Subjects        []string `json:"subjects"`
+	Missing         []string `json:"missing"`
+}
+
+// TestCrawlerGolden parses the book pages of testdata/crawler with the default extraction and compares the books to their golden files,
+// run with -update to write the golden files after a change of the parser
+func TestCrawlerGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "crawler", "*.html"))
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures)
+	for _, fixture := range fixtures {
+		t.Run(filepath.Base(fixture), func(t *testing.T) {
+			f, err := os.Open(fixture)
+			require.NoError(t, err)
+			defer f.Close()
+			book, missing, err := DefaultCrawlerConfig().Extraction.parse(f)
+			require.NoError(t, err)
+			act, err := json.MarshalIndent(crawled{
+				ISBN:            book.ISBN,
+				Title:           book.Title,
+				Authors:         book.Authors,
+				ImageURL:        book.ImageURL,
+				Publisher:       book.Publisher,
+				PublicationYear: book.PublicationYear,
+				PageCount:       book.PageCount,
+				Language:        book.Language,
+				Binding:         book.Binding,
+				Subjects:        book.Categories,
+				Missing:         missing,
+			}, "", "  ")
+			require.NoError(t, err)
+
+			golden := strings.TrimSuffix(fixture, ".html") + ".golden.json"
+			if *update {
+				require.NoError(t, os.WriteFile(golden, append(act, '\n'), 0644))
+			}
+			exp, err := os.ReadFile(golden)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(exp), string(act))
+		})
+	}
+}
+
+func TestCrawl(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "crawler", "free.html"))
+	require.NoError(t, err)
+	type testCase struct {
+		name      string
+		desc      string
+		page      string
+		respCode  int
+		clientErr error
+		expTitle  string
+		expErr    error
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "all ok",
+			page:     string(page),
+			respCode: http.StatusOK,
+			expTitle: "Unlucky 13",
+		},
+		{
+			name:     "Sad Case",
+			desc:     "page without a title",
+			page:     `<html><body><table><tr><th>Publisher</th><td>BB Books</td></tr></table></body></html>`,
+			respCode: http.StatusOK,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book page not found",
+			respCode: http.StatusNotFound,
+			expErr:   constant.ErrBookNotFound,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "non 2xx response code",
+			respCode: http.StatusForbidden,
+			expErr:   constant.ErrRetrievingBookDetails,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "client returns error",
+			clientErr: constant.ErrTimeout,
+			expErr:    constant.ErrRetrievingBookDetails,
+		},
+	}
+	config, err := DefaultCrawlerConfig().WithCookie("SESS1234=abcd; _ga=GA1.2")
+	require.NoError(t, err)
+	config.BaseURL = "https://books.example.com/"
+	config.Headers["User-Agent"] = "library"
+	for _, v := range testCases {
+		var req *http.Request
+		svc := NewBookService(&MockGOISBN{}).WithCrawler(config)
+		svc.client = &MockClient{
+			MockDo: func(r *http.Request) (*http.Response, error) {
+				req = r
+				return &http.Response{
+					StatusCode: v.respCode,
+					Body:       ioutil.NopCloser(bytes.NewReader([]byte(v.page))),
+				}, v.clientErr
+			},
+		}
+		missing := testutil.ToFloat64(crawlMissing.WithLabelValues("binding"))
+		actRes, actErr := svc.crawl(context.Background(), "9781784756055")
+
+		require.NotNil(t, req, v.desc)
+		assert.Equal(t, "https://books.example.com/book/9781784756055", req.URL.String(), v.desc)
+		assert.Equal(t, "library", req.Header.Get("User-Agent"), v.desc)
+		assert.Equal(t, "text/html", req.Header.Get("Accept"), v.desc)
+		ck, err := req.Cookie("SESS1234")
+		require.NoError(t, err, v.desc)
+		assert.Equal(t, "abcd", ck.Value, v.desc)
+		if v.expErr != nil {
+			assert.ErrorIs(t, actErr, v.expErr, v.desc)
+			assert.Nil(t, actRes, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expTitle, actRes.Title, v.desc)
+		assert.Equal(t, "isbndb_crawl", actRes.Source, v.desc)
+		// The free page has no binding
+		assert.Equal(t, missing+1, testutil.ToFloat64(crawlMissing.WithLabelValues("binding")), v.desc)
+	}
+}
+
+func TestLoadCrawlerConfig(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		config string
+		expRes func() CrawlerConfig
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "settings missing from the file are defaults",
+			config: `{"baseURL": "https://books.example.com", "headers": {"User-Agent": "library"}}`,
+			expRes: func() CrawlerConfig {
+				c := DefaultCrawlerConfig()
+				c.BaseURL = "https://books.example.com"
+				c.Headers = map[string]string{"User-Agent": "library"}
+				return c
+			},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "extraction replaces the default one",
+			config: `{"cookies": {"SESS1234": "abcd"}, "extraction": {"rows": "dl div", "label": "dt", "value": "dd", "labels": {"title": "Title"}, "elements": {"imageURL": {"selector": "img.cover", "attr": "src"}}}}`,
+			expRes: func() CrawlerConfig {
+				c := DefaultCrawlerConfig()
+				c.Cookies = map[string]string{"SESS1234": "abcd"}
+				c.Extraction = Extraction{Rows: "dl div", Label: "dt", Value: "dd", Labels: map[string]string{"title": "Title"}, Elements: map[string]Element{"imageURL": {Selector: "img.cover", Attr: "src"}}}
+				return c
+			},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown field",
+			config: `{"extraction": {"rows": "tr", "label": "th", "value": "td", "labels": {"title": "Title", "price": "List Price"}}}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "no title field",
+			config: `{"extraction": {"elements": {"imageURL": {"selector": "img.cover", "attr": "src"}}}}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "row fields without rows",
+			config: `{"extraction": {"labels": {"title": "Title"}}}`,
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "invalid json",
+			config: `{"baseURL": }`,
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		path := filepath.Join(t.TempDir(), "crawler.json")
+		require.NoError(t, os.WriteFile(path, []byte(v.config), 0600))
+		actRes, actErr := LoadCrawlerConfig(path)
+		if v.expErr {
+			assert.Error(t, actErr, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expRes(), actRes, v.desc)
+	}
+	_, err := LoadCrawlerConfig(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestWithCookie(t *testing.T) {
+	c := DefaultCrawlerConfig()
+	c.Cookies = map[string]string{"_ga": "GA1.1", "lang": "en"}
+	actRes, err := c.WithCookie(" SESS1234=abcd; _ga=GA1.2\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"SESS1234": "abcd", "_ga": "GA1.2", "lang": "en"}, actRes.Cookies)
+	assert.Equal(t, map[string]string{"_ga": "GA1.1", "lang": "en"}, c.Cookies)
+
+	_, err = c.WithCookie("no value")
+	assert.Error(t, err)
+}
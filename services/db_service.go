@@ -2,63 +2,249 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
 
+	"github.com/abx123/library/constant"
 	"github.com/abx123/library/entities"
+	"github.com/abx123/library/isbn"
 	"github.com/abx123/library/repo"
 )
 
 // DBService defines dbService
 type DBService struct {
-	repo repo.IdbRepo
+	repo  repo.IdbRepo
+	stats *statsCache
 }
 
 // NewDbService creates a new instance of DBService
 func NewDbService(r repo.IdbRepo) *DBService {
 	return &DBService{
-		repo: r,
+		repo:  r,
+		stats: newStatsCache(),
 	}
 }
 
-// Upsert updates the database record if a record is found, creates a record if none is found
-func (svc *DBService) Upsert(ctx context.Context, isbn, title, authors, imageURL, smallImageURL, publisher, userId, description, categories, language, source string, publicationYear, status, pageCount int64) (*entities.Book, error) {
-	book := &entities.Book{
-		ISBN:            isbn,
-		Title:           title,
-		Authors:         authors,
-		ImageURL:        imageURL,
-		SmallImageURL:   smallImageURL,
-		PublicationYear: publicationYear,
-		Publisher:       publisher,
-		UserID:          userId,
-		Status:          status,
-		Description:     description,
-		PageCount:       pageCount,
-		Categories:      categories,
-		Language:        language,
-		Source:          source,
-	}
-	book, err := svc.repo.Upsert(ctx, book)
+// Upsert updates the database record if a record is found, creates a record if none is found.
+// Every change to the library of a user drops the cached statistics of the user
+func (svc *DBService) Upsert(ctx context.Context, book *entities.Book) (*entities.Book, error) {
+	b := *book
+	b.ISBN = canonicalISBN(book.ISBN)
+	// The stored book tells whether the status changes
+	prev, err := svc.repo.Get(ctx, &entities.Book{ISBN: b.ISBN, UserID: b.UserID})
+	if err != nil && !errors.Is(err, constant.ErrBookNotFound) {
+		return nil, err
+	}
+	entities.Transition(&b, prev, time.Now().UTC())
+	if prev != nil {
+		b.CurrentPage = prev.CurrentPage
+	}
+	book, err = svc.repo.Upsert(ctx, &b)
+	svc.stats.invalidate(b.UserID)
 	if err != nil {
 		return nil, err
 	}
+	if err = svc.withProgress(ctx, b.UserID, book); err != nil {
+		return nil, err
+	}
 	return book, nil
 }
 
+// Patch updates only the fields set in patch of the database record matching search criteria
+func (svc *DBService) Patch(ctx context.Context, isbn string, userId string, patch *entities.BookPatch) (*entities.Book, error) {
+	key := &entities.Book{ISBN: canonicalISBN(isbn), UserID: userId}
+	if patch.Status != nil {
+		prev, err := svc.repo.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		b := &entities.Book{Status: *patch.Status}
+		entities.Transition(b, prev, time.Now().UTC())
+		p := *patch
+		p.StartedAt, p.FinishedAt = b.StartedAt, b.FinishedAt
+		patch = &p
+	}
+	book, err := svc.repo.Patch(ctx, key, patch)
+	svc.stats.invalidate(userId)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.withProgress(ctx, userId, book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Progress records a reading session of the database record matching search criteria up to the page reported in u.
+// Reporting progress starts reading the book and reaching its last page finishes it
+func (svc *DBService) Progress(ctx context.Context, isbn string, userId string, u *entities.ProgressUpdate) (*entities.Book, error) {
+	key := &entities.Book{ISBN: canonicalISBN(isbn), UserID: userId}
+	prev, err := svc.repo.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	page, err := progressPage(prev, u)
+	if err != nil {
+		return nil, err
+	}
+	s := &entities.Session{StartPage: prev.CurrentPage, EndPage: page, EndedAt: time.Now().UTC()}
+	if u.EndedAt != nil {
+		s.EndedAt = u.EndedAt.UTC()
+	}
+	s.StartedAt = s.EndedAt
+	if u.StartedAt != nil {
+		s.StartedAt = u.StartedAt.UTC()
+	}
+	patch := &entities.BookPatch{CurrentPage: &page}
+	b := &entities.Book{Status: progressStatus(prev, page)}
+	if b.Status != prev.Status {
+		at := s.EndedAt
+		if b.Status == entities.StatusReading {
+			at = s.StartedAt
+		}
+		entities.Transition(b, prev, at)
+		patch.Status, patch.StartedAt, patch.FinishedAt = &b.Status, b.StartedAt, b.FinishedAt
+	}
+	book, err := svc.repo.AddSession(ctx, key, s, patch)
+	svc.stats.invalidate(userId)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.withProgress(ctx, userId, book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// progressPage returns the page reported in u, a percentage is converted to a page of b
+func progressPage(b *entities.Book, u *entities.ProgressUpdate) (int64, error) {
+	if u.Percent != nil {
+		if b.PageCount <= 0 {
+			return 0, constant.InvalidField("percent", "requires the page count of the book")
+		}
+		return int64(math.Round(*u.Percent * float64(b.PageCount) / 100)), nil
+	}
+	if b.PageCount > 0 && *u.Page > b.PageCount {
+		return 0, constant.InvalidField("page", fmt.Sprintf("exceeds the page count of %d", b.PageCount))
+	}
+	return *u.Page, nil
+}
+
+// progressStatus returns the status of b at page, reaching the last page reads the book and any other page is reading it
+func progressStatus(b *entities.Book, page int64) entities.Status {
+	switch {
+	case b.PageCount > 0 && page >= b.PageCount:
+		return entities.StatusRead
+	case page > 0:
+		return entities.StatusReading
+	}
+	return b.Status
+}
+
+// withProgress sets the progress of books, the recent reading sessions of the user are only read when a book is being read
+func (svc *DBService) withProgress(ctx context.Context, userId string, books ...*entities.Book) error {
+	now := time.Now().UTC()
+	var pace float64
+	for _, b := range books {
+		if entities.Estimating(b) {
+			sessions, err := svc.repo.ListSessions(ctx, userId, now.Add(-entities.PaceWindow))
+			if err != nil {
+				return err
+			}
+			pace = entities.Pace(sessions, now)
+			break
+		}
+	}
+	for _, b := range books {
+		b.Progress = entities.ProgressOf(b, pace, now)
+	}
+	return nil
+}
+
 // Get gets the database record matching search criteria
 func (svc *DBService) Get(ctx context.Context, isbn string, userId string) (*entities.Book, error) {
-	book := &entities.Book{ISBN: isbn, UserID: userId}
+	book := &entities.Book{ISBN: canonicalISBN(isbn), UserID: userId}
 	book, err := svc.repo.Get(ctx, book)
 	if err != nil {
 		return nil, err
 	}
+	if err = svc.withProgress(ctx, userId, book); err != nil {
+		return nil, err
+	}
 	return book, nil
 }
 
-// List lists all database records matching search criteria
-func (svc *DBService) List(ctx context.Context, limit, offset int64, userId string) ([]*entities.Book, error) {
-	books, err := svc.repo.List(ctx, limit, offset, userId)
+// List lists a page of the database records matching the query, with the cursor of the next page and the total number of records
+func (svc *DBService) List(ctx context.Context, q *entities.ListQuery) (*entities.BookPage, error) {
+	// One more record than requested tells whether there is a next page
+	pq := *q
+	pq.Limit = q.Limit + 1
+	books, err := svc.repo.List(ctx, &pq)
+	if err != nil {
+		return nil, err
+	}
+	page := &entities.BookPage{Items: books}
+	if int64(len(books)) > q.Limit {
+		page.Items = books[:q.Limit]
+		if q.Limit > 0 {
+			page.NextCursor = entities.EncodeCursor(entities.NewCursor(page.Items[q.Limit-1], q.Sort), q.Sort)
+		}
+	}
+	page.TotalCount, err = svc.repo.Count(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if err = svc.withProgress(ctx, q.UserID, page.Items...); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// Delete moves the database record matching search criteria to trash
+func (svc *DBService) Delete(ctx context.Context, isbn string, userId string) error {
+	err := svc.repo.Delete(ctx, &entities.Book{ISBN: canonicalISBN(isbn), UserID: userId})
+	svc.stats.invalidate(userId)
+	return err
+}
+
+// Trash lists all deleted database records matching search criteria
+func (svc *DBService) Trash(ctx context.Context, limit, offset int64, userId string) ([]*entities.Book, error) {
+	books, err := svc.repo.ListDeleted(ctx, limit, offset, userId)
+	if err != nil {
+		return nil, err
+	}
+	return books, nil
+}
+
+// Restore restores the deleted database record matching search criteria
+func (svc *DBService) Restore(ctx context.Context, isbn string, userId string) (*entities.Book, error) {
+	book := &entities.Book{ISBN: canonicalISBN(isbn), UserID: userId}
+	err := svc.repo.Restore(ctx, book)
+	svc.stats.invalidate(userId)
+	if err != nil {
+		return nil, err
+	}
+	book, err = svc.repo.Get(ctx, book)
 	if err != nil {
 		return nil, err
 	}
-	return books, err
+	if err = svc.withProgress(ctx, userId, book); err != nil {
+		return nil, err
+	}
+	return book, nil
+}
+
+// Purge permanently removes database records deleted longer than the retention period ago
+func (svc *DBService) Purge(ctx context.Context, retention time.Duration) (int64, error) {
+	return svc.repo.Purge(ctx, time.Now().UTC().Add(-retention))
+}
+
+// canonicalISBN returns the ISBN-13 books are stored by, an invalid ISBN is returned unchanged and matches no book
+func canonicalISBN(s string) string {
+	if c, err := isbn.Canonical(s); err == nil {
+		return c
+	}
+	return s
 }
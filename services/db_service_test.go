@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 
+	"github.com/abx123/library/constant"
 	"github.com/abx123/library/entities"
 	"github.com/abx123/library/repo/mocks"
 )
@@ -15,6 +18,7 @@ func TestUpsert(t *testing.T) {
 	type testCase struct {
 		name   string
 		desc   string
+		getErr error
 		expRes *entities.Book
 		expErr error
 	}
@@ -27,7 +31,7 @@ func TestUpsert(t *testing.T) {
 				BookID:          0,
 				ISBN:            "isbn",
 				Title:           "title",
-				Authors:         "authors",
+				Authors:         []string{"authors"},
 				ImageURL:        "imageURL",
 				SmallImageURL:   "smallImageURL",
 				PublicationYear: 2021,
@@ -36,27 +40,281 @@ func TestUpsert(t *testing.T) {
 				Status:          1,
 				Description:     "description",
 				PageCount:       999,
-				Categories:      "categories",
+				Categories:      []string{"categories"},
 				Language:        "language",
 				Source:          "source"},
+			getErr: constant.ErrBookNotFound,
 		},
 		{
 			name:   "Sad Case",
 			desc:   "repo returns error",
+			getErr: constant.ErrBookNotFound,
 			expErr: fmt.Errorf("mock error"),
 		},
+		{
+			name:   "Sad Case",
+			desc:   "get returns error",
+			getErr: fmt.Errorf("mock error"),
+			expErr: fmt.Errorf("mock error"),
+		},
+	}
+
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		repo.On("Get", context.Background(), &entities.Book{ISBN: "isbn", UserID: "userId"}).Return(nil, v.getErr)
+		repo.On("Upsert", context.Background(), &entities.Book{BookID: 0, ISBN: "isbn", Title: "title", Authors: []string{"authors"}, ImageURL: "imageURL", SmallImageURL: "smallImageURL", PublicationYear: 2021, Publisher: "publisher", UserID: "userId", Status: 1, Description: "description", PageCount: 999, Categories: []string{"categories"}, Language: "language", Source: "source"}).Return(v.expRes, v.expErr)
+		actRes, actErr := dbSvc.Upsert(context.Background(), &entities.Book{ISBN: "isbn", Title: "title", Authors: []string{"authors"}, ImageURL: "imageURL", SmallImageURL: "smallImageURL", PublicationYear: 2021, Publisher: "publisher", UserID: "userId", Status: 1, Description: "description", PageCount: 999, Categories: []string{"categories"}, Language: "language", Source: "source"})
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
 	}
+}
 
+func TestPatch(t *testing.T) {
+	title := "title"
+	type testCase struct {
+		name   string
+		desc   string
+		expRes *entities.Book
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: &entities.Book{ISBN: "isbn", UserID: "userid", Title: "title"},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "repo returns error",
+			expErr: fmt.Errorf("mock error"),
+		},
+	}
 	for _, v := range testCases {
 		repo := mocks.IdbRepo{}
 		dbSvc := NewDbService(&repo)
-		repo.On("Upsert", context.Background(), &entities.Book{BookID: 0, ISBN: "isbn", Title: "title", Authors: "authors", ImageURL: "imageURL", SmallImageURL: "smallImageURL", PublicationYear: 2021, Publisher: "publisher", UserID: "userId", Status: 1, Description: "description", PageCount: 999, Categories: "categories", Language: "language", Source: "source"}).Return(v.expRes, v.expErr)
-		actRes, actErr := dbSvc.Upsert(context.Background(), "isbn", "title", "authors", "imageURL", "smallImageURL", "publisher", "userId", "description", "categories", "language", "source", 2021, 1, 999)
+		repo.On("Patch", context.Background(), &entities.Book{ISBN: "isbn", UserID: "userid"}, &entities.BookPatch{Title: &title}).Return(v.expRes, v.expErr)
+		actRes, actErr := dbSvc.Patch(context.Background(), "isbn", "userid", &entities.BookPatch{Title: &title})
 		assert.Equal(t, v.expRes, actRes)
 		assert.Equal(t, v.expErr, actErr)
 	}
 }
 
+func TestPatchStatus(t *testing.T) {
+	started := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+	finished := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	type testCase struct {
+		name        string
+		desc        string
+		prev        *entities.Book
+		status      entities.Status
+		getErr      error
+		expStarted  string
+		expFinished string
+		expErr      error
+	}
+	testCases := []testCase{
+		{
+			name:        "Happy Case",
+			desc:        "starting to read sets startedAt",
+			prev:        &entities.Book{Status: entities.StatusOwned},
+			status:      entities.StatusReading,
+			expStarted:  "now",
+			expFinished: "nil",
+		},
+		{
+			name:        "Happy Case",
+			desc:        "finishing a book sets finishedAt",
+			prev:        &entities.Book{Status: entities.StatusReading, StartedAt: &started},
+			status:      entities.StatusRead,
+			expStarted:  "prev",
+			expFinished: "now",
+		},
+		{
+			name:        "Happy Case",
+			desc:        "abandoning a book sets finishedAt",
+			prev:        &entities.Book{Status: entities.StatusReading, StartedAt: &started},
+			status:      entities.StatusAbandoned,
+			expStarted:  "prev",
+			expFinished: "now",
+		},
+		{
+			name:        "Happy Case",
+			desc:        "rereading a book clears finishedAt",
+			prev:        &entities.Book{Status: entities.StatusRead, StartedAt: &started, FinishedAt: &finished},
+			status:      entities.StatusReading,
+			expStarted:  "now",
+			expFinished: "nil",
+		},
+		{
+			name:        "Happy Case",
+			desc:        "unchanged status keeps the times",
+			prev:        &entities.Book{Status: entities.StatusRead, StartedAt: &started, FinishedAt: &finished},
+			status:      entities.StatusRead,
+			expStarted:  "prev",
+			expFinished: "prev",
+		},
+		{
+			name:        "Happy Case",
+			desc:        "other statuses keep the times",
+			prev:        &entities.Book{Status: entities.StatusRead, StartedAt: &started, FinishedAt: &finished},
+			status:      entities.StatusOwned,
+			expStarted:  "prev",
+			expFinished: "prev",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "get returns error",
+			status: entities.StatusReading,
+			getErr: constant.ErrBookNotFound,
+			expErr: constant.ErrBookNotFound,
+		},
+	}
+	at := func(exp string, prev, act *time.Time, start time.Time) bool {
+		switch exp {
+		case "nil":
+			return act == nil
+		case "prev":
+			return act == prev
+		}
+		return act != nil && !act.Before(start)
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		key := &entities.Book{ISBN: "isbn", UserID: "userid"}
+		repo.On("Get", context.Background(), key).Return(v.prev, v.getErr)
+		start := time.Now().UTC()
+		repo.On("Patch", context.Background(), key, mock.MatchedBy(func(p *entities.BookPatch) bool {
+			return *p.Status == v.status && at(v.expStarted, v.prev.StartedAt, p.StartedAt, start) && at(v.expFinished, v.prev.FinishedAt, p.FinishedAt, start)
+		})).Return(key, nil)
+		status := v.status
+		_, actErr := dbSvc.Patch(context.Background(), "isbn", "userid", &entities.BookPatch{Status: &status})
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		if v.expErr == nil {
+			repo.AssertExpectations(t)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	startedAt := time.Date(2021, 6, 1, 20, 0, 0, 0, time.UTC)
+	endedAt := time.Date(2021, 6, 1, 21, 0, 0, 0, time.UTC)
+	page, half, over, last := int64(80), 50.0, int64(301), int64(300)
+	reading, read := entities.StatusReading, entities.StatusRead
+	type testCase struct {
+		name        string
+		desc        string
+		prev        *entities.Book
+		update      *entities.ProgressUpdate
+		getErr      error
+		expSession  *entities.Session
+		expPatch    *entities.BookPatch
+		addRes      *entities.Book
+		addErr      error
+		listErr     error
+		expPercent  float64
+		expEstimate bool
+		expErr      error
+	}
+	testCases := []testCase{
+		{
+			name:        "Happy Case",
+			desc:        "reporting a page starts reading the book",
+			prev:        &entities.Book{Status: entities.StatusOwned, PageCount: 300, CurrentPage: 50},
+			update:      &entities.ProgressUpdate{Page: &page, StartedAt: &startedAt, EndedAt: &endedAt},
+			expSession:  &entities.Session{StartedAt: startedAt, EndedAt: endedAt, StartPage: 50, EndPage: 80},
+			expPatch:    &entities.BookPatch{CurrentPage: &page, Status: &reading, StartedAt: &startedAt},
+			addRes:      &entities.Book{Status: entities.StatusReading, PageCount: 300, CurrentPage: 80},
+			expPercent:  26.7,
+			expEstimate: true,
+		},
+		{
+			name:        "Happy Case",
+			desc:        "a percentage is converted to a page",
+			prev:        &entities.Book{Status: entities.StatusReading, StartedAt: &startedAt, PageCount: 300, CurrentPage: 80},
+			update:      &entities.ProgressUpdate{Percent: &half, StartedAt: &startedAt, EndedAt: &endedAt},
+			expSession:  &entities.Session{StartedAt: startedAt, EndedAt: endedAt, StartPage: 80, EndPage: 150},
+			expPatch:    &entities.BookPatch{CurrentPage: func() *int64 { p := int64(150); return &p }()},
+			addRes:      &entities.Book{Status: entities.StatusReading, PageCount: 300, CurrentPage: 150},
+			expPercent:  50,
+			expEstimate: true,
+		},
+		{
+			name:       "Happy Case",
+			desc:       "reaching the last page reads the book",
+			prev:       &entities.Book{Status: entities.StatusReading, StartedAt: &startedAt, PageCount: 300, CurrentPage: 250},
+			update:     &entities.ProgressUpdate{Page: &last, EndedAt: &endedAt},
+			expSession: &entities.Session{StartedAt: endedAt, EndedAt: endedAt, StartPage: 250, EndPage: 300},
+			expPatch:   &entities.BookPatch{CurrentPage: &last, Status: &read, StartedAt: &startedAt, FinishedAt: &endedAt},
+			addRes:     &entities.Book{Status: entities.StatusRead, PageCount: 300, CurrentPage: 300},
+			expPercent: 100,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "percentage of a book without page count",
+			prev:   &entities.Book{Status: entities.StatusReading},
+			update: &entities.ProgressUpdate{Percent: &half},
+			expErr: constant.InvalidField("percent", "requires the page count of the book"),
+		},
+		{
+			name:   "Sad Case",
+			desc:   "page exceeds the page count",
+			prev:   &entities.Book{Status: entities.StatusReading, PageCount: 300},
+			update: &entities.ProgressUpdate{Page: &over},
+			expErr: constant.InvalidField("page", "exceeds the page count of 300"),
+		},
+		{
+			name:   "Sad Case",
+			desc:   "get returns error",
+			update: &entities.ProgressUpdate{Page: &page},
+			getErr: constant.ErrBookNotFound,
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:       "Sad Case",
+			desc:       "add session returns error",
+			prev:       &entities.Book{Status: entities.StatusReading, StartedAt: &startedAt, PageCount: 300, CurrentPage: 50},
+			update:     &entities.ProgressUpdate{Page: &page, StartedAt: &startedAt, EndedAt: &endedAt},
+			expSession: &entities.Session{StartedAt: startedAt, EndedAt: endedAt, StartPage: 50, EndPage: 80},
+			expPatch:   &entities.BookPatch{CurrentPage: &page},
+			addErr:     fmt.Errorf("mock error"),
+			expErr:     fmt.Errorf("mock error"),
+		},
+		{
+			name:       "Sad Case",
+			desc:       "list sessions returns error",
+			prev:       &entities.Book{Status: entities.StatusReading, StartedAt: &startedAt, PageCount: 300, CurrentPage: 50},
+			update:     &entities.ProgressUpdate{Page: &page, StartedAt: &startedAt, EndedAt: &endedAt},
+			expSession: &entities.Session{StartedAt: startedAt, EndedAt: endedAt, StartPage: 50, EndPage: 80},
+			expPatch:   &entities.BookPatch{CurrentPage: &page},
+			addRes:     &entities.Book{Status: entities.StatusReading, PageCount: 300, CurrentPage: 80},
+			listErr:    fmt.Errorf("mock error"),
+			expErr:     fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		key := &entities.Book{ISBN: "isbn", UserID: "userid"}
+		repo.On("Get", context.Background(), key).Return(v.prev, v.getErr)
+		repo.On("AddSession", context.Background(), key, v.expSession, v.expPatch).Return(v.addRes, v.addErr)
+		if v.expEstimate || v.listErr != nil {
+			// 30 pages a day, the sessions are only read for books being read
+			repo.On("ListSessions", context.Background(), "userid", mock.Anything).Return([]*entities.Session{{StartedAt: time.Now().UTC().Add(-2 * 24 * time.Hour), StartPage: 0, EndPage: 60}}, v.listErr)
+		}
+		actRes, actErr := dbSvc.Progress(context.Background(), "isbn", "userid", v.update)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		if v.expErr != nil {
+			assert.Nil(t, actRes, v.desc)
+			continue
+		}
+		assert.Equal(t, v.expPercent, actRes.Progress.Percent, v.desc)
+		assert.Equal(t, v.expEstimate, actRes.Progress.EstimatedFinish != nil, v.desc)
+		repo.AssertExpectations(t)
+	}
+}
+
 func TestDBGet(t *testing.T) {
 	type testCase struct {
 		name   string
@@ -73,7 +331,7 @@ func TestDBGet(t *testing.T) {
 				BookID:          0,
 				ISBN:            "isbn",
 				Title:           "title",
-				Authors:         "authors",
+				Authors:         []string{"authors"},
 				ImageURL:        "imageURL",
 				SmallImageURL:   "smallImageURL",
 				PublicationYear: 2021,
@@ -82,7 +340,7 @@ func TestDBGet(t *testing.T) {
 				Status:          1,
 				Description:     "description",
 				PageCount:       999,
-				Categories:      "categories",
+				Categories:      []string{"categories"},
 				Language:        "language",
 				Source:          "source"},
 		},
@@ -96,7 +354,7 @@ func TestDBGet(t *testing.T) {
 	for _, v := range testCases {
 		repo := mocks.IdbRepo{}
 		dbSvc := NewDbService(&repo)
-		repo.On("Get", context.Background(), &entities.Book{BookID: 0, ISBN: "isbn", Title: "", Authors: "", ImageURL: "", SmallImageURL: "", PublicationYear: 0, Publisher: "", UserID: "userid", Status: 0, Description: "", PageCount: 0, Categories: "", Language: "", Source: ""}).Return(v.expRes, v.expErr)
+		repo.On("Get", context.Background(), &entities.Book{BookID: 0, ISBN: "isbn", Title: "", ImageURL: "", SmallImageURL: "", PublicationYear: 0, Publisher: "", UserID: "userid", Status: 0, Description: "", PageCount: 0, Language: "", Source: ""}).Return(v.expRes, v.expErr)
 		actRes, actErr := dbSvc.Get(context.Background(), "isbn", "userid")
 		assert.Equal(t, v.expRes, actRes)
 		assert.Equal(t, v.expErr, actErr)
@@ -104,6 +362,137 @@ func TestDBGet(t *testing.T) {
 }
 
 func TestList(t *testing.T) {
+	books := []*entities.Book{
+		{BookID: 1, ISBN: "9780552166591", Title: "Good Omens", UserID: "userid"},
+		{BookID: 2, ISBN: "9780552124751", Title: "The Colour of Magic", UserID: "userid"},
+		{BookID: 3, ISBN: "9780751562774", Title: "The Secrets She Keeps", UserID: "userid"},
+	}
+	sort := []entities.SortField{{Field: entities.SortTitle}}
+	type testCase struct {
+		name     string
+		desc     string
+		limit    int64
+		books    []*entities.Book
+		listErr  error
+		count    int64
+		countErr error
+		expRes   *entities.BookPage
+		expErr   error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "last page",
+			limit:  3,
+			books:  books,
+			count:  3,
+			expRes: &entities.BookPage{Items: books, TotalCount: 3},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "next page continues after the last item",
+			limit:  2,
+			books:  books,
+			count:  3,
+			expRes: &entities.BookPage{Items: books[:2], NextCursor: entities.EncodeCursor(&entities.Cursor{Values: []interface{}{"The Colour of Magic"}, ID: 2}, sort), TotalCount: 3},
+		},
+		{
+			name:    "Sad Case",
+			desc:    "repo returns error",
+			limit:   2,
+			listErr: fmt.Errorf("mock error"),
+			expErr:  fmt.Errorf("mock error"),
+		},
+		{
+			name:     "Sad Case",
+			desc:     "count returns error",
+			limit:    3,
+			books:    books,
+			countErr: fmt.Errorf("mock error"),
+			expErr:   fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		q := &entities.ListQuery{UserID: "userid", Sort: sort, Limit: v.limit}
+		repo.On("List", context.Background(), &entities.ListQuery{UserID: "userid", Sort: sort, Limit: v.limit + 1}).Return(v.books, v.listErr)
+		repo.On("Count", context.Background(), q).Return(v.count, v.countErr)
+		actRes, actErr := dbSvc.List(context.Background(), q)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name: "Happy Case",
+			desc: "all ok",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "repo returns error",
+			expErr: fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		repo.On("Delete", context.Background(), &entities.Book{ISBN: "isbn", UserID: "userid"}).Return(v.expErr)
+		actErr := dbSvc.Delete(context.Background(), "isbn", "userid")
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
+
+func TestCanonicalISBN(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		isbn   string
+		expRes string
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "isbn-10",
+			isbn:   "0751562777",
+			expRes: "9780751562774",
+		},
+		{
+			name:   "Happy Case",
+			desc:   "hyphenated isbn-13",
+			isbn:   "978-0-7515-6277-4",
+			expRes: "9780751562774",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "invalid isbn is unchanged",
+			isbn:   "isbn",
+			expRes: "isbn",
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		book := &entities.Book{ISBN: v.expRes, UserID: "userid"}
+		repo.On("Upsert", context.Background(), book).Return(book, nil)
+		repo.On("Get", context.Background(), book).Return(book, nil)
+		actRes, actErr := dbSvc.Upsert(context.Background(), &entities.Book{ISBN: v.isbn, UserID: "userid"})
+		assert.Equal(t, book, actRes, v.desc)
+		assert.NoError(t, actErr, v.desc)
+		actRes, actErr = dbSvc.Get(context.Background(), v.isbn, "userid")
+		assert.Equal(t, book, actRes, v.desc)
+		assert.NoError(t, actErr, v.desc)
+	}
+}
+
+func TestTrash(t *testing.T) {
 	type testCase struct {
 		name   string
 		desc   string
@@ -116,21 +505,8 @@ func TestList(t *testing.T) {
 			desc: "all ok",
 			expRes: []*entities.Book{
 				{
-					BookID:          0,
-					ISBN:            "isbn",
-					Title:           "title",
-					Authors:         "authors",
-					ImageURL:        "imageURL",
-					SmallImageURL:   "smallImageURL",
-					PublicationYear: 2021,
-					Publisher:       "publisher",
-					UserID:          "userId",
-					Status:          1,
-					Description:     "description",
-					PageCount:       999,
-					Categories:      "categories",
-					Language:        "language",
-					Source:          "source",
+					ISBN:   "isbn",
+					UserID: "userid",
 				},
 			},
 		},
@@ -143,8 +519,80 @@ func TestList(t *testing.T) {
 	for _, v := range testCases {
 		repo := mocks.IdbRepo{}
 		dbSvc := NewDbService(&repo)
-		repo.On("List", context.Background(), int64(10), int64(0), "userid").Return(v.expRes, v.expErr)
-		actRes, actErr := dbSvc.List(context.Background(), 10, 0, "userid")
+		repo.On("ListDeleted", context.Background(), int64(10), int64(0), "userid").Return(v.expRes, v.expErr)
+		actRes, actErr := dbSvc.Trash(context.Background(), 10, 0, "userid")
+		assert.Equal(t, v.expRes, actRes)
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	type testCase struct {
+		name       string
+		desc       string
+		restoreErr error
+		getErr     error
+		expRes     *entities.Book
+		expErr     error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: &entities.Book{ISBN: "isbn", UserID: "userid", Title: "title"},
+		},
+		{
+			name:       "Sad Case",
+			desc:       "restore returns error",
+			restoreErr: fmt.Errorf("mock error"),
+			expErr:     fmt.Errorf("mock error"),
+		},
+		{
+			name:   "Sad Case",
+			desc:   "get returns error",
+			getErr: fmt.Errorf("mock error"),
+			expErr: fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		repo.On("Restore", context.Background(), &entities.Book{ISBN: "isbn", UserID: "userid"}).Return(v.restoreErr)
+		repo.On("Get", context.Background(), &entities.Book{ISBN: "isbn", UserID: "userid"}).Return(v.expRes, v.getErr)
+		actRes, actErr := dbSvc.Restore(context.Background(), "isbn", "userid")
+		assert.Equal(t, v.expRes, actRes)
+		assert.Equal(t, v.expErr, actErr)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		expRes int64
+		expErr error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: 2,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "repo returns error",
+			expErr: fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		start := time.Now().UTC()
+		repo.On("Purge", context.Background(), mock.MatchedBy(func(before time.Time) bool {
+			// cutoff is retention period before now
+			return !before.Before(start.Add(-time.Hour)) && before.Before(start)
+		})).Return(v.expRes, v.expErr)
+		actRes, actErr := dbSvc.Purge(context.Background(), time.Hour)
 		assert.Equal(t, v.expRes, actRes)
 		assert.Equal(t, v.expErr, actErr)
 	}
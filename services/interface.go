@@ -3,20 +3,35 @@ package services
 import (
 	"context"
 	"net/http"
+	"time"
 
 	"github.com/abx123/library/entities"
 )
 
 // IdbService defines the interface for dbService
 type IdbService interface {
-	Upsert(context.Context, string, string, string, string, string, string, string, string, string, string, string, int64, int64, int64) (*entities.Book, error)
+	Upsert(context.Context, *entities.Book) (*entities.Book, error)
+	Patch(context.Context, string, string, *entities.BookPatch) (*entities.Book, error)
+	Progress(context.Context, string, string, *entities.ProgressUpdate) (*entities.Book, error)
 	Get(context.Context, string, string) (*entities.Book, error)
-	List(context.Context, int64, int64, string) ([]*entities.Book, error)
+	List(context.Context, *entities.ListQuery) (*entities.BookPage, error)
+	Delete(context.Context, string, string) error
+	Trash(context.Context, int64, int64, string) ([]*entities.Book, error)
+	Restore(context.Context, string, string) (*entities.Book, error)
+	Purge(context.Context, time.Duration) (int64, error)
+	Stats(context.Context, string) (*entities.Stats, error)
 }
 
 // Ibooks defines the interface for bookService
 type Ibooks interface {
 	Get(context.Context, string) (*entities.Book, error)
+	Providers() []*entities.ProviderStatus
+}
+
+// LookupStore caches the lookups of books from providers, see repo.IdbRepo
+type LookupStore interface {
+	GetLookup(context.Context, string) (*entities.Lookup, error)
+	PutLookup(context.Context, *entities.Lookup) error
 }
 
 type httpClient interface {
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // decodes the size of gif covers
+	_ "image/jpeg" // decodes the size of jpeg covers
+	_ "image/png"  // decodes the size of png covers
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/abx123/library/entities"
+)
+
+// MergeRule decides which provider supplies a field of a book found by several providers
+type MergeRule string
+
+// Merge rules
+const (
+	// RuleFirst takes the field from the first provider of the chain which has it
+	RuleFirst MergeRule = "first"
+	// RuleLongest takes the longest text, the most names or the largest number
+	RuleLongest MergeRule = "longest"
+	// RuleResolution takes the cover image with the most pixels, the images are downloaded to be measured
+	RuleResolution MergeRule = "resolution"
+)
+
+// DefaultMergeRules takes the longest description and the highest resolution cover, every other field from the first provider
+const DefaultMergeRules = "description=longest,imageURL=resolution"
+
+// MergeRules are the rules of the fields of a book by field name, fields without a rule are merged with RuleFirst
+type MergeRules map[string]MergeRule
+
+// mergeField is a field of a book which can be supplied by a provider
+type mergeField struct {
+	name string
+	// size orders the values of the field for RuleLongest, 0 is no value
+	size func(b *entities.Book) int
+	// image returns the URL of an image field, nil for fields which are not images
+	image func(b *entities.Book) string
+	copy  func(dst, src *entities.Book)
+}
+
+// mergeFields are the fields of a book merged from providers, named as in the API
+var mergeFields = []mergeField{
+	{
+		name: "isbn",
+		size: func(b *entities.Book) int { return len(b.ISBN) },
+		copy: func(dst, src *entities.Book) { dst.ISBN = src.ISBN },
+	},
+	{
+		name: "title",
+		size: func(b *entities.Book) int { return len(b.Title) },
+		copy: func(dst, src *entities.Book) { dst.Title = src.Title },
+	},
+	{
+		name: "authors",
+		size: func(b *entities.Book) int { return len(b.Authors) },
+		copy: func(dst, src *entities.Book) { dst.Authors = src.Authors },
+	},
+	{
+		name:  "imageURL",
+		size:  func(b *entities.Book) int { return len(b.ImageURL) },
+		image: func(b *entities.Book) string { return b.ImageURL },
+		copy:  func(dst, src *entities.Book) { dst.ImageURL = src.ImageURL },
+	},
+	{
+		name:  "smallImageURL",
+		size:  func(b *entities.Book) int { return len(b.SmallImageURL) },
+		image: func(b *entities.Book) string { return b.SmallImageURL },
+		copy:  func(dst, src *entities.Book) { dst.SmallImageURL = src.SmallImageURL },
+	},
+	{
+		name: "publicationYear",
+		size: func(b *entities.Book) int { return int(b.PublicationYear) },
+		copy: func(dst, src *entities.Book) { dst.PublicationYear = src.PublicationYear },
+	},
+	{
+		name: "publisher",
+		size: func(b *entities.Book) int { return len(b.Publisher) },
+		copy: func(dst, src *entities.Book) { dst.Publisher = src.Publisher },
+	},
+	{
+		name: "description",
+		size: func(b *entities.Book) int { return len(b.Description) },
+		copy: func(dst, src *entities.Book) { dst.Description = src.Description },
+	},
+	{
+		name: "pageCount",
+		size: func(b *entities.Book) int { return int(b.PageCount) },
+		copy: func(dst, src *entities.Book) { dst.PageCount = src.PageCount },
+	},
+	{
+		name: "categories",
+		size: func(b *entities.Book) int { return len(b.Categories) },
+		copy: func(dst, src *entities.Book) { dst.Categories = src.Categories },
+	},
+	{
+		name: "language",
+		size: func(b *entities.Book) int { return len(b.Language) },
+		copy: func(dst, src *entities.Book) { dst.Language = src.Language },
+	},
+	{
+		name: "binding",
+		size: func(b *entities.Book) int { return len(b.Binding) },
+		copy: func(dst, src *entities.Book) { dst.Binding = src.Binding },
+	},
+}
+
+// ParseMergeRules parses a comma separated list of field=rule, ie: "description=longest,imageURL=resolution".
+// Only image fields can be merged by resolution
+func ParseMergeRules(s string) (MergeRules, error) {
+	rules := MergeRules{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		i := strings.Index(v, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid merge rule %q, expected field=rule", v)
+		}
+		name, rule := strings.TrimSpace(v[:i]), MergeRule(strings.TrimSpace(v[i+1:]))
+		f, ok := fieldByName(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid merge rule %q: unknown field %q", v, name)
+		}
+		switch {
+		case rule == RuleFirst, rule == RuleLongest:
+		case rule == RuleResolution && f.image != nil:
+		default:
+			return nil, fmt.Errorf("invalid merge rule %q: rule %q does not apply to %s", v, rule, name)
+		}
+		rules[name] = rule
+	}
+	return rules, nil
+}
+
+func fieldByName(name string) (mergeField, bool) {
+	for _, f := range mergeFields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return mergeField{}, false
+}
+
+// merge merges the books found by providers in chain order field by field, the provenance of the book records the source of every field.
+// The source of the book is the source of its title
+func (svc *BookService) merge(ctx context.Context, found []*entities.Book) *entities.Book {
+	b := &entities.Book{Status: entities.StatusOwned, Provenance: map[string]string{}}
+	for _, f := range mergeFields {
+		candidates := []*entities.Book{}
+		for _, c := range found {
+			if f.size(c) > 0 {
+				candidates = append(candidates, c)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		src := candidates[0]
+		switch svc.rules[f.name] {
+		case RuleLongest:
+			for _, c := range candidates[1:] {
+				if f.size(c) > f.size(src) {
+					src = c
+				}
+			}
+		case RuleResolution:
+			src = svc.largestImage(ctx, candidates, f.image)
+		}
+		f.copy(b, src)
+		b.Provenance[f.name] = src.Source
+	}
+	b.Source = b.Provenance["title"]
+	if b.Source == "" {
+		b.Source = found[0].Source
+	}
+	return b
+}
+
+// largestImage returns the candidate whose image has the most pixels, the first candidate when no image can be measured
+func (svc *BookService) largestImage(ctx context.Context, candidates []*entities.Book, url func(b *entities.Book) string) *entities.Book {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	pixels := make([]int, len(candidates))
+	var wg sync.WaitGroup
+	for i, c := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pixels[i] = svc.imagePixels(ctx, url(c))
+		}()
+	}
+	wg.Wait()
+	best := 0
+	for i := range candidates {
+		if pixels[i] > pixels[best] {
+			best = i
+		}
+	}
+	return candidates[best]
+}
+
+// imagePixels downloads the image at url and returns its width times its height, 0 when it cannot be decoded
+func (svc *BookService) imagePixels(ctx context.Context, url string) int {
+	req, err := http.NewRequestWithContext(ctx, methodGet, url, nil)
+	if err != nil {
+		return 0
+	}
+	res, err := svc.client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return 0
+	}
+	cfg, _, err := image.DecodeConfig(res.Body)
+	if err != nil {
+		return 0
+	}
+	return cfg.Width * cfg.Height
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+func TestParseMergeRules(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		input  string
+		expRes MergeRules
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "default rules",
+			input:  DefaultMergeRules,
+			expRes: MergeRules{"description": RuleLongest, "imageURL": RuleResolution},
+		},
+		{
+			name:   "Happy Case",
+			desc:   "no rule",
+			input:  "",
+			expRes: MergeRules{},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown field",
+			input:  "blurb=longest",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown rule",
+			input:  "title=shortest",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "resolution of a field which is not an image",
+			input:  "title=resolution",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "missing rule",
+			input:  "title",
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := ParseMergeRules(v.input)
+		if v.expErr {
+			assert.Error(t, actErr, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+	}
+}
+
+// pngOf returns a png image of width by height pixels
+func pngOf(width, height int) []byte {
+	buf := &bytes.Buffer{}
+	png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return buf.Bytes()
+}
+
+func TestGetMerge(t *testing.T) {
+	google := &entities.Book{
+		ISBN: "9781784756055", Title: "Unlucky 13", Authors: []string{"James Patterson"}, Description: "short",
+		ImageURL: "https://google/cover.png", PageCount: 400, Source: "google",
+	}
+	openLibrary := &entities.Book{
+		ISBN: "9781784756055", Title: "Unlucky Thirteen", Authors: []string{"James Patterson", "Maxine Paetro"}, Description: "a longer description",
+		ImageURL: "https://openlibrary/cover.png", Publisher: "BB Books", PageCount: 416, Source: "openlibrary",
+	}
+	images := map[string][]byte{
+		"https://google/cover.png":      pngOf(128, 192),
+		"https://openlibrary/cover.png": pngOf(400, 600),
+	}
+	type testCase struct {
+		name       string
+		desc       string
+		rules      string
+		found      []*entities.Book
+		expRes     *entities.Book
+		expSources map[string]string
+	}
+	testCases := []testCase{
+		{
+			name:  "Happy Case",
+			desc:  "longest description and highest resolution cover",
+			rules: DefaultMergeRules,
+			found: []*entities.Book{google, openLibrary},
+			expRes: &entities.Book{
+				ISBN: "9781784756055", Title: "Unlucky 13", Authors: []string{"James Patterson"}, Description: "a longer description",
+				ImageURL: "https://openlibrary/cover.png", Publisher: "BB Books", PageCount: 400, Source: "google", Status: entities.StatusOwned,
+			},
+			expSources: map[string]string{
+				"isbn": "google", "title": "google", "authors": "google", "description": "openlibrary",
+				"imageURL": "openlibrary", "publisher": "openlibrary", "pageCount": "google",
+			},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "every field from the first provider which has it",
+			rules: "",
+			found: []*entities.Book{google, openLibrary},
+			expRes: &entities.Book{
+				ISBN: "9781784756055", Title: "Unlucky 13", Authors: []string{"James Patterson"}, Description: "short",
+				ImageURL: "https://google/cover.png", Publisher: "BB Books", PageCount: 400, Source: "google", Status: entities.StatusOwned,
+			},
+			expSources: map[string]string{
+				"isbn": "google", "title": "google", "authors": "google", "description": "google",
+				"imageURL": "google", "publisher": "openlibrary", "pageCount": "google",
+			},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "most authors and largest page count",
+			rules: "authors=longest,pageCount=longest",
+			found: []*entities.Book{google, openLibrary},
+			expRes: &entities.Book{
+				ISBN: "9781784756055", Title: "Unlucky 13", Authors: []string{"James Patterson", "Maxine Paetro"}, Description: "short",
+				ImageURL: "https://google/cover.png", Publisher: "BB Books", PageCount: 416, Source: "google", Status: entities.StatusOwned,
+			},
+			expSources: map[string]string{
+				"isbn": "google", "title": "google", "authors": "openlibrary", "description": "google",
+				"imageURL": "google", "publisher": "openlibrary", "pageCount": "openlibrary",
+			},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "provider which does not find the book is left out",
+			rules: DefaultMergeRules,
+			found: []*entities.Book{nil, openLibrary},
+			expRes: &entities.Book{
+				ISBN: "9781784756055", Title: "Unlucky Thirteen", Authors: []string{"James Patterson", "Maxine Paetro"}, Description: "a longer description",
+				ImageURL: "https://openlibrary/cover.png", Publisher: "BB Books", PageCount: 416, Source: "openlibrary", Status: entities.StatusOwned,
+			},
+			expSources: map[string]string{
+				"isbn": "openlibrary", "title": "openlibrary", "authors": "openlibrary", "description": "openlibrary",
+				"imageURL": "openlibrary", "publisher": "openlibrary", "pageCount": "openlibrary",
+			},
+		},
+	}
+	for _, v := range testCases {
+		rules, err := ParseMergeRules(v.rules)
+		assert.NoError(t, err, v.desc)
+		chain := []Provider{}
+		for _, b := range v.found {
+			chain = append(chain, Provider{MetadataProvider: ProviderFunc("fake", func(context.Context, string) (*entities.Book, error) {
+				if b == nil {
+					return nil, constant.ErrBookNotFound
+				}
+				found := *b
+				return &found, nil
+			})})
+		}
+		gi := &MockGOISBN{
+			MockValidateISBN: func(string) bool {
+				return true
+			},
+		}
+		svc := NewBookService(gi).WithProviders(chain).WithMergeRules(rules)
+		svc.client = &MockClient{
+			MockDo: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Body:       ioutil.NopCloser(bytes.NewReader(images[req.URL.String()])),
+				}, nil
+			},
+		}
+		actRes, actErr := svc.Get(context.Background(), "9781784756055")
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expSources, actRes.Provenance, v.desc)
+		actRes.Provenance = nil
+		assert.Equal(t, v.expRes, actRes, v.desc)
+	}
+}
@@ -36,3 +36,19 @@ func (_m *Ibooks) Get(_a0 context.Context, _a1 string) (*entities.Book, error) {
 
 	return r0, r1
 }
+
+// Providers provides a mock function with given fields:
+func (_m *Ibooks) Providers() []*entities.ProviderStatus {
+	ret := _m.Called()
+
+	var r0 []*entities.ProviderStatus
+	if rf, ok := ret.Get(0).(func() []*entities.ProviderStatus); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*entities.ProviderStatus)
+		}
+	}
+
+	return r0
+}
@@ -7,6 +7,8 @@ import (
 	entities "github.com/abx123/library/entities"
 
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // IdbService is an autogenerated mock type for the IdbService type
@@ -14,6 +16,20 @@ type IdbService struct {
 	mock.Mock
 }
 
+// Delete provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdbService) Delete(_a0 context.Context, _a1 string, _a2 string) error {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Get provides a mock function with given fields: _a0, _a1, _a2
 func (_m *IdbService) Get(_a0 context.Context, _a1 string, _a2 string) (*entities.Book, error) {
 	ret := _m.Called(_a0, _a1, _a2)
@@ -37,8 +53,144 @@ func (_m *IdbService) Get(_a0 context.Context, _a1 string, _a2 string) (*entitie
 	return r0, r1
 }
 
-// List provides a mock function with given fields: _a0, _a1, _a2, _a3
-func (_m *IdbService) List(_a0 context.Context, _a1 int64, _a2 int64, _a3 string) ([]*entities.Book, error) {
+// List provides a mock function with given fields: _a0, _a1
+func (_m *IdbService) List(_a0 context.Context, _a1 *entities.ListQuery) (*entities.BookPage, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *entities.BookPage
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.ListQuery) *entities.BookPage); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.BookPage)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.ListQuery) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Patch provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IdbService) Patch(_a0 context.Context, _a1 string, _a2 string, _a3 *entities.BookPatch) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *entities.BookPatch) *entities.Book); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *entities.BookPatch) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Progress provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IdbService) Progress(_a0 context.Context, _a1 string, _a2 string, _a3 *entities.ProgressUpdate) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1, _a2, _a3)
+
+	var r0 *entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, *entities.ProgressUpdate) *entities.Book); ok {
+		r0 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string, *entities.ProgressUpdate) error); ok {
+		r1 = rf(_a0, _a1, _a2, _a3)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Purge provides a mock function with given fields: _a0, _a1
+func (_m *IdbService) Purge(_a0 context.Context, _a1 time.Duration) (int64, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 int64
+	if rf, ok := ret.Get(0).(func(context.Context, time.Duration) int64); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Duration) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Restore provides a mock function with given fields: _a0, _a1, _a2
+func (_m *IdbService) Restore(_a0 context.Context, _a1 string, _a2 string) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1, _a2)
+
+	var r0 *entities.Book
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *entities.Book); ok {
+		r0 = rf(_a0, _a1, _a2)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Book)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(_a0, _a1, _a2)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Stats provides a mock function with given fields: _a0, _a1
+func (_m *IdbService) Stats(_a0 context.Context, _a1 string) (*entities.Stats, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *entities.Stats
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.Stats); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Stats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Trash provides a mock function with given fields: _a0, _a1, _a2, _a3
+func (_m *IdbService) Trash(_a0 context.Context, _a1 int64, _a2 int64, _a3 string) ([]*entities.Book, error) {
 	ret := _m.Called(_a0, _a1, _a2, _a3)
 
 	var r0 []*entities.Book
@@ -60,13 +212,13 @@ func (_m *IdbService) List(_a0 context.Context, _a1 int64, _a2 int64, _a3 string
 	return r0, r1
 }
 
-// Upsert provides a mock function with given fields: _a0, _a1, _a2, _a3, _a4, _a5, _a6, _a7, _a8, _a9, _a10, _a11, _a12, _a13, _a14
-func (_m *IdbService) Upsert(_a0 context.Context, _a1 string, _a2 string, _a3 string, _a4 string, _a5 string, _a6 string, _a7 string, _a8 string, _a9 string, _a10 string, _a11 string, _a12 int64, _a13 int64, _a14 int64) (*entities.Book, error) {
-	ret := _m.Called(_a0, _a1, _a2, _a3, _a4, _a5, _a6, _a7, _a8, _a9, _a10, _a11, _a12, _a13, _a14)
+// Upsert provides a mock function with given fields: _a0, _a1
+func (_m *IdbService) Upsert(_a0 context.Context, _a1 *entities.Book) (*entities.Book, error) {
+	ret := _m.Called(_a0, _a1)
 
 	var r0 *entities.Book
-	if rf, ok := ret.Get(0).(func(context.Context, string, string, string, string, string, string, string, string, string, string, string, int64, int64, int64) *entities.Book); ok {
-		r0 = rf(_a0, _a1, _a2, _a3, _a4, _a5, _a6, _a7, _a8, _a9, _a10, _a11, _a12, _a13, _a14)
+	if rf, ok := ret.Get(0).(func(context.Context, *entities.Book) *entities.Book); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*entities.Book)
@@ -74,8 +226,8 @@ func (_m *IdbService) Upsert(_a0 context.Context, _a1 string, _a2 string, _a3 st
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, string, string, string, string, string, string, string, string, string, string, string, int64, int64, int64) error); ok {
-		r1 = rf(_a0, _a1, _a2, _a3, _a4, _a5, _a6, _a7, _a8, _a9, _a10, _a11, _a12, _a13, _a14)
+	if rf, ok := ret.Get(1).(func(context.Context, *entities.Book) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
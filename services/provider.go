@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	goisbn "github.com/abx123/go-isbn"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// Names of the providers of the registry, the go-isbn providers are named after their source, ie: google, openlibrary
+const (
+	// ProviderGoISBN queries every go-isbn provider at once and takes the first book found
+	ProviderGoISBN = "goisbn"
+	// ProviderCrawler scrapes the book page of isbndb.com
+	ProviderCrawler = "crawler"
+)
+
+// MetadataProvider looks up the details of a book by its ISBN-13, ErrBookNotFound tells the provider does not know the book
+type MetadataProvider interface {
+	Name() string
+	Lookup(ctx context.Context, isbn string) (*entities.Book, error)
+}
+
+// errUnconfirmed is wrapped with ErrBookNotFound when a provider may not know the book because it failed, ie: go-isbn reports
+// any failure as not found. Such a book not found is cached for the UnconfirmedTTL of the cache
+var errUnconfirmed = errors.New("unconfirmed")
+
+// FailurePolicy decides how a provider chain continues after a provider fails, a book that is not found always moves on
+type FailurePolicy string
+
+// Failure policies
+const (
+	// FailNext moves on to the next provider
+	FailNext FailurePolicy = "next"
+	// FailStop ends the lookup with the error of the provider
+	FailStop FailurePolicy = "stop"
+)
+
+// ProviderConfig enables a provider of the registry in a chain
+type ProviderConfig struct {
+	Name string
+	// Timeout bounds an attempt to look a book up with the provider, a zero duration means no timeout of its own
+	Timeout time.Duration
+	Policy  FailurePolicy
+	Retry   RetryPolicy
+	Breaker BreakerPolicy
+}
+
+// ParseProviders parses a comma separated chain of providers in lookup order, ie: "google:2s,openlibrary:2s:stop:retries=0,crawler".
+// A provider name is followed by an optional timeout and failure policy, the policy defaults to next.
+// The options retries, backoff, failures and cooldown override DefaultRetry and DefaultBreaker.
+func ParseProviders(s string) ([]ProviderConfig, error) {
+	configs := []ProviderConfig{}
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		parts := strings.Split(v, ":")
+		c := ProviderConfig{Name: strings.TrimSpace(parts[0]), Policy: FailNext, Retry: DefaultRetry, Breaker: DefaultBreaker}
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.Contains(p, "=") {
+				if err := c.option(p); err != nil {
+					return nil, fmt.Errorf("invalid provider %q: %w", v, err)
+				}
+				continue
+			}
+			switch FailurePolicy(p) {
+			case FailNext, FailStop:
+				c.Policy = FailurePolicy(p)
+				continue
+			}
+			d, err := time.ParseDuration(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid provider %q: %q is neither a timeout nor a failure policy", v, p)
+			}
+			c.Timeout = d
+		}
+		configs = append(configs, c)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no provider in %q", s)
+	}
+	return configs, nil
+}
+
+// option sets the retry or breaker option name=value
+func (c *ProviderConfig) option(o string) error {
+	i := strings.Index(o, "=")
+	name, val := o[:i], o[i+1:]
+	var err error
+	switch name {
+	case "retries":
+		c.Retry.Retries, err = strconv.Atoi(val)
+		if err == nil && c.Retry.Retries < 0 {
+			err = fmt.Errorf("negative retries")
+		}
+	case "backoff":
+		c.Retry.Backoff, err = time.ParseDuration(val)
+	case "failures":
+		c.Breaker.Failures, err = strconv.Atoi(val)
+	case "cooldown":
+		c.Breaker.Cooldown, err = time.ParseDuration(val)
+	default:
+		err = fmt.Errorf("unknown option %q", name)
+	}
+	if err != nil {
+		return fmt.Errorf("%q: %w", o, err)
+	}
+	return nil
+}
+
+// Provider is a provider of a chain with its timeout, failure policy and resilience
+type Provider struct {
+	MetadataProvider
+	Timeout time.Duration
+	Policy  FailurePolicy
+	Retry   RetryPolicy
+	// breaker is nil for a provider which is never skipped
+	breaker *circuitBreaker
+}
+
+// Registry creates the providers a chain is configured from, by name
+type Registry map[string]func() MetadataProvider
+
+// Chain creates the providers of configs in order, an unknown provider name is an error
+func (r Registry) Chain(configs []ProviderConfig) ([]Provider, error) {
+	chain := make([]Provider, 0, len(configs))
+	for _, c := range configs {
+		create, ok := r[c.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown provider %q, expected one of %s", c.Name, strings.Join(r.names(), ", "))
+		}
+		p := Provider{MetadataProvider: create(), Timeout: c.Timeout, Policy: c.Policy, Retry: c.Retry}
+		p.breaker = newCircuitBreaker(p.Name(), c.Breaker)
+		chain = append(chain, p)
+	}
+	return chain, nil
+}
+
+func (r Registry) names() []string {
+	names := make([]string, 0, len(r))
+	for n := range r {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookup looks the book up unless the circuit of the provider is open, failed attempts are retried by the retry policy
+func (p Provider) lookup(ctx context.Context, isbn string) (*entities.Book, error) {
+	if p.breaker != nil && !p.breaker.allow() {
+		providerRejections.WithLabelValues(p.Name()).Inc()
+		return nil, fmt.Errorf("%w: %s: circuit open", constant.ErrRetrievingBookDetails, p.Name())
+	}
+	b, err := p.attempt(ctx, isbn)
+	for n := 0; n < p.Retry.Retries && failed(err) && ctx.Err() == nil; n++ {
+		if !sleep(ctx, p.Retry.delay(n)) {
+			break
+		}
+		providerRetries.WithLabelValues(p.Name()).Inc()
+		b, err = p.attempt(ctx, isbn)
+	}
+	if p.breaker != nil {
+		switch {
+		case ctx.Err() != nil:
+			p.breaker.record(breakerIgnored)
+		case failed(err):
+			p.breaker.record(breakerFailure)
+		default:
+			p.breaker.record(breakerSuccess)
+		}
+	}
+	return b, err
+}
+
+// attempt looks the book up within the timeout of the provider
+func (p Provider) attempt(ctx context.Context, isbn string) (*entities.Book, error) {
+	if p.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+	}
+	return p.Lookup(ctx, isbn)
+}
+
+// failed reports whether err is a failure of a provider, not finding the book is not
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, constant.ErrBookNotFound)
+}
+
+// Status returns the configuration and health of the provider
+func (p Provider) Status() *entities.ProviderStatus {
+	s := &entities.ProviderStatus{Name: p.Name(), Timeout: p.Timeout, Policy: string(p.Policy), Retries: p.Retry.Retries, State: entities.CircuitClosed}
+	if p.breaker != nil {
+		p.breaker.status(s)
+	}
+	return s
+}
+
+// providerFunc adapts a lookup function to a MetadataProvider
+type providerFunc struct {
+	name   string
+	lookup func(ctx context.Context, isbn string) (*entities.Book, error)
+}
+
+// ProviderFunc returns a MetadataProvider named name which looks books up with lookup
+func ProviderFunc(name string, lookup func(ctx context.Context, isbn string) (*entities.Book, error)) MetadataProvider {
+	return &providerFunc{name: name, lookup: lookup}
+}
+
+// Name returns the name of the provider
+func (p *providerFunc) Name() string {
+	return p.name
+}
+
+// Lookup looks the book up with the function of the provider
+func (p *providerFunc) Lookup(ctx context.Context, isbn string) (*entities.Book, error) {
+	return p.lookup(ctx, isbn)
+}
+
+// goisbnProvider looks books up with go-isbn, which does not take a context so an abandoned lookup ends with the timeout of its client
+type goisbnProvider struct {
+	name string
+	gi   goisbn.Queryer
+}
+
+// NewGoISBNProvider returns a MetadataProvider named name backed by gi
+func NewGoISBNProvider(name string, gi goisbn.Queryer) MetadataProvider {
+	return &goisbnProvider{name: name, gi: gi}
+}
+
+// Name returns the name of the provider
+func (p *goisbnProvider) Name() string {
+	return p.name
+}
+
+// Lookup looks the book up with go-isbn until ctx is done
+func (p *goisbnProvider) Lookup(ctx context.Context, isbn string) (*entities.Book, error) {
+	type result struct {
+		book *goisbn.Book
+		err  error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		b, err := p.gi.Get(isbn)
+		ch <- result{book: b, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %s: %v", constant.ErrRetrievingBookDetails, p.name, ctx.Err())
+	case res := <-ch:
+		if res.err != nil {
+			// go-isbn does not export its errors, any failure is reported as an unconfirmed not found
+			return nil, fmt.Errorf("%w: %s: %w: %v", constant.ErrBookNotFound, p.name, errUnconfirmed, res.err)
+		}
+		return mapBookToEnitiy(res.book), nil
+	}
+}
+
+// Registry returns the providers a chain of the service can be configured from: goisbn, crawler and every go-isbn provider.
+// The goodreads and isbndb providers of go-isbn find no book unless their API key is set
+func (svc *BookService) Registry() Registry {
+	r := Registry{
+		ProviderGoISBN: func() MetadataProvider {
+			return NewGoISBNProvider(ProviderGoISBN, goisbn.NewGoISBN(goisbn.DEFAULT_PROVIDERS))
+		},
+		ProviderCrawler: func() MetadataProvider {
+			return ProviderFunc(ProviderCrawler, svc.crawl)
+		},
+	}
+	for _, name := range goisbn.DEFAULT_PROVIDERS {
+		name := name
+		r[name] = func() MetadataProvider {
+			return NewGoISBNProvider(name, goisbn.NewGoISBN([]string{name}))
+		}
+	}
+	return r
+}
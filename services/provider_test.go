@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	goisbn "github.com/abx123/go-isbn"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+func TestParseProviders(t *testing.T) {
+	type testCase struct {
+		name   string
+		desc   string
+		input  string
+		expRes []ProviderConfig
+		expErr bool
+	}
+	testCases := []testCase{
+		{
+			name:  "Happy Case",
+			desc:  "names only",
+			input: "goisbn,crawler",
+			expRes: []ProviderConfig{
+				{Name: "goisbn", Policy: FailNext, Retry: DefaultRetry, Breaker: DefaultBreaker},
+				{Name: "crawler", Policy: FailNext, Retry: DefaultRetry, Breaker: DefaultBreaker},
+			},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "timeouts and policies",
+			input: " google:2s , openlibrary:stop:500ms,crawler:next ",
+			expRes: []ProviderConfig{
+				{Name: "google", Timeout: 2 * time.Second, Policy: FailNext, Retry: DefaultRetry, Breaker: DefaultBreaker},
+				{Name: "openlibrary", Timeout: 500 * time.Millisecond, Policy: FailStop, Retry: DefaultRetry, Breaker: DefaultBreaker},
+				{Name: "crawler", Policy: FailNext, Retry: DefaultRetry, Breaker: DefaultBreaker},
+			},
+		},
+		{
+			name:  "Happy Case",
+			desc:  "retry and breaker options",
+			input: "google:2s:retries=3:backoff=50ms,crawler:failures=0:cooldown=1m:retries=0",
+			expRes: []ProviderConfig{
+				{Name: "google", Timeout: 2 * time.Second, Policy: FailNext, Retry: RetryPolicy{Retries: 3, Backoff: 50 * time.Millisecond}, Breaker: DefaultBreaker},
+				{Name: "crawler", Policy: FailNext, Retry: RetryPolicy{Backoff: DefaultRetry.Backoff}, Breaker: BreakerPolicy{Cooldown: time.Minute}},
+			},
+		},
+		{
+			name:   "Sad Case",
+			desc:   "neither a timeout nor a policy",
+			input:  "google:fast",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "unknown option",
+			input:  "google:jitter=1s",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "negative retries",
+			input:  "google:retries=-1",
+			expErr: true,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "no provider",
+			input:  " , ",
+			expErr: true,
+		},
+	}
+	for _, v := range testCases {
+		actRes, actErr := ParseProviders(v.input)
+		if v.expErr {
+			assert.Error(t, actErr, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+	}
+}
+
+func TestRegistryChain(t *testing.T) {
+	svc := NewBookService(&MockGOISBN{})
+	type testCase struct {
+		name     string
+		desc     string
+		configs  []ProviderConfig
+		expNames []string
+		expErr   bool
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "providers in configured order",
+			configs:  []ProviderConfig{{Name: "crawler", Timeout: time.Second}, {Name: goisbn.ProviderOpenLibrary}, {Name: "goisbn"}},
+			expNames: []string{"crawler", "openlibrary", "goisbn"},
+		},
+		{
+			name:    "Sad Case",
+			desc:    "unknown provider",
+			configs: []ProviderConfig{{Name: "google"}, {Name: "amazon"}},
+			expErr:  true,
+		},
+	}
+	for _, v := range testCases {
+		chain, actErr := svc.Registry().Chain(v.configs)
+		if v.expErr {
+			assert.Error(t, actErr, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		names := []string{}
+		for i, p := range chain {
+			names = append(names, p.Name())
+			assert.Equal(t, v.configs[i].Timeout, p.Timeout, v.desc)
+		}
+		assert.Equal(t, v.expNames, names, v.desc)
+	}
+}
+
+func TestGetProviderChain(t *testing.T) {
+	found := func(name string) MetadataProvider {
+		return ProviderFunc(name, func(context.Context, string) (*entities.Book, error) {
+			return &entities.Book{Title: "DUMMY", Source: name}, nil
+		})
+	}
+	failing := func(name string, err error) MetadataProvider {
+		return ProviderFunc(name, func(context.Context, string) (*entities.Book, error) {
+			return nil, err
+		})
+	}
+	slow := ProviderFunc("slow", func(ctx context.Context, _ string) (*entities.Book, error) {
+		<-ctx.Done()
+		return nil, fmt.Errorf("%w: %v", constant.ErrRetrievingBookDetails, ctx.Err())
+	})
+	crawler := func(status int) MetadataProvider {
+		svc := NewBookService(&MockGOISBN{})
+		svc.client = &MockClient{
+			MockDo: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+			},
+		}
+		return ProviderFunc(ProviderCrawler, svc.crawl)
+	}
+	type testCase struct {
+		name      string
+		desc      string
+		chain     []Provider
+		ctxCancel bool
+		expSource string
+		expErr    error
+	}
+	testCases := []testCase{
+		{
+			name:      "Happy Case",
+			desc:      "first provider which finds the book",
+			chain:     []Provider{{MetadataProvider: failing("a", constant.ErrBookNotFound)}, {MetadataProvider: found("b")}, {MetadataProvider: found("c")}},
+			expSource: "b",
+		},
+		{
+			name:      "Happy Case",
+			desc:      "failing provider falls back to the next one",
+			chain:     []Provider{{MetadataProvider: failing("a", constant.ErrRetrievingBookDetails), Policy: FailNext}, {MetadataProvider: found("b")}},
+			expSource: "b",
+		},
+		{
+			name:      "Happy Case",
+			desc:      "provider timing out falls back to the next one",
+			chain:     []Provider{{MetadataProvider: slow, Timeout: time.Millisecond}, {MetadataProvider: found("b")}},
+			expSource: "b",
+		},
+		{
+			name:      "Happy Case",
+			desc:      "book not found by a provider which stops on failure",
+			chain:     []Provider{{MetadataProvider: failing("a", constant.ErrBookNotFound), Policy: FailStop}, {MetadataProvider: found("b")}},
+			expSource: "b",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "failing provider stops the lookup",
+			chain:  []Provider{{MetadataProvider: failing("a", constant.ErrRetrievingBookDetails), Policy: FailStop}, {MetadataProvider: found("b")}},
+			expErr: constant.ErrRetrievingBookDetails,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "error of the last provider",
+			chain:  []Provider{{MetadataProvider: failing("a", constant.ErrRetrievingBookDetails)}, {MetadataProvider: failing("b", constant.ErrBookNotFound)}},
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:      "Happy Case",
+			desc:      "book without a page on the crawler site found by the next provider",
+			chain:     []Provider{{MetadataProvider: crawler(http.StatusNotFound)}, {MetadataProvider: found("b")}},
+			expSource: "b",
+		},
+		{
+			name:   "Sad Case",
+			desc:   "book without a page on the crawler site",
+			chain:  []Provider{{MetadataProvider: crawler(http.StatusNotFound)}},
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "crawler site failing",
+			chain:  []Provider{{MetadataProvider: crawler(http.StatusServiceUnavailable)}},
+			expErr: constant.ErrRetrievingBookDetails,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "book not found after a provider failed is unconfirmed",
+			chain:  []Provider{{MetadataProvider: failing("a", constant.ErrRetrievingBookDetails)}, {MetadataProvider: failing("b", constant.ErrBookNotFound)}},
+			expErr: errUnconfirmed,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "book not found by a go-isbn provider is unconfirmed",
+			chain:  []Provider{{MetadataProvider: failing("a", fmt.Errorf("%w: %w", constant.ErrBookNotFound, errUnconfirmed))}, {MetadataProvider: failing("b", constant.ErrBookNotFound)}},
+			expErr: errUnconfirmed,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "no provider",
+			chain:  []Provider{},
+			expErr: constant.ErrBookNotFound,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "request canceled",
+			chain:     []Provider{{MetadataProvider: slow}, {MetadataProvider: found("b")}},
+			ctxCancel: true,
+			expErr:    constant.ErrRequestCanceled,
+		},
+	}
+	for _, v := range testCases {
+		gi := &MockGOISBN{
+			MockValidateISBN: func(string) bool {
+				return true
+			},
+		}
+		svc := NewBookService(gi).WithProviders(v.chain)
+		ctx, cancel := context.WithCancel(context.Background())
+		if v.ctxCancel {
+			cancel()
+		}
+		actRes, actErr := svc.Get(ctx, "dummy isbn")
+		cancel()
+		if v.expErr != nil {
+			assert.ErrorIs(t, actErr, v.expErr, v.desc)
+			assert.Nil(t, actRes, v.desc)
+			continue
+		}
+		assert.NoError(t, actErr, v.desc)
+		assert.Equal(t, v.expSource, actRes.Source, v.desc)
+	}
+}
+
+func TestGoISBNProvider(t *testing.T) {
+	type testCase struct {
+		name      string
+		desc      string
+		goIsbnErr error
+		block     bool
+		expRes    *entities.Book
+		expErr    error
+	}
+	testCases := []testCase{
+		{
+			name:   "Happy Case",
+			desc:   "all ok",
+			expRes: book,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "goisbn returns error",
+			goIsbnErr: fmt.Errorf("book not found"),
+			expErr:    constant.ErrBookNotFound,
+		},
+		{
+			name:      "Sad Case",
+			desc:      "goisbn error is unconfirmed",
+			goIsbnErr: fmt.Errorf("google: 503 Service Unavailable"),
+			expErr:    errUnconfirmed,
+		},
+		{
+			name:   "Sad Case",
+			desc:   "timed out",
+			block:  true,
+			expErr: constant.ErrRetrievingBookDetails,
+		},
+	}
+	for _, v := range testCases {
+		release := make(chan struct{})
+		p := NewGoISBNProvider("google", &MockGOISBN{
+			MockGet: func(string) (*goisbn.Book, error) {
+				if v.block {
+					<-release
+				}
+				return giBook, v.goIsbnErr
+			},
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		actRes, actErr := p.Lookup(ctx, "ISBN13")
+		cancel()
+		close(release)
+		assert.Equal(t, "google", p.Name(), v.desc)
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		if v.expErr == nil {
+			assert.NoError(t, actErr, v.desc)
+		} else {
+			assert.ErrorIs(t, actErr, v.expErr, v.desc)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abx123/library/entities"
+)
+
+// maxBackoff bounds the delay between retries of a lookup
+const maxBackoff = 5 * time.Second
+
+// Default resilience of the providers of a configured chain
+var (
+	DefaultRetry   = RetryPolicy{Retries: 2, Backoff: 100 * time.Millisecond}
+	DefaultBreaker = BreakerPolicy{Failures: 5, Cooldown: 30 * time.Second}
+)
+
+var (
+	providerRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "library",
+		Name:      "provider_retries_total",
+		Help:      "Lookups of books retried after a provider failed.",
+	}, []string{"provider"})
+	providerRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "library",
+		Name:      "provider_circuit_rejections_total",
+		Help:      "Lookups of books which skipped a provider as its circuit was open.",
+	}, []string{"provider"})
+	providerCircuit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "library",
+		Name:      "provider_circuit_state",
+		Help:      "State of the circuit breaker of a provider, 0 is closed, 1 half-open and 2 open.",
+	}, []string{"provider"})
+)
+
+func init() {
+	prometheus.MustRegister(providerRetries, providerRejections, providerCircuit)
+}
+
+// circuitGauge is the value of the circuit state metric of a state
+var circuitGauge = map[entities.CircuitState]float64{
+	entities.CircuitClosed:   0,
+	entities.CircuitHalfOpen: 1,
+	entities.CircuitOpen:     2,
+}
+
+// RetryPolicy retries failed lookups of a provider, lookups of books which are not found are not retried
+type RetryPolicy struct {
+	Retries int
+	// Backoff is the delay before the first retry, it doubles on every retry up to 5s and is jittered by up to half
+	Backoff time.Duration
+}
+
+// delay returns the jittered delay before retry n, starting at 0
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.Backoff << n
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// BreakerPolicy opens the circuit of a provider after consecutive failed lookups, a zero number of failures never opens it
+type BreakerPolicy struct {
+	Failures int
+	// Cooldown is how long the open circuit skips the provider before a trial lookup
+	Cooldown time.Duration
+}
+
+// breakerResult is the outcome of a lookup let through by a circuit breaker
+type breakerResult int
+
+const (
+	breakerSuccess breakerResult = iota
+	breakerFailure
+	// breakerIgnored is a lookup abandoned by its request, which tells nothing of the provider
+	breakerIgnored
+)
+
+// circuitBreaker skips a provider while it keeps failing
+type circuitBreaker struct {
+	name   string
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	// trial is set while the trial lookup of a half-open circuit is in flight
+	trial bool
+}
+
+func newCircuitBreaker(name string, policy BreakerPolicy) *circuitBreaker {
+	b := &circuitBreaker{name: name, policy: policy, now: time.Now}
+	providerCircuit.WithLabelValues(name).Set(circuitGauge[entities.CircuitClosed])
+	return b
+}
+
+// allow reports whether a lookup can go through, only one lookup goes through a half-open circuit until it is recorded
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.state()
+	providerCircuit.WithLabelValues(b.name).Set(circuitGauge[state])
+	switch {
+	case state == entities.CircuitOpen:
+		return false
+	case state == entities.CircuitHalfOpen && b.trial:
+		return false
+	case state == entities.CircuitHalfOpen:
+		b.trial = true
+	}
+	return true
+}
+
+// record records the result of a lookup let through, a failure opens the circuit once the failures reach the policy
+func (b *circuitBreaker) record(r breakerResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch r {
+	case breakerSuccess:
+		b.failures = 0
+	case breakerFailure:
+		b.failures++
+		if b.policy.Failures > 0 && b.failures >= b.policy.Failures {
+			b.openUntil = b.now().Add(b.policy.Cooldown)
+		}
+	}
+	providerCircuit.WithLabelValues(b.name).Set(circuitGauge[b.state()])
+}
+
+// state returns the state of the circuit, the lock must be held
+func (b *circuitBreaker) state() entities.CircuitState {
+	switch {
+	case b.policy.Failures <= 0 || b.failures < b.policy.Failures:
+		return entities.CircuitClosed
+	case b.now().Before(b.openUntil):
+		return entities.CircuitOpen
+	}
+	return entities.CircuitHalfOpen
+}
+
+// status sets the state of the circuit in s
+func (b *circuitBreaker) status(s *entities.ProviderStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s.State, s.Failures = b.state(), b.failures
+	if s.State == entities.CircuitOpen {
+		openUntil := b.openUntil
+		s.OpenUntil = &openUntil
+	}
+}
+
+// sleep waits for d unless ctx is done first, reports whether it waited
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goisbn "github.com/abx123/go-isbn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abx123/library/constant"
+	"github.com/abx123/library/entities"
+)
+
+// flakyProvider fails with errs in order, then finds the book
+func flakyProvider(calls *int, errs ...error) MetadataProvider {
+	return ProviderFunc("flaky", func(context.Context, string) (*entities.Book, error) {
+		*calls++
+		if *calls <= len(errs) {
+			return nil, errs[*calls-1]
+		}
+		return &entities.Book{Title: "DUMMY", Source: "flaky"}, nil
+	})
+}
+
+func TestProviderRetry(t *testing.T) {
+	type testCase struct {
+		name     string
+		desc     string
+		retries  int
+		errs     []error
+		expCalls int
+		expErr   error
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "found after failures are retried",
+			retries:  2,
+			errs:     []error{constant.ErrRetrievingBookDetails, constant.ErrRetrievingBookDetails},
+			expCalls: 3,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "retries exhausted",
+			retries:  1,
+			errs:     []error{constant.ErrRetrievingBookDetails, constant.ErrRetrievingBookDetails},
+			expCalls: 2,
+			expErr:   constant.ErrRetrievingBookDetails,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "book not found is not retried",
+			retries:  2,
+			errs:     []error{constant.ErrBookNotFound},
+			expCalls: 1,
+			expErr:   constant.ErrBookNotFound,
+		},
+	}
+	for _, v := range testCases {
+		calls := 0
+		p := Provider{MetadataProvider: flakyProvider(&calls, v.errs...), Retry: RetryPolicy{Retries: v.retries, Backoff: time.Millisecond}}
+		retries := testutil.ToFloat64(providerRetries.WithLabelValues("flaky"))
+		actRes, actErr := p.lookup(context.Background(), "9780751562774")
+		if v.expErr != nil {
+			assert.ErrorIs(t, actErr, v.expErr, v.desc)
+			assert.Nil(t, actRes, v.desc)
+		} else {
+			assert.NoError(t, actErr, v.desc)
+			assert.Equal(t, "DUMMY", actRes.Title, v.desc)
+		}
+		assert.Equal(t, v.expCalls, calls, v.desc)
+		assert.Equal(t, retries+float64(v.expCalls-1), testutil.ToFloat64(providerRetries.WithLabelValues("flaky")), v.desc)
+	}
+}
+
+func TestProviderRetryCanceled(t *testing.T) {
+	calls := 0
+	p := Provider{MetadataProvider: flakyProvider(&calls, constant.ErrRetrievingBookDetails), Retry: RetryPolicy{Retries: 2, Backoff: time.Hour}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := p.lookup(ctx, "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond}
+	for n, exp := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, maxBackoff, maxBackoff} {
+		if n == 3 {
+			// Delays are capped
+			n = 10
+		}
+		if n == 4 {
+			// Shifting out of range is capped too
+			n = 70
+		}
+		for i := 0; i < 20; i++ {
+			d := p.delay(n)
+			assert.GreaterOrEqual(t, d, exp/2)
+			assert.LessOrEqual(t, d, exp)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	calls := 0
+	errs := []error{constant.ErrRetrievingBookDetails, constant.ErrRetrievingBookDetails, constant.ErrRetrievingBookDetails}
+	p := Provider{MetadataProvider: flakyProvider(&calls, errs...), Policy: FailNext}
+	p.breaker = newCircuitBreaker("flaky", BreakerPolicy{Failures: 2, Cooldown: time.Minute})
+	p.breaker.now = func() time.Time { return now }
+	state := func() entities.CircuitState {
+		return p.Status().State
+	}
+
+	// Consecutive failures open the circuit
+	_, err := p.lookup(context.Background(), "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, entities.CircuitClosed, state())
+	_, err = p.lookup(context.Background(), "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, entities.CircuitOpen, state())
+	openUntil := now.Add(time.Minute)
+	assert.Equal(t, &entities.ProviderStatus{Name: "flaky", Policy: "next", State: entities.CircuitOpen, Failures: 2, OpenUntil: &openUntil}, p.Status())
+	assert.Equal(t, float64(2), testutil.ToFloat64(providerCircuit.WithLabelValues("flaky")))
+
+	// The open circuit skips the provider
+	rejections := testutil.ToFloat64(providerRejections.WithLabelValues("flaky"))
+	_, err = p.lookup(context.Background(), "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, rejections+1, testutil.ToFloat64(providerRejections.WithLabelValues("flaky")))
+
+	// After the cooldown a single trial goes through, its failure opens the circuit again
+	now = now.Add(time.Minute)
+	assert.Equal(t, entities.CircuitHalfOpen, state())
+	assert.True(t, p.breaker.allow())
+	assert.False(t, p.breaker.allow())
+	p.breaker.record(breakerIgnored)
+	_, err = p.lookup(context.Background(), "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, entities.CircuitOpen, state())
+
+	// A successful trial closes the circuit
+	now = now.Add(time.Minute)
+	actRes, err := p.lookup(context.Background(), "9780751562774")
+	assert.NoError(t, err)
+	assert.Equal(t, "DUMMY", actRes.Title)
+	assert.Equal(t, &entities.ProviderStatus{Name: "flaky", Policy: "next", State: entities.CircuitClosed}, p.Status())
+	assert.Equal(t, float64(0), testutil.ToFloat64(providerCircuit.WithLabelValues("flaky")))
+}
+
+// TestGoISBNCircuitBreaker shows go-isbn failures only engage the circuit breaker by timing out, go-isbn reports its errors as books not found
+func TestGoISBNCircuitBreaker(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	var calls int64
+	hanging := &MockGOISBN{
+		MockGet: func(string) (*goisbn.Book, error) {
+			atomic.AddInt64(&calls, 1)
+			<-release
+			return nil, fmt.Errorf("google: 503 Service Unavailable")
+		},
+	}
+	configs, err := ParseProviders("goisbn:10ms:retries=0:failures=2")
+	require.NoError(t, err)
+	chain, err := Registry{ProviderGoISBN: func() MetadataProvider {
+		return NewGoISBNProvider(ProviderGoISBN, hanging)
+	}}.Chain(configs)
+	require.NoError(t, err)
+	p := chain[0]
+
+	// Timed out lookups are failures which open the circuit
+	for i := 0; i < 2; i++ {
+		_, err = p.lookup(context.Background(), "9780751562774")
+		assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	}
+	assert.Equal(t, entities.CircuitOpen, p.Status().State)
+
+	// The open circuit skips go-isbn
+	_, err = p.lookup(context.Background(), "9780751562774")
+	assert.ErrorIs(t, err, constant.ErrRetrievingBookDetails)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&calls))
+}
+
+// TestCrawlerNotFoundCircuitBreaker shows books without a page on the crawler site are neither retried nor failures of the crawler
+func TestCrawlerNotFoundCircuitBreaker(t *testing.T) {
+	var calls int64
+	svc := NewBookService(&MockGOISBN{})
+	svc.client = &MockClient{
+		MockDo: func(*http.Request) (*http.Response, error) {
+			atomic.AddInt64(&calls, 1)
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(strings.NewReader("Not Found"))}, nil
+		},
+	}
+	configs, err := ParseProviders("crawler:3s")
+	require.NoError(t, err)
+	chain, err := svc.Registry().Chain(configs)
+	require.NoError(t, err)
+	p := chain[0]
+
+	for i := 0; i < DefaultBreaker.Failures; i++ {
+		_, err = p.lookup(context.Background(), "9780751562774")
+		assert.ErrorIs(t, err, constant.ErrBookNotFound)
+		assert.NotErrorIs(t, err, errUnconfirmed)
+	}
+	assert.Equal(t, int64(DefaultBreaker.Failures), atomic.LoadInt64(&calls))
+	assert.Equal(t, entities.CircuitClosed, p.Status().State)
+}
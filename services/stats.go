@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/abx123/library/entities"
+)
+
+// statsTTL is how long statistics are cached, changes made through other instances of the service show after it
+const statsTTL = time.Minute
+
+// statsCache holds the statistics of users until their library changes or statsTTL passed.
+// The version of the cache is bumped by every change, statistics computed while the library of the user changed are not cached
+type statsCache struct {
+	mu      sync.Mutex
+	version uint64
+	entries map[string]*statsEntry
+	// sweep is when the expired entries are dropped next
+	sweep time.Time
+	now   func() time.Time
+}
+
+// statsEntry holds the statistics of a user, or none since the change of the library at version changed.
+// The entry of a user is dropped once it expired, a change older than statsTTL is no longer compared to
+type statsEntry struct {
+	stats   *entities.Stats
+	changed uint64
+	expires time.Time
+}
+
+func newStatsCache() *statsCache {
+	return &statsCache{
+		entries: map[string]*statsEntry{},
+		now:     time.Now,
+	}
+}
+
+// get returns the cached statistics of a user and the version of the cache
+func (c *statsCache) get(userId string) (*entities.Stats, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[userId]; ok && c.now().Before(e.expires) {
+		return e.stats, c.version
+	}
+	return nil, c.version
+}
+
+// put caches the statistics of a user computed at version, unless the library of the user changed since
+func (c *statsCache) put(userId string, version uint64, s *entities.Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.expire(now)
+	e, ok := c.entries[userId]
+	if ok && e.changed > version {
+		return
+	}
+	c.entries[userId] = &statsEntry{stats: s, expires: now.Add(statsTTL)}
+}
+
+// invalidate drops the statistics of a user
+func (c *statsCache) invalidate(userId string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.expire(now)
+	c.version++
+	c.entries[userId] = &statsEntry{changed: c.version, expires: now.Add(statsTTL)}
+}
+
+// expire drops the expired entries at most once per statsTTL, the cache only holds the users of the last two
+func (c *statsCache) expire(now time.Time) {
+	if now.Before(c.sweep) {
+		return
+	}
+	for userId, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, userId)
+		}
+	}
+	c.sweep = now.Add(statsTTL)
+}
+
+// Stats returns the statistics of the library of a user, they are computed by the database and cached until the library changes or statsTTL passed
+func (svc *DBService) Stats(ctx context.Context, userId string) (*entities.Stats, error) {
+	s, version := svc.stats.get(userId)
+	if s != nil {
+		return s, nil
+	}
+	s, err := svc.repo.Stats(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	svc.stats.put(userId, version, s)
+	return s, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/abx123/library/entities"
+	"github.com/abx123/library/repo/mocks"
+)
+
+func TestStats(t *testing.T) {
+	stats := &entities.Stats{Statuses: []entities.StatusCount{{Status: entities.StatusOwned, Count: 2}}, Rated: 1, AverageRating: 8}
+	type testCase struct {
+		name     string
+		desc     string
+		repoErr  error
+		change   func(svc *DBService)
+		expCalls int
+		expRes   *entities.Stats
+		expErr   error
+	}
+	testCases := []testCase{
+		{
+			name:     "Happy Case",
+			desc:     "statistics are cached",
+			expCalls: 1,
+			expRes:   stats,
+		},
+		{
+			name: "Happy Case",
+			desc: "deleting a book drops the cached statistics",
+			change: func(svc *DBService) {
+				svc.Delete(context.Background(), "isbn", "userid")
+			},
+			expCalls: 2,
+			expRes:   stats,
+		},
+		{
+			name: "Happy Case",
+			desc: "changes of other users keep the cached statistics",
+			change: func(svc *DBService) {
+				svc.Delete(context.Background(), "isbn", "other")
+			},
+			expCalls: 1,
+			expRes:   stats,
+		},
+		{
+			name:     "Sad Case",
+			desc:     "errors are not cached",
+			repoErr:  fmt.Errorf("mock error"),
+			expCalls: 2,
+			expErr:   fmt.Errorf("mock error"),
+		},
+	}
+	for _, v := range testCases {
+		repo := mocks.IdbRepo{}
+		dbSvc := NewDbService(&repo)
+		repo.On("Delete", context.Background(), mock.Anything).Return(nil)
+		repo.On("Stats", context.Background(), "userid").Return(v.expRes, v.repoErr)
+		actRes, actErr := dbSvc.Stats(context.Background(), "userid")
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		if v.change != nil {
+			v.change(dbSvc)
+		}
+		actRes, actErr = dbSvc.Stats(context.Background(), "userid")
+		assert.Equal(t, v.expRes, actRes, v.desc)
+		assert.Equal(t, v.expErr, actErr, v.desc)
+		repo.AssertNumberOfCalls(t, "Stats", v.expCalls)
+	}
+}
+
+func TestStatsChangedWhileComputed(t *testing.T) {
+	repo := mocks.IdbRepo{}
+	dbSvc := NewDbService(&repo)
+	// The library changes after the statistics were read
+	repo.On("Stats", context.Background(), "userid").Run(func(mock.Arguments) {
+		dbSvc.stats.invalidate("userid")
+	}).Return(&entities.Stats{}, nil)
+	_, err := dbSvc.Stats(context.Background(), "userid")
+	assert.NoError(t, err)
+	_, err = dbSvc.Stats(context.Background(), "userid")
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "Stats", 2)
+}
+
+func TestStatsExpire(t *testing.T) {
+	repo := mocks.IdbRepo{}
+	dbSvc := NewDbService(&repo)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	dbSvc.stats.now = func() time.Time { return now }
+	repo.On("Stats", context.Background(), mock.Anything).Return(&entities.Stats{}, nil)
+	repo.On("Delete", context.Background(), mock.Anything).Return(nil)
+
+	// Statistics are computed again once expired, ie: after a change through another instance
+	_, err := dbSvc.Stats(context.Background(), "userid")
+	assert.NoError(t, err)
+	now = now.Add(statsTTL - time.Second)
+	_, err = dbSvc.Stats(context.Background(), "userid")
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "Stats", 1)
+	now = now.Add(time.Second)
+	_, err = dbSvc.Stats(context.Background(), "userid")
+	assert.NoError(t, err)
+	repo.AssertNumberOfCalls(t, "Stats", 2)
+
+	// The entries of users neither changed nor read since they expired are dropped
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, dbSvc.Delete(context.Background(), "isbn", fmt.Sprintf("user%d", i)))
+	}
+	assert.Len(t, dbSvc.stats.entries, 101)
+	now = now.Add(2 * statsTTL)
+	assert.NoError(t, dbSvc.Delete(context.Background(), "isbn", "other"))
+	assert.Len(t, dbSvc.stats.entries, 1)
+}
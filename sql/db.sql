@@ -1,2 +0,0 @@
-CREATE DATABASE IF NOT EXISTS `library`;
-USE `library`;
\ No newline at end of file